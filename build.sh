@@ -39,6 +39,12 @@ declare -a PLATFORMS=(
     "openbsd/amd64"
 )
 
+# 内置的号段数据库，没有时程序只能查询运营商和固话区号，发布包必须包含
+if [ ! -f pkg/numloc/data/phone.dat ]; then
+    echo "错误: 未找到 pkg/numloc/data/phone.dat，请先执行 make phonedata 下载内置的号段数据库"
+    exit 1
+fi
+
 echo "开始构建 ${PROJECT_NAME} ${VERSION}"
 echo "构建时间: ${BUILD_TIME}"
 echo "Git 提交: ${GIT_COMMIT}"
@@ -0,0 +1,950 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"sim-sms-forward/pkg/archive"
+	"sim-sms-forward/pkg/config"
+	"sim-sms-forward/pkg/contacts"
+	"sim-sms-forward/pkg/events"
+	"sim-sms-forward/pkg/heartbeat"
+	"sim-sms-forward/pkg/modem"
+	"sim-sms-forward/pkg/notification"
+	"sim-sms-forward/pkg/numloc"
+	"sim-sms-forward/pkg/outbox"
+	"sim-sms-forward/pkg/processor"
+	"sim-sms-forward/pkg/spam"
+	"sim-sms-forward/pkg/types"
+	"sim-sms-forward/pkg/vault"
+)
+
+// subcommands 支持的子命令，第一个参数匹配时执行对应的子命令而不是启动转发服务
+var subcommands = map[string]func(args []string) error{
+	"rules":     runRulesCommand,
+	"spam":      runSpamCommand,
+	"contacts":  runContactsCommand,
+	"numloc":    runNumlocCommand,
+	"history":   runHistoryCommand,
+	"export":    runExportCommand,
+	"import":    runImportCommand,
+	"rekey":     runRekeyCommand,
+	"replay":    runReplayCommand,
+	"heartbeat": runHeartbeatCommand,
+	"storage":   runStorageCommand,
+	"help":      runHelpCommand,
+}
+
+// subcommandHelp 子命令的简要说明，按用法说明中的显示顺序排列
+var subcommandHelp = [][2]string{
+	{"rules", "用示例短信测试路由规则"},
+	{"spam", "训练、测试垃圾短信过滤，查看被过滤的短信"},
+	{"contacts", "管理发送方通讯录"},
+	{"numloc", "查询号码归属地，更新号段数据库"},
+	{"history", "搜索归档的短信"},
+	{"export", "导出归档为 JSON 行、CSV 或 SMS Backup & Restore XML"},
+	{"import", "从导出文件、备份或日志导入历史短信"},
+	{"replay", "按当前的规则和模板重新发送归档的短信"},
+	{"rekey", "更换存储加密的口令或密钥文件"},
+	{"heartbeat", "测试心跳和运行汇总配置"},
+	{"storage", "查看和清理调制解调器的短信存储"},
+	{"help", "显示此用法说明"},
+}
+
+// printUsage 打印启动方式和所有子命令
+func printUsage(program string) {
+	fmt.Printf("用法: %s <配置文件路径>\n", program)
+	fmt.Printf("      %s <调制解调器ID> <Bark密钥>\n", program)
+	fmt.Printf("      %s <子命令> [参数]\n", program)
+	fmt.Println()
+	fmt.Println("子命令:")
+	for _, cmd := range subcommandHelp {
+		fmt.Printf("  %-10s %s\n", cmd[0], cmd[1])
+	}
+	fmt.Println()
+	fmt.Printf("运行 %s <子命令> -h 查看子命令的参数\n", program)
+	fmt.Println("示例:")
+	fmt.Println("  ./sim-sms-forward config.json")
+	fmt.Println("  ./sim-sms-forward 0 xxxxx")
+	fmt.Println("  ./sim-sms-forward rules test -c config.json -sender 10086 -content 余额不足")
+}
+
+// runHelpCommand 执行 help 子命令，显示用法说明
+func runHelpCommand(args []string) error {
+	printUsage(os.Args[0])
+	return nil
+}
+
+// runSubcommand 如果命令行的第一个参数是子命令则执行它
+// 返回: 是否为子命令；子命令执行失败时打印错误并以非零状态退出
+func runSubcommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	run, ok := subcommands[args[0]]
+	if !ok {
+		return false
+	}
+	if err := run(args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+	return true
+}
+
+// loadConfig 加载配置文件，未配置数据目录时使用程序所在目录下的 data
+func loadConfig(path string) (*config.Config, error) {
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.DataDir == "" {
+		execPath, err := os.Executable()
+		if err != nil {
+			return nil, fmt.Errorf("获取可执行文件路径失败: %v", err)
+		}
+		cfg.DataDir = filepath.Join(filepath.Dir(execPath), "data")
+	}
+	return cfg, nil
+}
+
+// openArchive 打开短信归档，配置了加密时先用口令或密钥文件打开密钥
+func openArchive(cfg *config.Config) (*archive.Archive, error) {
+	key, err := vault.Open(cfg.Encryption, cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	return archive.Open(cfg.Archive, cfg.DataDir, key)
+}
+
+// runRulesCommand 执行 rules 子命令
+// 目前支持 rules test，用示例短信测试路由规则
+func runRulesCommand(args []string) error {
+	if len(args) == 0 || args[0] != "test" {
+		return fmt.Errorf("用法: rules test -c <配置文件路径> -sender <号码> -content <内容> [-modem <ID>] [-sim <ICCID>] [-time HH:MM]")
+	}
+
+	fs := flag.NewFlagSet("rules test", flag.ContinueOnError)
+	configPath := fs.String("c", "config.json", "配置文件路径")
+	sender := fs.String("sender", "", "示例短信的发送方号码")
+	content := fs.String("content", "", "示例短信的内容")
+	modemID := fs.String("modem", "", "接收短信的调制解调器ID，默认使用配置中的 modem_id")
+	sim := fs.String("sim", "", "接收短信的 SIM 卡 ICCID")
+	at := fs.String("time", "", "模拟的接收时间，格式 HH:MM，默认当前时间")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if *at != "" {
+		t, err := time.Parse("15:04", *at)
+		if err != nil {
+			return fmt.Errorf("时间格式无效，应为 HH:MM: %v", err)
+		}
+		now = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	}
+	if *modemID == "" {
+		*modemID = cfg.ModemID
+	}
+
+	sms := &types.SMS{
+		ID:        "test",
+		Sender:    *sender,
+		Content:   *content,
+		Timestamp: now.Format(time.RFC3339),
+		SIM:       *sim,
+	}
+	processor.ExtractMetadata(sms, *modemID)
+
+	sp := processor.NewSMSProcessorWithConfig(cfg)
+	sp.LookupSender(sms)
+	decision, traces, notifiers, err := sp.Route(sms, now)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("示例短信: 发送方=%s(%s) 调制解调器=%s SIM=%s 时间=%s 验证码=%s\n",
+		sms.Sender, sms.SenderName, sms.ModemID, sms.SIM, now.Format("15:04"), sms.Code)
+	fmt.Println()
+	if len(traces) == 0 {
+		fmt.Println("未配置路由规则")
+	}
+	for i, trace := range traces {
+		switch {
+		case trace.Matched && trace.Stopped:
+			fmt.Printf("%2d. [命中] %s（stop，不再匹配后续规则）\n", i+1, trace.Rule)
+		case trace.Matched:
+			fmt.Printf("%2d. [命中] %s\n", i+1, trace.Rule)
+		default:
+			fmt.Printf("%2d. [跳过] %s: %s\n", i+1, trace.Rule, trace.Reason)
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("优先级: %s\n", sms.Priority)
+	if decision.Drop {
+		fmt.Println("结果: 丢弃，不发送任何通知")
+		return nil
+	}
+	names := make([]string, 0, len(notifiers))
+	for _, n := range notifiers {
+		names = append(names, n.Name())
+	}
+	if len(names) == 0 {
+		fmt.Println("通知渠道: （无）")
+	} else {
+		fmt.Printf("通知渠道: %s\n", strings.Join(names, ", "))
+	}
+	fmt.Printf("标题: %s\n", notification.FormatTitle(sms))
+	fmt.Printf("正文:\n%s\n", notification.FormatBody(sms))
+	return nil
+}
+
+// runSpamCommand 执行 spam 子命令
+// 支持 train（标记垃圾短信或正常短信）、test（测试示例短信）和 list（查看被过滤的短信）
+func runSpamCommand(args []string) error {
+	usage := fmt.Errorf("用法:\n" +
+		"  spam train -c <配置文件路径> (-spam|-ham) [-content <内容>] [-file <文件，每行一条短信>]\n" +
+		"  spam test -c <配置文件路径> -sender <号码> -content <内容>\n" +
+		"  spam list -c <配置文件路径> [-n <条数>]")
+	if len(args) == 0 {
+		return usage
+	}
+
+	fs := flag.NewFlagSet("spam "+args[0], flag.ContinueOnError)
+	configPath := fs.String("c", "config.json", "配置文件路径")
+	markSpam := fs.Bool("spam", false, "标记为垃圾短信")
+	markHam := fs.Bool("ham", false, "标记为正常短信")
+	sender := fs.String("sender", "", "示例短信的发送方号码")
+	content := fs.String("content", "", "短信内容")
+	file := fs.String("file", "", "批量标记的短信文件，每行一条")
+	limit := fs.Int("n", 20, "显示最近的条数")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	// 模型和被过滤的短信归档可能已加密
+	key, err := vault.Open(cfg.Encryption, cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	filter := spam.NewFilter(cfg.Spam, cfg.DataDir, key)
+
+	switch args[0] {
+	case "train":
+		if *markSpam == *markHam {
+			return fmt.Errorf("必须指定 -spam 或 -ham 其中之一")
+		}
+		messages, err := readMessages(*content, *file)
+		if err != nil {
+			return err
+		}
+		for _, message := range messages {
+			if err := filter.Train(message, *markSpam); err != nil {
+				return err
+			}
+		}
+		model, err := filter.Model()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("已标记 %d 条短信，模型共有垃圾短信 %d 条、正常短信 %d 条\n", len(messages), model.SpamDocs, model.HamDocs)
+	case "test":
+		sms := &types.SMS{ID: "test", Sender: *sender, Content: *content}
+		processor.ExtractMetadata(sms, cfg.ModemID)
+		verdict := filter.Check(sms)
+		if verdict.Spam {
+			fmt.Printf("结果: 垃圾短信（处理方式: %s）\n", filter.Action())
+		} else {
+			fmt.Println("结果: 正常短信")
+		}
+		if verdict.Probability >= 0 {
+			fmt.Printf("贝叶斯分类垃圾短信概率: %.3f\n", verdict.Probability)
+		}
+		for _, reason := range verdict.Reasons {
+			fmt.Printf("  - %s\n", reason)
+		}
+	case "list":
+		records, err := filter.ReadQuarantine()
+		if err != nil {
+			return err
+		}
+		if len(records) > *limit {
+			records = records[len(records)-*limit:]
+		}
+		for _, r := range records {
+			fmt.Printf("[%s] %s %s: %s\n    %s\n", r.FilteredAt, r.Action, r.Sender, r.Content, strings.Join(r.Verdict.Reasons, "; "))
+		}
+		if len(records) == 0 {
+			fmt.Println("没有被过滤的短信")
+		}
+	default:
+		return usage
+	}
+	return nil
+}
+
+// readMessages 读取要标记的短信，-content 和 -file 可以同时使用
+func readMessages(content, path string) ([]string, error) {
+	var messages []string
+	if strings.TrimSpace(content) != "" {
+		messages = append(messages, content)
+	}
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("打开短信文件失败: %v", err)
+		}
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				messages = append(messages, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("读取短信文件失败: %v", err)
+		}
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("没有要标记的短信，请通过 -content 或 -file 指定")
+	}
+	return messages, nil
+}
+
+// runContactsCommand 执行 contacts 子命令，管理发送方通讯录
+func runContactsCommand(args []string) error {
+	usage := fmt.Errorf("用法:\n" +
+		"  contacts list -c <配置文件路径> [-builtin]\n" +
+		"  contacts add -c <配置文件路径> -name <名称> -number <号码>\n" +
+		"  contacts remove -c <配置文件路径> -number <号码>\n" +
+		"  contacts import -c <配置文件路径> -file <联系人文件.vcf|.csv>\n" +
+		"  contacts lookup -c <配置文件路径> -number <号码>")
+	if len(args) == 0 {
+		return usage
+	}
+
+	fs := flag.NewFlagSet("contacts "+args[0], flag.ContinueOnError)
+	configPath := fs.String("c", "config.json", "配置文件路径")
+	name := fs.String("name", "", "联系人名称")
+	number := fs.String("number", "", "联系人号码")
+	file := fs.String("file", "", "要导入的 vCard 或 CSV 文件")
+	builtin := fs.Bool("builtin", false, "列出内置的服务号码")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	book := contacts.NewBook(cfg.Contacts, cfg.DataDir)
+
+	switch args[0] {
+	case "list":
+		list := contacts.Builtin()
+		if !*builtin {
+			if list, err = book.List(); err != nil {
+				return err
+			}
+		}
+		for _, c := range list {
+			fmt.Printf("%-16s %s\n", c.Number, c.Name)
+		}
+		fmt.Printf("共 %d 个号码\n", len(list))
+	case "add":
+		if *name == "" || *number == "" {
+			return fmt.Errorf("必须指定 -name 和 -number")
+		}
+		if _, err := book.Add(contacts.Contact{Name: *name, Number: *number}); err != nil {
+			return err
+		}
+		fmt.Printf("已保存 %s → %s\n", contacts.Normalize(*number), *name)
+	case "remove":
+		if err := book.Remove(*number); err != nil {
+			return err
+		}
+		fmt.Printf("已删除 %s\n", contacts.Normalize(*number))
+	case "import":
+		if *file == "" {
+			return fmt.Errorf("必须指定 -file")
+		}
+		list, err := contacts.ImportFile(*file)
+		if err != nil {
+			return err
+		}
+		changed, err := book.Add(list...)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("从 %s 读取到 %d 个号码，新增或更新 %d 个，通讯录: %s\n", *file, len(list), changed, book.Path())
+	case "lookup":
+		found := book.Lookup(*number)
+		if found == "" {
+			found = "（未找到）"
+		}
+		fmt.Printf("%s → %s\n", contacts.Normalize(*number), found)
+	default:
+		return usage
+	}
+	return nil
+}
+
+// runNumlocCommand 执行 numloc 子命令，查询号码归属地或更新号段数据库
+func runNumlocCommand(args []string) error {
+	usage := fmt.Errorf("用法:\n" +
+		"  numloc lookup -c <配置文件路径> -number <号码>\n" +
+		"  numloc update -c <配置文件路径> -file <phone.dat>")
+	if len(args) == 0 {
+		return usage
+	}
+
+	fs := flag.NewFlagSet("numloc "+args[0], flag.ContinueOnError)
+	configPath := fs.String("c", "config.json", "配置文件路径")
+	number := fs.String("number", "", "要查询的号码")
+	file := fs.String("file", "", "新的号段数据库文件")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	locator := numloc.NewLocator(cfg.Location, cfg.DataDir)
+
+	switch args[0] {
+	case "lookup":
+		loc := locator.Lookup(*number)
+		result := loc.String()
+		if result == "" {
+			result = "（未知）"
+		}
+		fmt.Printf("%s → %s\n", contacts.Normalize(*number), result)
+		if source := locator.Source(); source != "" {
+			fmt.Printf("号段数据库: %s（版本 %s）\n", source, locator.Version())
+		} else {
+			fmt.Printf("号段数据库: %s 不存在，也没有内置数据库，只能查询运营商和固话区号\n", locator.Path())
+		}
+	case "update":
+		if *file == "" {
+			return fmt.Errorf("必须指定 -file")
+		}
+		db, err := locator.Update(*file)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("号段数据库已更新: 版本 %s，共 %d 个号段，保存到 %s\n", db.Version, db.Records, locator.Path())
+	default:
+		return usage
+	}
+	return nil
+}
+
+// runHistoryCommand 执行 history 子命令，搜索归档的短信
+func runHistoryCommand(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: history -c <配置文件路径> [-sender <号码或名称>] [-tag otp|spam|dropped] [-from YYYY-MM-DD] [-to YYYY-MM-DD] [-n <条数>] [-json] [搜索词...]")
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("c", "config.json", "配置文件路径")
+	sender := fs.String("sender", "", "发送方号码或通讯录名称，号码以 * 结尾表示前缀匹配")
+	tag := fs.String("tag", "", "只显示带有指定标签的短信: otp、spam、dropped")
+	from := fs.String("from", "", "起始日期（含），格式 YYYY-MM-DD")
+	to := fs.String("to", "", "结束日期（含），格式 YYYY-MM-DD")
+	limit := fs.Int("n", 20, "最多显示的条数，0 表示不限")
+	asJSON := fs.Bool("json", false, "以 JSON 行格式输出完整记录")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	q := archive.Query{Text: strings.Join(fs.Args(), " "), Sender: *sender, Tag: *tag, Limit: *limit}
+	if q.Since, q.Until, err = parseDateRange(*from, *to); err != nil {
+		return err
+	}
+
+	a, err := openArchive(cfg)
+	if err != nil {
+		return err
+	}
+	records, err := a.Search(q)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, r := range records {
+			if err := encoder.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, r := range records {
+		sender := r.Sender
+		if r.SenderName != "" {
+			sender = fmt.Sprintf("%s（%s）", r.SenderName, r.Sender)
+		}
+		fmt.Printf("#%d [%s] %s", r.ID, r.ReceivedAt.Local().Format("2006-01-02 15:04:05"), sender)
+		if len(r.Tags) > 0 {
+			fmt.Printf(" [%s]", strings.Join(r.Tags, ","))
+		}
+		fmt.Printf("\n    %s\n", r.Content)
+		if len(r.Deliveries) > 0 {
+			results := make([]string, 0, len(r.Deliveries))
+			for _, d := range r.Deliveries {
+				channel := d.Channel
+				if d.Replay {
+					channel += "（重发）"
+				}
+				if d.OK {
+					results = append(results, channel+" 成功")
+				} else {
+					results = append(results, fmt.Sprintf("%s 失败（%s）", channel, d.Error))
+				}
+			}
+			fmt.Printf("    投递: %s\n", strings.Join(results, "，"))
+		}
+	}
+	if len(records) == 0 {
+		fmt.Println("没有匹配的短信")
+	} else {
+		fmt.Printf("共 %d 条，归档: %s\n", len(records), a.Path())
+	}
+	return nil
+}
+
+// parseDateRange 解析 -from 和 -to 日期参数（均包含当天），为空的参数不限制
+func parseDateRange(from, to string) (since, until time.Time, err error) {
+	if from != "" {
+		if since, err = time.ParseInLocation("2006-01-02", from, time.Local); err != nil {
+			return since, until, fmt.Errorf("-from 日期格式无效，应为 YYYY-MM-DD: %v", err)
+		}
+	}
+	if to != "" {
+		if until, err = time.ParseInLocation("2006-01-02", to, time.Local); err != nil {
+			return since, until, fmt.Errorf("-to 日期格式无效，应为 YYYY-MM-DD: %v", err)
+		}
+		until = until.AddDate(0, 0, 1)
+	}
+	return since, until, nil
+}
+
+// runExportCommand 执行 export 子命令，将归档导出为 JSON 行、CSV 或 SMS Backup & Restore XML
+func runExportCommand(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: export -c <配置文件路径> -o <输出文件> [-format jsonl|csv|xml] [-from YYYY-MM-DD] [-to YYYY-MM-DD]")
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("c", "config.json", "配置文件路径")
+	output := fs.String("o", "", "输出文件，为 - 时输出到标准输出")
+	format := fs.String("format", "", "导出格式: jsonl、csv、xml，默认根据输出文件扩展名推断")
+	from := fs.String("from", "", "起始日期（含），格式 YYYY-MM-DD")
+	to := fs.String("to", "", "结束日期（含），格式 YYYY-MM-DD")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *output == "" {
+		return fmt.Errorf("必须指定 -o")
+	}
+	if *format == "" {
+		if *format = archive.FormatFromPath(*output); *format == "" || *format == archive.FormatLog {
+			return fmt.Errorf("无法根据扩展名识别导出格式，请指定 -format jsonl、csv 或 xml")
+		}
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	q := archive.Query{Ascending: true}
+	if q.Since, q.Until, err = parseDateRange(*from, *to); err != nil {
+		return err
+	}
+	a, err := openArchive(cfg)
+	if err != nil {
+		return err
+	}
+	records, err := a.Search(q)
+	if err != nil {
+		return err
+	}
+
+	if *output == "-" {
+		return archive.Export(os.Stdout, *format, records)
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("创建输出文件失败: %v", err)
+	}
+	if err := archive.Export(file, *format, records); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("写入输出文件失败: %v", err)
+	}
+	fmt.Printf("已导出 %d 条短信到 %s\n", len(records), *output)
+	return nil
+}
+
+// runImportCommand 执行 import 子命令，从导出文件、SMS Backup & Restore 备份或日志文件导入历史短信
+func runImportCommand(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: import -c <配置文件路径> [-format jsonl|csv|xml|log] <文件>...")
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("c", "config.json", "配置文件路径")
+	format := fs.String("format", "", "导入格式: jsonl、csv、xml、log，默认根据文件扩展名推断")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("必须指定要导入的文件")
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	a, err := openArchive(cfg)
+	if err != nil {
+		return err
+	}
+	for _, path := range fs.Args() {
+		records, err := archive.ParseFile(path, *format)
+		if err != nil {
+			return err
+		}
+		added, skipped, err := a.Import(records)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: 读取到 %d 条短信，导入 %d 条，跳过已存在的 %d 条\n", path, len(records), added, skipped)
+	}
+	return nil
+}
+
+// runRekeyCommand 执行 rekey 子命令，用新的口令或密钥文件重新加密归档、暂存队列、事件记录、被过滤的短信和垃圾短信模型
+// 也可以用于首次启用加密（配置中没有 encryption 时旧数据为明文）或解密为明文
+func runRekeyCommand(args []string) error {
+	fs := flag.NewFlagSet("rekey", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: rekey -c <配置文件路径> (-passphrase <新口令> | -passphrase-env <环境变量> | -key-file <新密钥文件> | -decrypt)")
+		fmt.Fprintln(fs.Output(), "执行前请先停止转发服务，完成后将配置文件中的 encryption 改为新的口令或密钥文件")
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("c", "config.json", "配置文件路径，其中的 encryption 为当前的口令或密钥文件")
+	var next vault.Config
+	fs.StringVar(&next.Passphrase, "passphrase", "", "新口令")
+	fs.StringVar(&next.PassphraseEnv, "passphrase-env", "", "从环境变量读取新口令")
+	fs.StringVar(&next.KeyFile, "key-file", "", "新密钥文件")
+	decrypt := fs.Bool("decrypt", false, "解密为明文，不再加密")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *decrypt == next.Enabled() {
+		fs.Usage()
+		return fmt.Errorf("必须指定新的口令、密钥文件或 -decrypt 其中之一")
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	// 新密钥的盐在改写数据之前保存，中途失败时用同样的参数重新运行会继续完成，
+	// 读取时同时接受旧密钥和新密钥加密的记录
+	oldKey, newKey, err := vault.BeginRekey(cfg.Encryption, next, cfg.DataDir)
+	if err != nil {
+		return err
+	}
+
+	// 先打开所有存储，确认都能用当前密钥读取后再开始改写
+	a, err := archive.Open(cfg.Archive, cfg.DataDir, oldKey)
+	if err != nil {
+		return err
+	}
+	ob, err := outbox.Open(filepath.Join(cfg.DataDir, "outbox.json"), oldKey)
+	if err != nil {
+		return err
+	}
+	bus, err := events.Open(cfg.DataDir, oldKey)
+	if err != nil {
+		return err
+	}
+	filter := spam.NewFilter(cfg.Spam, cfg.DataDir, oldKey)
+	if _, err := filter.ReadQuarantine(); err != nil {
+		return err
+	}
+	const resume = "（用相同的参数重新运行 rekey 可以继续）"
+	if err := a.Rekey(newKey); err != nil {
+		return fmt.Errorf("%v%s", err, resume)
+	}
+	fmt.Printf("已重新加密短信归档: %s\n", a.Path())
+	if err := ob.Rekey(newKey); err != nil {
+		return fmt.Errorf("%v%s", err, resume)
+	}
+	fmt.Println("已重新加密待发送队列")
+	if err := bus.Rekey(newKey); err != nil {
+		return fmt.Errorf("%v%s", err, resume)
+	}
+	fmt.Println("已重新加密事件记录")
+	if err := filter.Rekey(newKey); err != nil {
+		return fmt.Errorf("%v%s", err, resume)
+	}
+	fmt.Printf("已重新加密被过滤的短信和垃圾短信模型: %s\n", filter.Path())
+
+	if err := vault.CommitRekey(cfg.DataDir, newKey); err != nil {
+		return fmt.Errorf("%v%s", err, resume)
+	}
+	if newKey == nil {
+		fmt.Println("数据已解密为明文，请删除配置文件中的 encryption 后再启动服务")
+		return nil
+	}
+	fmt.Printf("已切换到新密钥 %s，请将配置文件中的 encryption 改为新的口令或密钥文件后再启动服务\n", newKey.ID())
+	return nil
+}
+
+// runReplayCommand 执行 replay 子命令，按当前的路由规则和模板重新发送归档的短信
+func runReplayCommand(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: replay -c <配置文件路径> (-id <序号,...> | -sender <号码或名称> | -from YYYY-MM-DD | -to YYYY-MM-DD) [-notify <渠道,...>] [-n <条数>] [-dry-run]")
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("c", "config.json", "配置文件路径")
+	ids := fs.String("id", "", "归档序号（history 输出中 # 后的数字），多个用逗号分隔")
+	sender := fs.String("sender", "", "发送方号码或通讯录名称，号码以 * 结尾表示前缀匹配")
+	from := fs.String("from", "", "起始日期（含），格式 YYYY-MM-DD")
+	to := fs.String("to", "", "结束日期（含），格式 YYYY-MM-DD")
+	notify := fs.String("notify", "", "只发送到指定的通知渠道，多个用逗号分隔，默认按路由规则选择")
+	limit := fs.Int("n", 20, "最多重新发送的条数，0 表示不限")
+	dryRun := fs.Bool("dry-run", false, "只预览要发送的短信和渠道，不实际发送")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *ids == "" && *sender == "" && *from == "" && *to == "" {
+		fs.Usage()
+		return fmt.Errorf("必须至少指定 -id、-sender、-from、-to 其中之一")
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	q := archive.Query{Sender: *sender, Limit: *limit, Ascending: true}
+	if q.Since, q.Until, err = parseDateRange(*from, *to); err != nil {
+		return err
+	}
+	for _, field := range splitList(*ids) {
+		id, err := strconv.ParseInt(strings.TrimPrefix(field, "#"), 10, 64)
+		if err != nil {
+			return fmt.Errorf("归档序号 %s 无效", field)
+		}
+		q.IDs = append(q.IDs, id)
+	}
+	only := splitList(*notify)
+
+	key, err := vault.Open(cfg.Encryption, cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	sp, err := processor.NewSMSProcessorWithKey(cfg, key)
+	if err != nil {
+		return err
+	}
+	if sp.Archive == nil {
+		return fmt.Errorf("短信归档不可用，请检查配置中的 archive")
+	}
+	enabled := make(map[string]bool)
+	for _, n := range sp.Notifiers {
+		enabled[n.Name()] = true
+	}
+	for _, name := range only {
+		if name == "mqtt" {
+			return fmt.Errorf("MQTT 需要转发服务的常驻连接，不支持重新发送")
+		}
+		if !enabled[name] {
+			return fmt.Errorf("通知渠道 %s 未启用", name)
+		}
+	}
+
+	records, err := sp.Archive.Search(q)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		fmt.Println("没有匹配的短信")
+		return nil
+	}
+
+	sent, failed := 0, 0
+	for _, r := range records {
+		result, err := sp.Replay(r, only, *dryRun)
+		if err != nil {
+			fmt.Printf("#%d 处理失败: %v\n", r.ID, err)
+			failed++
+			continue
+		}
+		sender := r.Sender
+		if result.SMS.SenderName != "" {
+			sender = fmt.Sprintf("%s（%s）", result.SMS.SenderName, r.Sender)
+		}
+		fmt.Printf("#%d [%s] %s\n", r.ID, r.ReceivedAt.Local().Format("2006-01-02 15:04:05"), sender)
+		fmt.Printf("    标题: %s\n", result.Title)
+		if len(result.Matched) > 0 {
+			fmt.Printf("    命中规则: %s\n", strings.Join(result.Matched, ", "))
+		}
+		if result.Skipped != "" {
+			fmt.Printf("    跳过: %s\n", result.Skipped)
+			continue
+		}
+		if *dryRun {
+			fmt.Printf("    将发送到: %s\n", strings.Join(result.Notifiers, ", "))
+			continue
+		}
+		results := make([]string, 0, len(result.Notifiers))
+		for _, name := range result.Notifiers {
+			if err := result.Errors[name]; err != nil {
+				results = append(results, fmt.Sprintf("%s 失败（%v）", name, err))
+			} else {
+				results = append(results, name+" 成功")
+			}
+		}
+		fmt.Printf("    发送: %s\n", strings.Join(results, "，"))
+		if len(result.Errors) > 0 {
+			failed++
+		} else {
+			sent++
+		}
+	}
+	if *dryRun {
+		fmt.Printf("预览 %d 条，未实际发送\n", len(records))
+		return nil
+	}
+	fmt.Printf("共 %d 条，重新发送成功 %d 条\n", len(records), sent)
+	if failed > 0 {
+		return fmt.Errorf("%d 条短信重新发送失败", failed)
+	}
+	return nil
+}
+
+// splitList 拆分逗号分隔的参数，忽略空白项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// runHeartbeatCommand 执行 heartbeat 子命令
+// 目前支持 heartbeat test，立即请求一次 ping 地址并推送一条运行汇总，用于检查心跳配置
+func runHeartbeatCommand(args []string) error {
+	if len(args) == 0 || args[0] != "test" {
+		return fmt.Errorf("用法: heartbeat test -c <配置文件路径> [-ping] [-summary]")
+	}
+	fs := flag.NewFlagSet("heartbeat test", flag.ContinueOnError)
+	configPath := fs.String("c", "config.json", "配置文件路径")
+	pingOnly := fs.Bool("ping", false, "只请求 ping 地址")
+	summaryOnly := fs.Bool("summary", false, "只推送运行汇总")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	doPing := !*summaryOnly || *pingOnly
+	doSummary := !*pingOnly || *summaryOnly
+
+	hb := heartbeat.New(cfg.Heartbeat, cfg.DeviceID)
+	failed := false
+	if doPing {
+		if cfg.Heartbeat.PingURL == "" {
+			fmt.Println("ping: 未配置 heartbeat.ping_url，跳过")
+		} else if err := hb.Ping(); err != nil {
+			fmt.Printf("ping: %v\n", err)
+			failed = true
+		} else {
+			fmt.Printf("ping: %s 成功\n", cfg.Heartbeat.PingURL)
+		}
+	}
+	if doSummary {
+		sp := processor.NewSMSProcessorWithConfig(cfg)
+		sms := hb.Collect(sp.ModemManager.GetStatus, nil).Message(time.Now())
+		sms.Title = "[测试] " + sms.Title
+		fmt.Printf("运行汇总: %s\n%s\n", sms.Title, sms.Body)
+		if err := sp.SendHeartbeat(sms); err != nil {
+			fmt.Printf("推送失败: %v\n", err)
+			failed = true
+		} else {
+			fmt.Println("推送成功")
+		}
+	}
+	if failed {
+		return fmt.Errorf("心跳测试失败")
+	}
+	return nil
+}
+
+// runStorageCommand 执行 storage 子命令，显示调制解调器短信存储的使用情况，可选清理指定状态的短信
+func runStorageCommand(args []string) error {
+	fs := flag.NewFlagSet("storage", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: storage -c <配置文件路径> [-purge sent,stored,unknown]")
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("c", "config.json", "配置文件路径")
+	purge := fs.String("purge", "", "删除指定状态的短信，多个用逗号分隔，可选 "+strings.Join(modem.PurgeableStates, "、"))
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	manager := modem.NewManager(cfg.ModemID)
+	if states := splitList(*purge); len(states) > 0 {
+		deleted, err := manager.PurgeSMS(states)
+		if deleted > 0 || err == nil {
+			fmt.Printf("已删除 %d 条短信\n", deleted)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	status, err := manager.GetStorageStatus(cfg.Storage.Capacity)
+	if err != nil {
+		return err
+	}
+	fmt.Println(processor.FormatStorage(status))
+	return nil
+}
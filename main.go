@@ -10,6 +10,7 @@ import (
 	"sim-sms-forward/pkg/config"
 	"sim-sms-forward/pkg/logger"
 	"sim-sms-forward/pkg/processor"
+	"sim-sms-forward/pkg/vault"
 	"strconv"
 	"time"
 )
@@ -20,6 +21,11 @@ func main() {
 	var cfg *config.Config
 	var err error
 
+	// 子命令，如 rules test
+	if runSubcommand(os.Args[1:]) {
+		return
+	}
+
 	// 支持两种启动方式
 	// 1. 仅指定配置文件路径: ./sim-sms-forward config.json
 	// 2. 兼容原有方式: ./sim-sms-forward <调制解调器ID> <Bark密钥>
@@ -57,11 +63,7 @@ func main() {
 		}
 	default:
 		// 显示用法说明
-		fmt.Printf("用法: %s <配置文件路径>\n", os.Args[0])
-		fmt.Printf("      %s <调制解调器ID> <Bark密钥>\n", os.Args[0])
-		fmt.Println("示例:")
-		fmt.Println("  ./sim-sms-forward config.json")
-		fmt.Println("  ./sim-sms-forward 0 xxxxx")
+		printUsage(os.Args[0])
 		os.Exit(1)
 	}
 
@@ -74,10 +76,16 @@ func main() {
 	}
 	execDir := filepath.Dir(execPath)
 	logDir := filepath.Join(execDir, "logs")
+	if cfg.DataDir == "" {
+		cfg.DataDir = filepath.Join(execDir, "data")
+	}
 	if err := logger.Init(logDir); err != nil {
 		fmt.Printf("初始化日志系统失败: %v\n", err)
 		os.Exit(1)
 	}
+	// 配置文件加载时已经验证过隐私级别
+	privacy, _ := logger.ParsePrivacy(cfg.LogPrivacy)
+	logger.SetPrivacy(privacy)
 
 	// 记录程序启动日志
 	logger.Info("========================================")
@@ -89,17 +97,42 @@ func main() {
 	logger.Infof("Hismsg开关: %v", cfg.EnableHismsg)
 	logger.Infof("休眠时间: %d秒", cfg.SleepDuration)
 	logger.Infof("日志目录: %s", logDir)
+	logger.Infof("数据目录: %s", cfg.DataDir)
+	logger.Infof("日志隐私级别: %s", privacy)
 	logger.Info("========================================")
 
+	// 打开归档和暂存队列的加密密钥，口令错误时拒绝启动，避免写入无法解密的数据
+	key, err := vault.Open(cfg.Encryption, cfg.DataDir)
+	if err != nil {
+		logger.Fatalf("打开加密存储失败: %v", err)
+	}
+	if key != nil {
+		logger.Infof("存储加密: 已启用（密钥 %s）", key.ID())
+	}
+
 	// 创建短信处理器实例，传入配置对象
-	smsProcessor := processor.NewSMSProcessorWithConfig(cfg)
+	smsProcessor, err := processor.NewSMSProcessorWithKey(cfg, key)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	if err := smsProcessor.Start(); err != nil {
+		logger.Fatalf("%v", err)
+	}
 
 	// 开始循环处理短信
 	logger.Info("开始循环监控短信...")
+	var lastErr string
 	for {
 		// 开始处理所有短信
+		// 调制解调器暂时不可用时继续等待它恢复，同样的错误只记录一次，避免日志刷屏
 		if err := smsProcessor.ProcessAllSMS(); err != nil {
-			logger.Fatalf("处理短信失败: %v", err)
+			if err.Error() != lastErr {
+				logger.Errorf("处理短信失败: %v", err)
+			}
+			lastErr = err.Error()
+		} else if lastErr != "" {
+			logger.Info("短信处理恢复正常")
+			lastErr = ""
 		}
 
 		// 等待指定时间后再次检查
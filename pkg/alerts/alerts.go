@@ -0,0 +1,249 @@
+// Package alerts 监控调制解调器和 SIM 卡的状态，状态变化时生成告警通知
+// 新状态需要连续多次检查都保持不变才会确认，两次告警之间有冷却时间，避免状态反复变化时频繁告警
+package alerts
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"sim-sms-forward/pkg/types"
+)
+
+// Config 调制解调器告警配置
+type Config struct {
+	Enable        bool     `json:"enable"`                   // 是否启用告警
+	Notifiers     []string `json:"notifiers,omitempty"`      // 接收告警的通知渠道，为空时使用所有渠道（MQTT 除外）
+	ConfirmChecks int      `json:"confirm_checks,omitempty"` // 新状态连续保持多少次检查后才确认，默认 2
+	Cooldown      int      `json:"cooldown,omitempty"`       // 两次告警的最短间隔（分钟），默认 10
+	WeakSignal    int      `json:"weak_signal,omitempty"`    // 信号质量低于这个百分比时告警，0 表示不检查
+}
+
+// Validate 验证告警配置，通知渠道是否存在由调用方检查
+func (c *Config) Validate() error {
+	if c.ConfirmChecks < 0 {
+		return fmt.Errorf("alerts.confirm_checks 不能小于 0")
+	}
+	if c.Cooldown < 0 {
+		return fmt.Errorf("alerts.cooldown 不能小于 0")
+	}
+	if c.WeakSignal < 0 || c.WeakSignal > 100 {
+		return fmt.Errorf("alerts.weak_signal 应在 0-100 之间")
+	}
+	return nil
+}
+
+// 调制解调器和 SIM 卡的状态分类
+const (
+	ConditionOK           = "ok"            // 已注册到本地网络
+	ConditionModemMissing = "modem_missing" // 找不到调制解调器
+	ConditionSIMMissing   = "sim_missing"   // 未检测到 SIM 卡
+	ConditionSIMLocked    = "sim_locked"    // SIM 卡需要 PIN 或 PUK
+	ConditionFailed       = "failed"        // 调制解调器处于 failed 状态
+	ConditionUnregistered = "unregistered"  // 未注册到网络
+	ConditionRoaming      = "roaming"       // 已注册，处于漫游状态
+	ConditionWeakSignal   = "weak_signal"   // 已注册，信号低于 weak_signal
+)
+
+// titles 进入各状态时的告警标题
+var titles = map[string]string{
+	ConditionOK:           "调制解调器恢复正常",
+	ConditionModemMissing: "调制解调器不可用",
+	ConditionSIMMissing:   "未检测到 SIM 卡",
+	ConditionSIMLocked:    "SIM 卡已锁定",
+	ConditionFailed:       "调制解调器故障",
+	ConditionUnregistered: "SIM 卡失去网络注册",
+	ConditionRoaming:      "SIM 卡正在漫游",
+	ConditionWeakSignal:   "信号弱",
+}
+
+// labels 各状态的简短说明
+var labels = map[string]string{
+	ConditionOK:           "正常",
+	ConditionModemMissing: "调制解调器不可用",
+	ConditionSIMMissing:   "无 SIM 卡",
+	ConditionSIMLocked:    "SIM 卡锁定",
+	ConditionFailed:       "调制解调器故障",
+	ConditionUnregistered: "未注册网络",
+	ConditionRoaming:      "漫游",
+	ConditionWeakSignal:   "信号弱",
+}
+
+// weakSignalMargin 信号恢复时需要超过 weak_signal 的幅度，避免信号在阈值附近波动时反复告警
+const weakSignalMargin = 5
+
+// Snapshot 一次检查得到的状态
+type Snapshot struct {
+	Condition     string
+	State         string
+	FailedReason  string
+	Registration  string
+	Operator      string
+	SignalQuality int
+	Error         string // 获取状态失败的原因
+	Time          time.Time
+}
+
+// Monitor 调制解调器状态监控
+type Monitor struct {
+	cfg      Config
+	deviceID string
+
+	candidate string    // 等待确认的状态
+	count     int       // 等待确认的状态已连续出现的次数
+	stable    *Snapshot // 已确认的状态
+	alerted   *Snapshot // 最近一次告警（或启动时）的状态
+	lastAlert time.Time
+}
+
+// NewMonitor 创建调制解调器状态监控
+// 参数:
+//   - cfg: 告警配置
+//   - deviceID: 设备标识，显示在告警中
+func NewMonitor(cfg Config, deviceID string) *Monitor {
+	if cfg.ConfirmChecks == 0 {
+		cfg.ConfirmChecks = 2
+	}
+	if cfg.Cooldown == 0 {
+		cfg.Cooldown = 10
+	}
+	if deviceID == "" {
+		deviceID = "sim-sms-forward"
+	}
+	return &Monitor{cfg: cfg, deviceID: deviceID}
+}
+
+// Observe 记录一次检查的结果，需要告警时返回告警通知
+// 启动后第一次确认的状态正常时不告警，异常时告警
+// 参数:
+//   - status: 调制解调器状态，获取失败时为 nil
+//   - err: 获取状态失败的原因
+//   - now: 检查时间
+//
+// 返回: 告警通知，不需要告警时为 nil
+func (m *Monitor) Observe(status *types.ModemStatus, err error, now time.Time) *types.SMS {
+	snap := m.classify(status, err, now)
+	if snap.Condition == m.candidate {
+		m.count++
+	} else {
+		m.candidate = snap.Condition
+		m.count = 1
+	}
+	if m.count < m.cfg.ConfirmChecks {
+		return nil
+	}
+	m.stable = &snap
+
+	if m.alerted == nil {
+		m.alerted = m.stable
+		if snap.Condition == ConditionOK {
+			return nil
+		}
+		m.lastAlert = now
+		return m.message(nil, m.stable)
+	}
+	if m.alerted.Condition == snap.Condition {
+		return nil
+	}
+	// 冷却时间内的变化暂不告警，冷却结束时状态仍与上次告警不同才告警
+	if now.Sub(m.lastAlert) < time.Duration(m.cfg.Cooldown)*time.Minute {
+		return nil
+	}
+	previous := m.alerted
+	m.alerted = m.stable
+	m.lastAlert = now
+	return m.message(previous, m.stable)
+}
+
+// classify 根据调制解调器状态判断所处的状态分类
+func (m *Monitor) classify(status *types.ModemStatus, err error, now time.Time) Snapshot {
+	if status == nil {
+		snap := Snapshot{Condition: ConditionModemMissing, State: "unavailable", Time: now}
+		if err != nil {
+			snap.Error = err.Error()
+		}
+		return snap
+	}
+	snap := Snapshot{
+		State:         status.State,
+		FailedReason:  status.FailedReason,
+		Registration:  status.RegistrationState,
+		Operator:      status.OperatorName,
+		SignalQuality: status.SignalQuality,
+		Time:          now,
+	}
+	switch {
+	case status.FailedReason == "sim-missing" || (status.SIMPath == "" && status.State != "locked"):
+		snap.Condition = ConditionSIMMissing
+	case status.State == "locked":
+		snap.Condition = ConditionSIMLocked
+	case status.State == "failed":
+		snap.Condition = ConditionFailed
+	case status.RegistrationState != "home" && status.RegistrationState != "roaming":
+		snap.Condition = ConditionUnregistered
+	case m.weakSignal(status.SignalQuality):
+		snap.Condition = ConditionWeakSignal
+	case status.RegistrationState == "roaming":
+		snap.Condition = ConditionRoaming
+	default:
+		snap.Condition = ConditionOK
+	}
+	return snap
+}
+
+// weakSignal 判断信号是否弱，已经处于信号弱状态时需要恢复到阈值以上一定幅度才算恢复
+func (m *Monitor) weakSignal(quality int) bool {
+	if m.cfg.WeakSignal == 0 {
+		return false
+	}
+	threshold := m.cfg.WeakSignal
+	if m.candidate == ConditionWeakSignal {
+		threshold += weakSignalMargin
+	}
+	return quality < threshold
+}
+
+// message 生成告警通知
+// 参数:
+//   - previous: 上次告警时的状态，启动后第一次告警时为 nil
+//   - current: 当前状态
+func (m *Monitor) message(previous, current *Snapshot) *types.SMS {
+	var body strings.Builder
+	fmt.Fprintf(&body, "设备: %s\n", m.deviceID)
+	if current.Error != "" {
+		fmt.Fprintf(&body, "错误: %s\n", current.Error)
+	} else {
+		fmt.Fprintf(&body, "状态: %s", current.State)
+		if current.FailedReason != "" {
+			fmt.Fprintf(&body, "（%s）", current.FailedReason)
+		}
+		fmt.Fprintf(&body, "\n网络注册: %s\n", orDash(current.Registration))
+		fmt.Fprintf(&body, "运营商: %s\n", orDash(current.Operator))
+		fmt.Fprintf(&body, "信号: %d%%\n", current.SignalQuality)
+	}
+	if previous != nil {
+		fmt.Fprintf(&body, "之前: %s（%s 起）", labels[previous.Condition], previous.Time.Format("01-02 15:04"))
+	}
+
+	priority := types.PriorityHigh
+	if current.Condition == ConditionOK || current.Condition == ConditionRoaming || current.Condition == ConditionWeakSignal {
+		priority = types.PriorityNormal
+	}
+	return &types.SMS{
+		ID:        fmt.Sprintf("alert-%d", current.Time.Unix()),
+		Sender:    "modem",
+		Timestamp: current.Time.Format(time.RFC3339),
+		Content:   strings.TrimRight(body.String(), "\n"),
+		Priority:  priority,
+		Title:     titles[current.Condition],
+		Body:      strings.TrimRight(body.String(), "\n"),
+	}
+}
+
+// orDash 空字符串显示为 -
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
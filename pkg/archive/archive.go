@@ -0,0 +1,416 @@
+// Package archive 提供本地短信归档和搜索
+// 每条处理过的短信都以 JSON 行的形式追加到归档文件，不依赖 SQLite 等外部数据库，保持程序为单个无依赖的可执行文件
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sim-sms-forward/pkg/contacts"
+	"sim-sms-forward/pkg/types"
+	"sim-sms-forward/pkg/vault"
+)
+
+// Config 归档配置
+type Config struct {
+	Disable bool   `json:"disable,omitempty"` // 是否关闭短信归档
+	File    string `json:"file,omitempty"`    // 归档文件路径，默认为数据目录下的 archive.jsonl
+}
+
+// 归档记录的标签
+const (
+	TagOTP     = "otp"     // 包含验证码
+	TagSpam    = "spam"    // 被判定为垃圾短信
+	TagDropped = "dropped" // 被路由规则丢弃
+)
+
+// Delivery 一个通知渠道的投递结果
+type Delivery struct {
+	Channel string    `json:"channel"`          // 通知渠道名称
+	OK      bool      `json:"ok"`               // 是否成功
+	Error   string    `json:"error,omitempty"`  // 失败原因
+	At      time.Time `json:"at"`               // 投递时间
+	Replay  bool      `json:"replay,omitempty"` // 是否为重新发送
+}
+
+// Record 一条归档的短信
+type Record struct {
+	ID         int64                `json:"id"`                    // 归档序号
+	ArchivedAt time.Time            `json:"archived_at"`           // 归档时间
+	ReceivedAt time.Time            `json:"received_at"`           // 接收时间，由短信时间戳解析，解析失败时为归档时间
+	SMSID      string               `json:"sms_id"`                // 短信在调制解调器上的ID
+	Sender     string               `json:"sender"`                // 发送方号码
+	SenderName string               `json:"sender_name,omitempty"` // 通讯录名称
+	Timestamp  string               `json:"timestamp"`             // 调制解调器报告的原始时间戳
+	Content    string               `json:"content"`               // 短信内容
+	ModemID    string               `json:"modem_id"`              // 调制解调器ID
+	SIM        string               `json:"sim,omitempty"`         // SIM 卡 ICCID
+	Code       string               `json:"code,omitempty"`        // 验证码
+	Location   types.NumberLocation `json:"location"`              // 发送方归属地和运营商
+	Priority   string               `json:"priority"`              // 推送优先级
+	Tags       []string             `json:"tags,omitempty"`        // 标签: otp、spam、dropped
+	Rules      []string             `json:"rules,omitempty"`       // 命中的路由规则
+	Deliveries []Delivery           `json:"deliveries,omitempty"`  // 各通知渠道的投递结果
+}
+
+// NewRecord 根据处理后的短信创建归档记录
+// 参数:
+//   - sms: 已提取元数据并应用路由规则的短信
+//   - matched: 命中的路由规则名称
+//   - dropped: 是否被路由规则丢弃
+//
+// 返回: 尚未分配序号的归档记录
+func NewRecord(sms *types.SMS, matched []string, dropped bool) *Record {
+	now := time.Now()
+	r := &Record{
+		ArchivedAt: now,
+		ReceivedAt: ParseTimestamp(sms.Timestamp, now),
+		SMSID:      sms.ID,
+		Sender:     sms.Sender,
+		SenderName: sms.SenderName,
+		Timestamp:  sms.Timestamp,
+		Content:    sms.Content,
+		ModemID:    sms.ModemID,
+		SIM:        sms.SIM,
+		Code:       sms.Code,
+		Location:   sms.Location,
+		Priority:   sms.Priority.String(),
+		Rules:      matched,
+	}
+	if sms.Code != "" {
+		r.Tags = append(r.Tags, TagOTP)
+	}
+	if sms.Spam {
+		r.Tags = append(r.Tags, TagSpam)
+	}
+	if dropped {
+		r.Tags = append(r.Tags, TagDropped)
+	}
+	return r
+}
+
+// AddDelivery 记录一个通知渠道的投递结果
+func (r *Record) AddDelivery(channel string, err error) {
+	d := Delivery{Channel: channel, OK: err == nil, At: time.Now()}
+	if err != nil {
+		d.Error = err.Error()
+	}
+	r.Deliveries = append(r.Deliveries, d)
+}
+
+// AddReplay 记录一次重新发送的投递结果
+func (r *Record) AddReplay(channel string, err error) {
+	r.AddDelivery(channel, err)
+	r.Deliveries[len(r.Deliveries)-1].Replay = true
+}
+
+// SMS 将归档记录还原为短信，用于重新发送
+// 只还原调制解调器提供的原始字段和设备信息，验证码、优先级等元数据需要重新提取
+func (r *Record) SMS() *types.SMS {
+	return &types.SMS{
+		ID:         r.SMSID,
+		Sender:     r.Sender,
+		Timestamp:  r.Timestamp,
+		Content:    r.Content,
+		SenderName: r.SenderName,
+		ModemID:    r.ModemID,
+		SIM:        r.SIM,
+		Location:   r.Location,
+		Spam:       r.HasTag(TagSpam),
+	}
+}
+
+// HasTag 判断记录是否带有指定标签
+func (r *Record) HasTag(tag string) bool {
+	for _, t := range r.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// key 同一条短信重试处理时的标识，用于覆盖之前的记录
+func (r *Record) key() string {
+	return r.ModemID + "\x00" + r.SMSID + "\x00" + r.Sender + "\x00" + r.Timestamp
+}
+
+// ParseTimestamp 解析 mmcli 报告的短信时间戳，如 2024-01-15T10:30:00+08:00
+// 参数:
+//   - timestamp: 原始时间戳
+//   - fallback: 无法解析时返回的时间
+func ParseTimestamp(timestamp string, fallback time.Time) time.Time {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05-07", "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, timestamp, time.Local); err == nil {
+			return t
+		}
+	}
+	return fallback
+}
+
+// Archive 短信归档
+// 记录只追加不修改，同一条短信重新处理时追加一条相同序号的新记录，读取时以最后一条为准
+// 运行中的服务和 import 命令可能同时写入，文件大小与上次写入后不同时重新读取序号
+type Archive struct {
+	path string
+	key  *vault.Key // 加密密钥，未配置加密时为 nil
+
+	mu     sync.Mutex
+	nextID int64
+	ids    map[string]int64 // 短信标识到归档序号，用于重试时覆盖记录
+	size   int64            // 上次读取或写入后的文件大小
+}
+
+// Open 打开归档，读取已有记录的序号
+// 参数:
+//   - cfg: 归档配置
+//   - dataDir: 数据目录，未配置 file 时归档文件为 archive.jsonl
+//   - key: 加密密钥，为 nil 时不加密
+//
+// 返回: 归档和可能的错误，归档中有无法用 key 解密的记录时返回错误
+func Open(cfg Config, dataDir string, key *vault.Key) (*Archive, error) {
+	path := cfg.File
+	if path == "" {
+		path = filepath.Join(dataDir, "archive.jsonl")
+	}
+	a := &Archive{path: path, key: key}
+	if err := a.load(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// load 读取已有记录的序号，调用方需持有锁或在初始化时调用
+func (a *Archive) load() error {
+	a.nextID = 1
+	a.ids = make(map[string]int64)
+	a.size = 0
+	if info, err := os.Stat(a.path); err == nil {
+		a.size = info.Size()
+	}
+	return a.scan(func(r *Record) {
+		if r.ID >= a.nextID {
+			a.nextID = r.ID + 1
+		}
+		a.ids[r.key()] = r.ID
+	})
+}
+
+// Path 返回归档文件路径
+func (a *Archive) Path() string {
+	return a.path
+}
+
+// Put 保存归档记录
+// 记录没有序号时分配新序号，同一条短信已归档过时沿用原序号，读取时新记录覆盖旧记录
+func (a *Archive) Put(r *Record) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if info, err := os.Stat(a.path); err == nil && info.Size() != a.size {
+		if err := a.load(); err != nil {
+			return err
+		}
+	}
+	if r.ID == 0 {
+		if id, ok := a.ids[r.key()]; ok {
+			r.ID = id
+		} else {
+			r.ID = a.nextID
+			a.nextID++
+		}
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("序列化归档记录失败: %v", err)
+	}
+	data = vault.Encode(a.key, data)
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return fmt.Errorf("创建数据目录失败: %v", err)
+	}
+	file, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("打开短信归档失败: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入短信归档失败: %v", err)
+	}
+	if info, err := file.Stat(); err == nil {
+		a.size = info.Size()
+	}
+	a.ids[r.key()] = r.ID
+	return nil
+}
+
+// Query 搜索条件，未设置的条件不参与过滤
+type Query struct {
+	Text   string    // 全文搜索，空格分隔多个词，全部出现在内容、发送方、名称、验证码或归属地中才匹配，不区分大小写
+	Sender string    // 发送方号码或通讯录名称，号码以 * 结尾表示前缀匹配
+	Tag    string    // 标签，如 otp、spam
+	Since  time.Time // 接收时间不早于
+	Until  time.Time // 接收时间早于
+	Limit  int       // 最多返回的条数，0 表示不限
+	IDs    []int64   // 归档序号
+
+	Ascending bool // 按接收时间从旧到新排列，默认从新到旧
+}
+
+// Search 按条件搜索归档，默认按接收时间从新到旧返回
+// 参数: q - 搜索条件
+// 返回: 匹配的记录和可能的错误
+func (a *Archive) Search(q Query) ([]*Record, error) {
+	terms := strings.Fields(strings.ToLower(q.Text))
+	matched := make(map[int64]*Record)
+	err := a.scan(func(r *Record) {
+		// 同一序号的记录以最后一条为准，旧记录匹配而新记录不匹配时需要删除
+		if q.match(r, terms) {
+			matched[r.ID] = r
+		} else {
+			delete(matched, r.ID)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*Record, 0, len(matched))
+	for _, r := range matched {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		newer := records[i].ReceivedAt.After(records[j].ReceivedAt)
+		if records[i].ReceivedAt.Equal(records[j].ReceivedAt) {
+			newer = records[i].ID > records[j].ID
+		}
+		return newer != q.Ascending
+	})
+	if q.Limit > 0 && len(records) > q.Limit {
+		records = records[:q.Limit]
+	}
+	return records, nil
+}
+
+// match 判断记录是否满足搜索条件，terms 为小写的搜索词
+func (q Query) match(r *Record, terms []string) bool {
+	if !q.Since.IsZero() && r.ReceivedAt.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !r.ReceivedAt.Before(q.Until) {
+		return false
+	}
+	if len(q.IDs) > 0 && !slices.Contains(q.IDs, r.ID) {
+		return false
+	}
+	if q.Tag != "" && !r.HasTag(q.Tag) {
+		return false
+	}
+	if q.Sender != "" && !matchSender(q.Sender, r) {
+		return false
+	}
+	if len(terms) > 0 {
+		text := strings.ToLower(strings.Join([]string{r.Content, r.Sender, r.SenderName, r.Code, r.Location.String()}, "\n"))
+		for _, term := range terms {
+			if !strings.Contains(text, term) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchSender 按号码（忽略 +86 等前缀）或通讯录名称匹配发送方
+func matchSender(pattern string, r *Record) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(contacts.Normalize(r.Sender), contacts.Normalize(prefix)) || strings.HasPrefix(r.SenderName, prefix)
+	}
+	return contacts.Normalize(r.Sender) == contacts.Normalize(pattern) || r.SenderName == pattern
+}
+
+// Rekey 用新密钥重新加密整个归档，先写入临时文件再替换，newKey 为 nil 时解密为明文
+// 调用期间不能有其他进程写入归档
+func (a *Archive) Rekey(newKey *vault.Key) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := os.Stat(a.path); os.IsNotExist(err) {
+		a.key = newKey
+		return nil
+	}
+
+	tmp := a.path + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("创建临时归档失败: %v", err)
+	}
+	writer := bufio.NewWriter(out)
+	err = a.scanLines(func(line []byte) error {
+		writer.Write(vault.Encode(newKey, line))
+		return writer.WriteByte('\n')
+	})
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := out.Close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("重新加密归档失败: %v", err)
+	}
+	if err := os.Rename(tmp, a.path); err != nil {
+		return fmt.Errorf("替换归档文件失败: %v", err)
+	}
+	a.key = newKey
+	return a.load()
+}
+
+// scan 按写入顺序读取所有记录，无法解析的行跳过，无法解密时返回错误
+func (a *Archive) scan(fn func(r *Record)) error {
+	return a.scanLines(func(line []byte) error {
+		var r Record
+		if err := json.Unmarshal(line, &r); err == nil {
+			fn(&r)
+		}
+		return nil
+	})
+}
+
+// scanLines 按写入顺序读取所有记录解密后的 JSON
+func (a *Archive) scanLines(fn func(line []byte) error) error {
+	file, err := os.Open(a.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("打开短信归档失败: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		data, err := vault.Decode(a.key, scanner.Bytes())
+		if err != nil {
+			return fmt.Errorf("短信归档 %s 第 %d 行: %v", a.path, line, err)
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取短信归档失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package archive
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 导出和导入支持的格式
+const (
+	FormatJSONL = "jsonl" // JSON 行，每行一条完整的归档记录
+	FormatCSV   = "csv"   // CSV 表格，带表头
+	FormatXML   = "xml"   // Android "SMS Backup & Restore" 备份格式
+	FormatLog   = "log"   // sms-forward-YYYY-MM-DD.log 日志文件，只能导入
+)
+
+// csvHeader CSV 格式的表头
+var csvHeader = []string{"id", "received_at", "sender", "sender_name", "content", "timestamp", "modem_id", "sim", "code", "location", "priority", "tags", "rules", "deliveries"}
+
+// FormatFromPath 根据文件扩展名推断格式
+// 参数: path - 文件路径
+// 返回: 格式名称，无法识别时返回空字符串
+func FormatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".json", ".ndjson":
+		return FormatJSONL
+	case ".csv":
+		return FormatCSV
+	case ".xml":
+		return FormatXML
+	case ".log":
+		return FormatLog
+	default:
+		return ""
+	}
+}
+
+// Export 按指定格式导出归档记录
+// 参数:
+//   - w: 输出
+//   - format: 格式: jsonl、csv、xml
+//   - records: 要导出的记录
+func Export(w io.Writer, format string, records []*Record) error {
+	switch format {
+	case FormatJSONL:
+		encoder := json.NewEncoder(w)
+		for _, r := range records {
+			if err := encoder.Encode(r); err != nil {
+				return fmt.Errorf("导出 JSON 失败: %v", err)
+			}
+		}
+		return nil
+	case FormatCSV:
+		return exportCSV(w, records)
+	case FormatXML:
+		return exportXML(w, records)
+	default:
+		return fmt.Errorf("不支持导出为 %s 格式，可选 jsonl、csv、xml", format)
+	}
+}
+
+// exportCSV 导出为带表头的 CSV，标签、规则和投递结果用分号分隔
+func exportCSV(w io.Writer, records []*Record) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return fmt.Errorf("导出 CSV 失败: %v", err)
+	}
+	for _, r := range records {
+		deliveries := make([]string, 0, len(r.Deliveries))
+		for _, d := range r.Deliveries {
+			if d.OK {
+				deliveries = append(deliveries, d.Channel+":ok")
+			} else {
+				deliveries = append(deliveries, d.Channel+":failed")
+			}
+		}
+		row := []string{
+			strconv.FormatInt(r.ID, 10),
+			r.ReceivedAt.Format("2006-01-02T15:04:05Z07:00"),
+			r.Sender,
+			r.SenderName,
+			r.Content,
+			r.Timestamp,
+			r.ModemID,
+			r.SIM,
+			r.Code,
+			r.Location.String(),
+			r.Priority,
+			strings.Join(r.Tags, ";"),
+			strings.Join(r.Rules, ";"),
+			strings.Join(deliveries, ";"),
+		}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("导出 CSV 失败: %v", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("导出 CSV 失败: %v", err)
+	}
+	return nil
+}
+
+// backupSMSes SMS Backup & Restore 备份文件的根元素
+type backupSMSes struct {
+	XMLName xml.Name    `xml:"smses"`
+	Count   int         `xml:"count,attr"`
+	SMS     []backupSMS `xml:"sms"`
+}
+
+// backupSMS SMS Backup & Restore 备份文件中的一条短信
+// 只列出恢复时需要的属性，其他属性导出为 "null" 或默认值
+type backupSMS struct {
+	Protocol      string `xml:"protocol,attr"`
+	Address       string `xml:"address,attr"`
+	Date          int64  `xml:"date,attr"` // 接收时间，毫秒时间戳
+	Type          int    `xml:"type,attr"` // 1 收件箱，2 已发送
+	Subject       string `xml:"subject,attr"`
+	Body          string `xml:"body,attr"`
+	Toa           string `xml:"toa,attr"`
+	ScToa         string `xml:"sc_toa,attr"`
+	ServiceCenter string `xml:"service_center,attr"`
+	Read          int    `xml:"read,attr"`
+	Status        int    `xml:"status,attr"`
+	Locked        int    `xml:"locked,attr"`
+	DateSent      int64  `xml:"date_sent,attr"`
+	ReadableDate  string `xml:"readable_date,attr,omitempty"`
+	ContactName   string `xml:"contact_name,attr,omitempty"`
+}
+
+// backupTypeInbox SMS Backup & Restore 中收件箱短信的 type
+const backupTypeInbox = 1
+
+// exportXML 导出为 SMS Backup & Restore 格式，所有短信都作为已读的收件箱短信
+func exportXML(w io.Writer, records []*Record) error {
+	backup := backupSMSes{Count: len(records)}
+	for _, r := range records {
+		contactName := r.SenderName
+		if contactName == "" {
+			contactName = "(Unknown)"
+		}
+		backup.SMS = append(backup.SMS, backupSMS{
+			Protocol:      "0",
+			Address:       r.Sender,
+			Date:          r.ReceivedAt.UnixMilli(),
+			Type:          backupTypeInbox,
+			Subject:       "null",
+			Body:          r.Content,
+			Toa:           "null",
+			ScToa:         "null",
+			ServiceCenter: "null",
+			Read:          1,
+			Status:        -1,
+			DateSent:      r.ReceivedAt.UnixMilli(),
+			ReadableDate:  r.ReceivedAt.Format("2006年1月2日 15:04:05"),
+			ContactName:   contactName,
+		})
+	}
+
+	if _, err := io.WriteString(w, "<?xml version='1.0' encoding='UTF-8' standalone='yes' ?>\n"); err != nil {
+		return fmt.Errorf("导出 XML 失败: %v", err)
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(backup); err != nil {
+		return fmt.Errorf("导出 XML 失败: %v", err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("导出 XML 失败: %v", err)
+	}
+	return nil
+}
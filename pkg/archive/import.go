@@ -0,0 +1,302 @@
+package archive
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"sim-sms-forward/pkg/contacts"
+)
+
+// TagImported 从备份或日志导入的记录的标签
+const TagImported = "imported"
+
+// Parse 按指定格式解析要导入的短信
+// 参数:
+//   - r: 输入
+//   - format: 格式: jsonl、csv、xml、log
+//
+// 返回: 没有分配序号的记录和可能的错误
+func Parse(r io.Reader, format string) ([]*Record, error) {
+	switch format {
+	case FormatJSONL:
+		return parseJSONL(r)
+	case FormatCSV:
+		return parseCSV(r)
+	case FormatXML:
+		return parseXML(r)
+	case FormatLog:
+		return ParseLog(r)
+	default:
+		return nil, fmt.Errorf("不支持导入 %s 格式，可选 jsonl、csv、xml、log", format)
+	}
+}
+
+// ParseFile 解析要导入的文件，format 为空时根据扩展名推断
+func ParseFile(path, format string) ([]*Record, error) {
+	if format == "" {
+		format = FormatFromPath(path)
+		if format == "" {
+			return nil, fmt.Errorf("无法根据扩展名识别 %s 的格式，请指定 -format", filepath.Base(path))
+		}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开导入文件失败: %v", err)
+	}
+	defer file.Close()
+	records, err := Parse(file, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Base(path), err)
+	}
+	return records, nil
+}
+
+// Import 导入记录，已经存在的短信（发送方、接收时间和内容都相同）跳过
+// 参数: records - 要导入的记录，原有的序号会被忽略
+// 返回: 新增和跳过的条数以及可能的错误
+func (a *Archive) Import(records []*Record) (added, skipped int, err error) {
+	seen := make(map[string]bool)
+	if err := a.scan(func(r *Record) { seen[r.contentKey()] = true }); err != nil {
+		return 0, 0, err
+	}
+
+	now := time.Now()
+	for _, r := range records {
+		key := r.contentKey()
+		if seen[key] {
+			skipped++
+			continue
+		}
+		seen[key] = true
+
+		r.ID = 0
+		if r.ArchivedAt.IsZero() {
+			r.ArchivedAt = now
+		}
+		if r.ReceivedAt.IsZero() {
+			r.ReceivedAt = ParseTimestamp(r.Timestamp, r.ArchivedAt)
+		}
+		if r.Priority == "" {
+			r.Priority = "normal"
+		}
+		if !r.HasTag(TagImported) {
+			r.Tags = append(r.Tags, TagImported)
+		}
+		if err := a.Put(r); err != nil {
+			return added, skipped, err
+		}
+		added++
+	}
+	return added, skipped, nil
+}
+
+// contentKey 导入时判断短信是否重复的标识，不同来源的时间戳格式不同，按秒比较接收时间
+func (r *Record) contentKey() string {
+	return fmt.Sprintf("%s\x00%d\x00%s", contacts.Normalize(r.Sender), r.ReceivedAt.Unix(), r.Content)
+}
+
+// parseJSONL 解析 export 导出的 JSON 行
+func parseJSONL(r io.Reader) ([]*Record, error) {
+	var records []*Record
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("第 %d 行解析失败: %v", line, err)
+		}
+		records = append(records, &record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取失败: %v", err)
+	}
+	return records, nil
+}
+
+// parseCSV 解析带表头的 CSV，至少需要 sender 和 content 列，其他列按 export 的表头识别
+func parseCSV(r io.Reader) ([]*Record, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("解析 CSV 失败: %v", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["sender"]; !ok {
+		return nil, fmt.Errorf("CSV 缺少 sender 列")
+	}
+	if _, ok := columns["content"]; !ok {
+		return nil, fmt.Errorf("CSV 缺少 content 列")
+	}
+	get := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	var records []*Record
+	for _, row := range rows[1:] {
+		record := &Record{
+			Sender:     get(row, "sender"),
+			SenderName: get(row, "sender_name"),
+			Content:    get(row, "content"),
+			Timestamp:  get(row, "timestamp"),
+			ModemID:    get(row, "modem_id"),
+			SIM:        get(row, "sim"),
+			Code:       get(row, "code"),
+			Priority:   get(row, "priority"),
+		}
+		if receivedAt := get(row, "received_at"); receivedAt != "" {
+			record.ReceivedAt = ParseTimestamp(receivedAt, time.Time{})
+		}
+		for _, field := range []struct {
+			name string
+			list *[]string
+		}{{"tags", &record.Tags}, {"rules", &record.Rules}} {
+			if value := get(row, field.name); value != "" {
+				*field.list = strings.Split(value, ";")
+			}
+		}
+		if record.Sender == "" && record.Content == "" {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// parseXML 解析 SMS Backup & Restore 备份文件，只导入收件箱中的短信
+func parseXML(r io.Reader) ([]*Record, error) {
+	var backup backupSMSes
+	if err := xml.NewDecoder(r).Decode(&backup); err != nil {
+		return nil, fmt.Errorf("解析 XML 失败: %v", err)
+	}
+	var records []*Record
+	for _, sms := range backup.SMS {
+		if sms.Type != backupTypeInbox {
+			continue
+		}
+		receivedAt := time.UnixMilli(sms.Date)
+		record := &Record{
+			ReceivedAt: receivedAt,
+			Sender:     sms.Address,
+			Timestamp:  receivedAt.Format(time.RFC3339),
+			Content:    sms.Body,
+		}
+		if sms.ContactName != "" && sms.ContactName != "(Unknown)" {
+			record.SenderName = sms.ContactName
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+var (
+	// logLineRegex 日志行: [INFO] 2024/01/15 10:30:00 processor.go:215: 消息
+	logLineRegex = regexp.MustCompile(`^\[\w+\] (\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}) \S+?:\d+: (.*)$`)
+	// logModemRegex 处理短信前记录的调制解调器ID
+	logModemRegex = regexp.MustCompile(`^开始处理调制解调器 (\S+) 上的所有短信`)
+	// logPrivacyRegex 程序启动时记录的日志隐私级别
+	logPrivacyRegex = regexp.MustCompile(`^日志隐私级别: (\w+)`)
+	// logMaskedRegex masked 级别下被截断的短信内容结尾
+	logMaskedRegex = regexp.MustCompile(`…（共 \d+ 字）$`)
+)
+
+// ParseLog 从 sms-forward-YYYY-MM-DD.log 日志中解析处理过的短信
+// 每条短信由 "短信 ID"、"发送方号码"、"接收时间"、"短信内容" 四行组成，多行的短信内容
+// 从 "短信内容" 一行延续到下一条带时间的日志行。日志隐私级别不是 full 时号码和内容不完整，
+// 这样的短信跳过：按启动时记录的隐私级别判断，没有记录时按被隐藏的号码和截断的内容判断
+func ParseLog(r io.Reader) ([]*Record, error) {
+	var records []*Record
+	var current *Record
+	content := false // current 的短信内容是否还可能有后续行
+	modemID := ""
+	privacy := "full"
+
+	finish := func() {
+		if current != nil && content && privacy == "full" && !maskedLog(current) {
+			current.ReceivedAt = ParseTimestamp(current.Timestamp, current.ArchivedAt)
+			records = append(records, current)
+		}
+		if content {
+			current, content = nil, false
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		match := logLineRegex.FindStringSubmatch(line)
+		if match == nil {
+			if content {
+				current.Content += "\n" + line
+			}
+			continue
+		}
+		finish()
+		message := match[2]
+		if m := logPrivacyRegex.FindStringSubmatch(message); m != nil {
+			privacy = m[1]
+			continue
+		}
+		if m := logModemRegex.FindStringSubmatch(message); m != nil {
+			modemID = m[1]
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(message, "短信 ID: "):
+			logTime, _ := time.ParseInLocation("2006/01/02 15:04:05", match[1], time.FixedZone("CST", 8*3600))
+			current = &Record{
+				ArchivedAt: logTime,
+				SMSID:      strings.TrimPrefix(message, "短信 ID: "),
+				ModemID:    modemID,
+			}
+		case current == nil:
+		case strings.HasPrefix(message, "发送方号码: "):
+			current.Sender = strings.TrimPrefix(message, "发送方号码: ")
+		case strings.HasPrefix(message, "接收时间: "):
+			current.Timestamp = strings.TrimPrefix(message, "接收时间: ")
+		case strings.HasPrefix(message, "短信内容: "):
+			current.Content = strings.TrimPrefix(message, "短信内容: ")
+			content = true
+		}
+	}
+	finish()
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取日志失败: %v", err)
+	}
+	return records, nil
+}
+
+// maskedLog 判断没有记录隐私级别的日志中，短信的号码或内容是否被隐藏
+// masked 级别下长号码中间替换为 *，超过 10 字的内容被截断；none 级别下号码和内容都显示为 [已隐藏]
+func maskedLog(record *Record) bool {
+	return strings.HasPrefix(record.Content, "[已隐藏") ||
+		strings.HasPrefix(record.Sender, "[已隐藏") ||
+		strings.Contains(record.Sender, "*") ||
+		logMaskedRegex.MatchString(record.Content)
+}
@@ -0,0 +1,71 @@
+package archive
+
+import (
+	"strings"
+	"testing"
+)
+
+const testLog = `[INFO] 2024/01/15 10:29:58 main.go:106: 日志隐私级别: full
+[INFO] 2024/01/15 10:30:00 processor.go:436: 开始处理调制解调器 0 上的所有短信
+[INFO] 2024/01/15 10:30:01 processor.go:321: ======================================
+[INFO] 2024/01/15 10:30:01 processor.go:322: 短信 ID: 7
+[INFO] 2024/01/15 10:30:01 processor.go:323: 发送方号码: 95588
+[INFO] 2024/01/15 10:30:01 processor.go:324: 接收时间: 2024-01-15T10:29:50+08:00
+[INFO] 2024/01/15 10:30:01 processor.go:325: 短信内容: 【工商银行】您尾号1234的卡
+支出 100.00 元
+
+余额 900.00 元
+[INFO] 2024/01/15 10:30:01 processor.go:326: ======================================
+[INFO] 2024/01/15 10:30:05 processor.go:322: 短信 ID: 8
+[INFO] 2024/01/15 10:30:05 processor.go:323: 发送方号码: 10086
+[INFO] 2024/01/15 10:30:05 processor.go:324: 接收时间: 2024-01-15T10:30:00+08:00
+[INFO] 2024/01/15 10:30:05 processor.go:325: 短信内容: 单行短信
+`
+
+func TestParseLog(t *testing.T) {
+	records, err := ParseLog(strings.NewReader(testLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("解析出 %d 条短信，期望 2", len(records))
+	}
+	want := "【工商银行】您尾号1234的卡\n支出 100.00 元\n\n余额 900.00 元"
+	if records[0].Content != want {
+		t.Errorf("多行内容为 %q，期望 %q", records[0].Content, want)
+	}
+	if records[0].ModemID != "0" || records[0].Sender != "95588" {
+		t.Errorf("记录不正确: %+v", records[0])
+	}
+	if records[1].Content != "单行短信" {
+		t.Errorf("最后一条短信内容为 %q", records[1].Content)
+	}
+}
+
+func TestParseLogMasked(t *testing.T) {
+	masked := `[INFO] 2024/01/15 10:29:58 main.go:106: 日志隐私级别: masked
+[INFO] 2024/01/15 10:30:01 processor.go:322: 短信 ID: 7
+[INFO] 2024/01/15 10:30:01 processor.go:323: 发送方号码: 95588
+[INFO] 2024/01/15 10:30:01 processor.go:325: 短信内容: 验证码****
+`
+	// 没有记录隐私级别的旧日志按号码和内容判断
+	legacy := `[INFO] 2024/01/15 10:30:01 processor.go:322: 短信 ID: 8
+[INFO] 2024/01/15 10:30:01 processor.go:323: 发送方号码: 138****5678
+[INFO] 2024/01/15 10:30:01 processor.go:325: 短信内容: 你好
+[INFO] 2024/01/15 10:30:02 processor.go:322: 短信 ID: 9
+[INFO] 2024/01/15 10:30:02 processor.go:323: 发送方号码: 95588
+[INFO] 2024/01/15 10:30:02 processor.go:325: 短信内容: 【工商银行】您尾号**…（共 42 字）
+[INFO] 2024/01/15 10:30:03 processor.go:322: 短信 ID: 10
+[INFO] 2024/01/15 10:30:03 processor.go:323: 发送方号码: [已隐藏]
+[INFO] 2024/01/15 10:30:03 processor.go:325: 短信内容: [已隐藏，共 12 字]
+`
+	for name, log := range map[string]string{"masked": masked, "legacy": legacy} {
+		records, err := ParseLog(strings.NewReader(log))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 0 {
+			t.Errorf("%s: 隐藏过的短信不应导入，解析出 %d 条", name, len(records))
+		}
+	}
+}
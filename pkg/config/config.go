@@ -6,6 +6,24 @@ import (
 	"fmt"
 	"os"
 	"time"
+
+	"sim-sms-forward/pkg/alerts"
+	"sim-sms-forward/pkg/archive"
+	"sim-sms-forward/pkg/contacts"
+	"sim-sms-forward/pkg/health"
+	"sim-sms-forward/pkg/heartbeat"
+	"sim-sms-forward/pkg/logger"
+	"sim-sms-forward/pkg/metrics"
+	"sim-sms-forward/pkg/modem"
+	"sim-sms-forward/pkg/mqtt"
+	"sim-sms-forward/pkg/notification"
+	"sim-sms-forward/pkg/numloc"
+	"sim-sms-forward/pkg/policy"
+	"sim-sms-forward/pkg/recovery"
+	"sim-sms-forward/pkg/rules"
+	"sim-sms-forward/pkg/spam"
+	"sim-sms-forward/pkg/vault"
+	"sim-sms-forward/pkg/web"
 )
 
 // Config 定义应用程序的配置结构
@@ -36,6 +54,78 @@ type Config struct {
 	
 	// SleepDuration 检查间隔时间（秒）
 	SleepDuration int `json:"sleep_duration"`
+
+	// LogPrivacy 日志隐私级别: full、masked、none，控制日志中电话号码和短信内容的详细程度
+	LogPrivacy string `json:"log_privacy,omitempty"`
+
+	// DataDir 数据目录，保存垃圾短信模型等运行数据，默认为程序所在目录下的 data
+	DataDir string `json:"data_dir,omitempty"`
+
+	// Webhooks 通用模板化 Webhook 通知列表
+	Webhooks []notification.WebhookConfig `json:"webhooks,omitempty"`
+
+	// Emails SMTP 邮件通知列表
+	Emails []notification.EmailConfig `json:"emails,omitempty"`
+
+	// Gotify 自建 Gotify 通知列表
+	Gotify []notification.GotifyConfig `json:"gotify,omitempty"`
+
+	// Ntfy ntfy 通知列表
+	Ntfy []notification.NtfyConfig `json:"ntfy,omitempty"`
+
+	// Pushover Pushover 通知列表
+	Pushover []notification.PushoverConfig `json:"pushover,omitempty"`
+
+	// Telegram Telegram 机器人通知列表
+	Telegram []notification.TelegramConfig `json:"telegram,omitempty"`
+
+	// NotifyURLs Apprise 风格的通知 URL 列表，每个 URL 对应一个通知渠道
+	NotifyURLs []string `json:"notify_urls,omitempty"`
+
+	// MQTT MQTT 发布和 Home Assistant 自动发现配置
+	MQTT mqtt.Config `json:"mqtt"`
+
+	// Rules 短信路由规则，按顺序匹配，未配置时所有短信发送到全部通知渠道
+	Rules []rules.Rule `json:"rules,omitempty"`
+
+	// Spam 垃圾短信和营销短信过滤配置
+	Spam spam.Config `json:"spam"`
+
+	// Contacts 通讯录配置，用于在通知中显示发送方名称
+	Contacts contacts.Config `json:"contacts"`
+
+	// Location 离线号码归属地查询配置
+	Location numloc.Config `json:"location"`
+
+	// Archive 本地短信归档配置
+	Archive archive.Config `json:"archive"`
+
+	// Encryption 归档和待发送队列的静态加密配置，未配置时明文保存
+	Encryption vault.Config `json:"encryption"`
+
+	// Web 内嵌的网页控制台配置
+	Web web.Config `json:"web"`
+
+	// Metrics Prometheus 监控端点配置
+	Metrics metrics.Config `json:"metrics"`
+
+	// Health 存活和就绪检查端点配置
+	Health health.Config `json:"health"`
+
+	// Heartbeat 心跳配置，用于在设备停止工作时让外部得知
+	Heartbeat heartbeat.Config `json:"heartbeat"`
+
+	// Alerts 调制解调器和 SIM 卡状态告警配置
+	Alerts alerts.Config `json:"alerts"`
+
+	// Storage 调制解调器短信存储检查和清理配置
+	Storage modem.StorageConfig `json:"storage"`
+
+	// Recovery 调制解调器持续不可用时的自动恢复配置
+	Recovery recovery.Config `json:"recovery"`
+
+	// Channels 按通知渠道名称配置的发送策略，如免打扰时段
+	Channels map[string]policy.ChannelConfig `json:"channels,omitempty"`
 }
 
 // DefaultConfig 返回默认配置
@@ -128,9 +218,181 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("休眠时间必须大于0秒")
 	}
 
+	if _, err := logger.ParsePrivacy(c.LogPrivacy); err != nil {
+		return err
+	}
+
+	if err := c.MQTT.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Spam.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Web.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Encryption.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Health.Validate(); err != nil {
+		return err
+	}
+	if c.Health.Enable && c.Health.Listen == "" && !c.Metrics.Enable {
+		return fmt.Errorf("启用健康检查时，需要配置 health.listen 或启用 metrics")
+	}
+
+	if err := c.Heartbeat.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Alerts.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Storage.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Recovery.Validate(); err != nil {
+		return err
+	}
+
+	// 验证通知渠道可以正常创建，并且名称不重复
+	notifiers, err := c.BuildNotifiers()
+	if err != nil {
+		return err
+	}
+
+	// 验证路由规则可以编译，并且引用的通知渠道都存在
+	engine, err := rules.Compile(c.Rules)
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool)
+	for _, n := range notifiers {
+		names[n.Name()] = true
+	}
+	if c.MQTT.Enable {
+		names["mqtt"] = true
+	}
+	for _, name := range engine.NotifierNames() {
+		if !names[name] {
+			return fmt.Errorf("路由规则引用了不存在的通知渠道: %s", name)
+		}
+	}
+
+	// 验证心跳引用的通知渠道存在，MQTT 不推送运行汇总
+	for _, name := range c.Heartbeat.Notifiers {
+		if !names[name] || name == "mqtt" {
+			return fmt.Errorf("heartbeat.notifiers 中引用了不存在或不支持的通知渠道: %s", name)
+		}
+	}
+	for _, name := range c.Alerts.Notifiers {
+		if !names[name] || name == "mqtt" {
+			return fmt.Errorf("alerts.notifiers 中引用了不存在或不支持的通知渠道: %s", name)
+		}
+	}
+	for _, name := range c.Storage.Notifiers {
+		if !names[name] || name == "mqtt" {
+			return fmt.Errorf("storage.notifiers 中引用了不存在或不支持的通知渠道: %s", name)
+		}
+	}
+	for _, name := range c.Recovery.Notifiers {
+		if !names[name] || name == "mqtt" {
+			return fmt.Errorf("recovery.notifiers 中引用了不存在或不支持的通知渠道: %s", name)
+		}
+	}
+
+	// 验证发送策略引用的通知渠道存在
+	for name, channel := range c.Channels {
+		if !names[name] {
+			return fmt.Errorf("channels 中引用了不存在的通知渠道: %s", name)
+		}
+		if err := channel.Validate(); err != nil {
+			return fmt.Errorf("channels.%s: %v", name, err)
+		}
+	}
+
 	return nil
 }
 
+// BuildNotifiers 根据配置创建所有启用的通知渠道
+// 返回: 按配置顺序排列的通知渠道列表和可能的错误
+func (c *Config) BuildNotifiers() ([]notification.Notifier, error) {
+	var notifiers []notification.Notifier
+
+	if c.EnableBark {
+		notifiers = append(notifiers, notification.NewBarkClient(c.BarkKey, c.BarkAPIURL))
+	}
+	if c.EnableHismsg {
+		notifiers = append(notifiers, notification.NewHismsgClient(c.HismsgKey, c.HismsgAPIURL, c.DeviceID))
+	}
+	for i, wc := range c.Webhooks {
+		client, err := notification.NewWebhookClient(wc)
+		if err != nil {
+			return nil, fmt.Errorf("webhooks[%d]: %v", i, err)
+		}
+		notifiers = append(notifiers, client)
+	}
+	for i, ec := range c.Emails {
+		client, err := notification.NewEmailClient(ec)
+		if err != nil {
+			return nil, fmt.Errorf("emails[%d]: %v", i, err)
+		}
+		notifiers = append(notifiers, client)
+	}
+	for i, gc := range c.Gotify {
+		client, err := notification.NewGotifyClient(gc)
+		if err != nil {
+			return nil, fmt.Errorf("gotify[%d]: %v", i, err)
+		}
+		notifiers = append(notifiers, client)
+	}
+	for i, nc := range c.Ntfy {
+		client, err := notification.NewNtfyClient(nc)
+		if err != nil {
+			return nil, fmt.Errorf("ntfy[%d]: %v", i, err)
+		}
+		notifiers = append(notifiers, client)
+	}
+	for i, pc := range c.Pushover {
+		client, err := notification.NewPushoverClient(pc)
+		if err != nil {
+			return nil, fmt.Errorf("pushover[%d]: %v", i, err)
+		}
+		notifiers = append(notifiers, client)
+	}
+	for i, tc := range c.Telegram {
+		client, err := notification.NewTelegramClient(tc)
+		if err != nil {
+			return nil, fmt.Errorf("telegram[%d]: %v", i, err)
+		}
+		notifiers = append(notifiers, client)
+	}
+	for i, raw := range c.NotifyURLs {
+		client, err := ParseNotifyURL(raw)
+		if err != nil {
+			return nil, fmt.Errorf("notify_urls[%d]: %v", i, err)
+		}
+		notifiers = append(notifiers, client)
+	}
+
+	// 渠道名称用于日志和路由，必须唯一
+	names := make(map[string]bool)
+	for _, n := range notifiers {
+		if names[n.Name()] {
+			return nil, fmt.Errorf("通知渠道名称重复: %s，请通过 name 配置不同的名称", n.Name())
+		}
+		names[n.Name()] = true
+	}
+
+	return notifiers, nil
+}
+
 // GetSleepDuration 返回休眠时间的Duration对象
 func (c *Config) GetSleepDuration() time.Duration {
 	return time.Duration(c.SleepDuration) * time.Second
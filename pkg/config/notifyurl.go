@@ -0,0 +1,488 @@
+// Package config 提供配置文件的解析和管理功能
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"sim-sms-forward/pkg/notification"
+)
+
+// NotifyURLError 通知 URL 解析错误
+// Part 指出 URL 中出错的部分（如 "scheme"、"host"、"查询参数 level"），便于定位问题
+type NotifyURLError struct {
+	URL  string // 脱敏后的 URL
+	Part string // 出错的部分
+	Msg  string // 错误说明
+}
+
+// Error 实现 error 接口
+func (e *NotifyURLError) Error() string {
+	return fmt.Sprintf("%s 的 %s 无效: %s", e.URL, e.Part, e.Msg)
+}
+
+// ParseNotifyURL 将 Apprise 风格的通知 URL 解析为通知渠道
+// 支持的格式:
+//   - bark://<key>@<host>[/path]?group=&level=&sound=&icon=      （HTTPS，bark+http:// 使用 HTTP）
+//   - hismsg://<key>@<host>[/path]?tags=短信,验证码&source=        （HTTPS，hismsg+http:// 使用 HTTP）
+//   - tgram://<bot_token>/<chat_id>[/<chat_id>...]?silent=yes
+//   - gotify://<host>[/path]/<token>?priority=5                  （HTTP，gotifys:// 使用 HTTPS）
+//   - ntfy://[user:pass@]<host>/<topic>?priority=&tags=&click=&token= （HTTP，ntfys:// 使用 HTTPS；ntfy://<topic> 使用 ntfy.sh）
+//   - pover://<user_key>@<app_token>[/<device>]?emergency_otp=yes&retry=&expire=&sound=
+//   - webhook+https://<host>/<path>?method=&content_type=&+Header=value&-field=template
+//
+// 所有格式都支持 name 参数设置渠道名称
+// 参数: raw - 通知 URL
+// 返回: 通知渠道和可能的错误
+func ParseNotifyURL(raw string) (notification.Notifier, error) {
+	raw = strings.TrimSpace(raw)
+	idx := strings.Index(raw, "://")
+	if idx <= 0 {
+		return nil, &NotifyURLError{URL: redactNotifyURL(raw), Part: "scheme", Msg: "缺少 <类型>:// 前缀"}
+	}
+	scheme := strings.ToLower(raw[:idx])
+
+	switch scheme {
+	case "tgram":
+		return parseTelegramURL(raw)
+	case "pover":
+		return parsePushoverURL(raw)
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, &NotifyURLError{URL: redactNotifyURL(raw), Part: "格式", Msg: err.Error()}
+	}
+	p := &urlParser{u: u, raw: raw, query: u.Query()}
+
+	switch scheme {
+	case "bark", "bark+http", "bark+https":
+		return p.bark()
+	case "hismsg", "hismsg+http", "hismsg+https":
+		return p.hismsg()
+	case "gotify", "gotifys":
+		return p.gotify()
+	case "ntfy", "ntfys":
+		return p.ntfy()
+	case "webhook+http", "webhook+https":
+		return p.webhook()
+	default:
+		return nil, p.errorf("scheme", "不支持的通知类型 %s，可选: bark、hismsg、tgram、gotify、ntfy、pover、webhook+https", scheme)
+	}
+}
+
+// urlParser 解析标准格式的通知 URL
+type urlParser struct {
+	u     *url.URL
+	raw   string
+	query url.Values
+}
+
+// errorf 构建指向 URL 某一部分的错误
+func (p *urlParser) errorf(part, format string, args ...interface{}) error {
+	return &NotifyURLError{URL: redactNotifyURL(p.raw), Part: part, Msg: fmt.Sprintf(format, args...)}
+}
+
+// httpScheme 根据通知类型后缀决定使用 http 还是 https
+// 参数: defaultScheme - 没有 +http/+https 后缀时使用的协议
+func (p *urlParser) httpScheme(defaultScheme string) string {
+	scheme := strings.ToLower(p.u.Scheme)
+	switch {
+	case strings.HasSuffix(scheme, "+http"):
+		return "http"
+	case strings.HasSuffix(scheme, "+https"):
+		return "https"
+	default:
+		return defaultScheme
+	}
+}
+
+// requireHost 返回 URL 的主机部分，为空时报错
+func (p *urlParser) requireHost(example string) (string, error) {
+	if p.u.Host == "" {
+		return "", p.errorf("host", "缺少服务器地址，格式应为 %s", example)
+	}
+	return p.u.Host, nil
+}
+
+// pathSegments 返回非空的路径段
+func (p *urlParser) pathSegments() []string {
+	var segments []string
+	for _, seg := range strings.Split(p.u.Path, "/") {
+		if seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+	return segments
+}
+
+// intParam 读取整数查询参数，未设置时返回 0
+func (p *urlParser) intParam(name string) (int, error) {
+	value := p.query.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, p.errorf("查询参数 "+name, "%s 不是整数", value)
+	}
+	return n, nil
+}
+
+// boolParam 读取布尔查询参数，支持 yes/no、true/false、1/0、on/off
+func (p *urlParser) boolParam(name string) (bool, error) {
+	return parseBoolParam(p.query.Get(name), func(value string) error {
+		return p.errorf("查询参数 "+name, "%s 不是布尔值，可选 yes/no", value)
+	})
+}
+
+// bark 解析 bark://<key>@<host>
+func (p *urlParser) bark() (notification.Notifier, error) {
+	host, err := p.requireHost("bark://<key>@api.day.app")
+	if err != nil {
+		return nil, err
+	}
+	if p.u.User == nil || p.u.User.Username() == "" {
+		return nil, p.errorf("设备密钥", "缺少设备密钥，格式应为 bark://<key>@%s", host)
+	}
+
+	level := p.query.Get("level")
+	switch level {
+	case "", "active", "timeSensitive", "passive", "critical":
+	default:
+		return nil, p.errorf("查询参数 level", "%s 不是有效的中断级别，可选 active、timeSensitive、passive、critical", level)
+	}
+
+	apiURL := p.httpScheme("https") + "://" + host + strings.TrimRight(p.u.Path, "/")
+	client := notification.NewBarkClient(p.u.User.Username(), apiURL)
+	client.ChannelName = p.query.Get("name")
+	client.Group = p.query.Get("group")
+	client.Level = level
+	client.Sound = p.query.Get("sound")
+	client.Icon = p.query.Get("icon")
+	return client, nil
+}
+
+// hismsg 解析 hismsg://<key>@<host>
+func (p *urlParser) hismsg() (notification.Notifier, error) {
+	host, err := p.requireHost("hismsg://<key>@hismsg.com")
+	if err != nil {
+		return nil, err
+	}
+	if p.u.User == nil || p.u.User.Username() == "" {
+		return nil, p.errorf("密钥", "缺少用户密钥，格式应为 hismsg://<key>@%s", host)
+	}
+
+	apiURL := p.httpScheme("https") + "://" + host + strings.TrimRight(p.u.Path, "/")
+	source := p.query.Get("source")
+	if source == "" {
+		source = "sim-sms-forward"
+	}
+	client := notification.NewHismsgClient(p.u.User.Username(), apiURL, source)
+	client.ChannelName = p.query.Get("name")
+	if tags := p.query.Get("tags"); tags != "" {
+		client.Tags = splitList(tags)
+	}
+	return client, nil
+}
+
+// gotify 解析 gotify://<host>[/path]/<token>
+func (p *urlParser) gotify() (notification.Notifier, error) {
+	host, err := p.requireHost("gotify://<host>/<token>")
+	if err != nil {
+		return nil, err
+	}
+	segments := p.pathSegments()
+	if len(segments) == 0 {
+		return nil, p.errorf("token", "缺少应用令牌，格式应为 gotify://%s/<token>", host)
+	}
+	priority, err := p.intParam("priority")
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := "http"
+	if strings.ToLower(p.u.Scheme) == "gotifys" {
+		scheme = "https"
+	}
+	base := scheme + "://" + host
+	if len(segments) > 1 {
+		base += "/" + strings.Join(segments[:len(segments)-1], "/")
+	}
+	return notification.NewGotifyClient(notification.GotifyConfig{
+		Name:     p.query.Get("name"),
+		URL:      base,
+		Token:    segments[len(segments)-1],
+		Priority: priority,
+	})
+}
+
+// ntfy 解析 ntfy://[user:pass@]<host>/<topic> 或 ntfy://<topic>
+func (p *urlParser) ntfy() (notification.Notifier, error) {
+	if p.u.Host == "" {
+		return nil, p.errorf("topic", "缺少主题，格式应为 ntfy://<host>/<topic> 或 ntfy://<topic>")
+	}
+	priority, err := p.intParam("priority")
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := notification.NtfyConfig{
+		Name:     p.query.Get("name"),
+		Priority: priority,
+		Click:    p.query.Get("click"),
+		Token:    p.query.Get("token"),
+	}
+	if tags := p.query.Get("tags"); tags != "" {
+		cfg.Tags = splitList(tags)
+	}
+	if p.u.User != nil {
+		cfg.Username = p.u.User.Username()
+		cfg.Password, _ = p.u.User.Password()
+	}
+
+	segments := p.pathSegments()
+	if len(segments) == 0 {
+		// ntfy://<topic> 使用公共服务器
+		cfg.URL = "https://ntfy.sh"
+		cfg.Topic = p.u.Host
+	} else {
+		scheme := "http"
+		if strings.ToLower(p.u.Scheme) == "ntfys" {
+			scheme = "https"
+		}
+		cfg.URL = scheme + "://" + p.u.Host
+		if len(segments) > 1 {
+			cfg.URL += "/" + strings.Join(segments[:len(segments)-1], "/")
+		}
+		cfg.Topic = segments[len(segments)-1]
+	}
+
+	client, err := notification.NewNtfyClient(cfg)
+	if err != nil {
+		return nil, p.errorf("查询参数", "%v", err)
+	}
+	return client, nil
+}
+
+// webhook 解析 webhook+https://<host>/<path>
+// method、content_type、timeout、name 为保留参数；+ 开头的参数作为请求头，- 开头的参数作为字段模板，其余参数保留在目标 URL 中
+func (p *urlParser) webhook() (notification.Notifier, error) {
+	if _, err := p.requireHost("webhook+https://<host>/<path>"); err != nil {
+		return nil, err
+	}
+	timeout, err := p.intParam("timeout")
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := notification.WebhookConfig{
+		Name:        p.query.Get("name"),
+		Method:      p.query.Get("method"),
+		ContentType: p.query.Get("content_type"),
+		Timeout:     timeout,
+		Headers:     make(map[string]string),
+		Fields:      make(map[string]string),
+	}
+
+	for key, values := range p.query {
+		switch {
+		case !isWebhookOption(key):
+		case isWebhookHeader(key):
+			cfg.Headers[key[1:]] = values[0]
+		case strings.HasPrefix(key, "-"):
+			cfg.Fields[key[1:]] = values[0]
+		}
+	}
+
+	// 目标 URL 的路径和其余查询参数按原样保留，重新编码会把模板的 {{ }} 转义为 %7B%7D，渲染后无法替换
+	target := *p.u
+	target.Scheme = p.httpScheme("https")
+	target.Path, target.RawPath, target.RawQuery, target.Fragment = "", "", "", ""
+	cfg.URL = target.String()
+	if p.u.RawPath != "" {
+		cfg.URL += p.u.RawPath
+	} else {
+		cfg.URL += p.u.EscapedPath()
+	}
+	var remaining []string
+	for _, pair := range strings.Split(p.u.RawQuery, "&") {
+		rawKey, _, _ := strings.Cut(pair, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			key = rawKey
+		}
+		if pair != "" && !isWebhookOption(key) {
+			remaining = append(remaining, pair)
+		}
+	}
+	if len(remaining) > 0 {
+		cfg.URL += "?" + strings.Join(remaining, "&")
+	}
+	if p.u.Fragment != "" {
+		cfg.URL += "#" + p.u.EscapedFragment()
+	}
+
+	client, err := notification.NewWebhookClient(cfg)
+	if err != nil {
+		return nil, p.errorf("查询参数", "%v", err)
+	}
+	return client, nil
+}
+
+// isWebhookHeader 判断 webhook 的查询参数是否为请求头
+// 查询字符串中未编码的 + 会被解码为空格，两种写法都视为请求头
+func isWebhookHeader(key string) bool {
+	return (strings.HasPrefix(key, "+") || strings.HasPrefix(key, " ")) && len(key) > 1
+}
+
+// isWebhookOption 判断 webhook 的查询参数是否为保留参数、请求头或字段，这些参数不保留在目标 URL 中
+func isWebhookOption(key string) bool {
+	switch key {
+	case "method", "content_type", "timeout", "name":
+		return true
+	}
+	return isWebhookHeader(key) || (strings.HasPrefix(key, "-") && len(key) > 1)
+}
+
+// parseTelegramURL 解析 tgram://<bot_token>/<chat_id>[/<chat_id>...]
+// 机器人令牌中包含冒号，不能按标准 URL 的 host:port 解析
+func parseTelegramURL(raw string) (notification.Notifier, error) {
+	rest := raw[len("tgram://"):]
+	var query url.Values
+	if q := strings.Index(rest, "?"); q >= 0 {
+		var err error
+		if query, err = url.ParseQuery(rest[q+1:]); err != nil {
+			return nil, &NotifyURLError{URL: redactNotifyURL(raw), Part: "查询参数", Msg: err.Error()}
+		}
+		rest = rest[:q]
+	}
+
+	var segments []string
+	for _, seg := range strings.Split(rest, "/") {
+		if seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+	if len(segments) == 0 || !strings.Contains(segments[0], ":") {
+		return nil, &NotifyURLError{URL: redactNotifyURL(raw), Part: "bot_token", Msg: "缺少机器人令牌，格式应为 tgram://123456:ABC-DEF/<chat_id>"}
+	}
+	if len(segments) < 2 {
+		return nil, &NotifyURLError{URL: redactNotifyURL(raw), Part: "chat_id", Msg: "缺少会话ID，格式应为 tgram://<bot_token>/<chat_id>"}
+	}
+	silent, err := parseBoolParam(query.Get("silent"), func(value string) error {
+		return &NotifyURLError{URL: redactNotifyURL(raw), Part: "查询参数 silent", Msg: value + " 不是布尔值，可选 yes/no"}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return notification.NewTelegramClient(notification.TelegramConfig{
+		Name:    query.Get("name"),
+		Token:   segments[0],
+		ChatIDs: segments[1:],
+		Silent:  silent,
+	})
+}
+
+// parsePushoverURL 解析 pover://<user_key>@<app_token>[/<device>]
+func parsePushoverURL(raw string) (notification.Notifier, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, &NotifyURLError{URL: redactNotifyURL(raw), Part: "格式", Msg: err.Error()}
+	}
+	p := &urlParser{u: u, raw: raw, query: u.Query()}
+
+	if u.User == nil || u.User.Username() == "" {
+		return nil, p.errorf("user_key", "缺少用户 Key，格式应为 pover://<user_key>@<app_token>")
+	}
+	if u.Host == "" {
+		return nil, p.errorf("app_token", "缺少应用 Token，格式应为 pover://<user_key>@<app_token>")
+	}
+	emergency, err := p.boolParam("emergency_otp")
+	if err != nil {
+		return nil, err
+	}
+	retry, err := p.intParam("retry")
+	if err != nil {
+		return nil, err
+	}
+	expire, err := p.intParam("expire")
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := notification.PushoverConfig{
+		Name:         p.query.Get("name"),
+		UserKey:      u.User.Username(),
+		AppToken:     u.Host,
+		Sound:        p.query.Get("sound"),
+		EmergencyOTP: emergency,
+		Retry:        retry,
+		Expire:       expire,
+	}
+	if segments := p.pathSegments(); len(segments) > 0 {
+		cfg.Device = segments[0]
+	}
+	client, err := notification.NewPushoverClient(cfg)
+	if err != nil {
+		return nil, p.errorf("查询参数", "%v", err)
+	}
+	return client, nil
+}
+
+// parseBoolParam 解析布尔参数，空字符串为 false
+func parseBoolParam(value string, invalid func(string) error) (bool, error) {
+	switch strings.ToLower(value) {
+	case "", "no", "false", "0", "off":
+		return false, nil
+	case "yes", "true", "1", "on":
+		return true, nil
+	default:
+		return false, invalid(value)
+	}
+}
+
+// splitList 按逗号分隔字符串并去除空白项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// redactNotifyURL 对通知 URL 进行脱敏，用于日志和错误信息
+// 隐藏用户信息部分，Telegram 和 Pushover 的令牌位于路径/主机中，只保留类型
+func redactNotifyURL(raw string) string {
+	idx := strings.Index(raw, "://")
+	if idx <= 0 {
+		if len(raw) > 12 {
+			return raw[:12] + "…"
+		}
+		return raw
+	}
+	scheme := strings.ToLower(raw[:idx])
+	switch scheme {
+	case "tgram", "pover":
+		return scheme + "://****"
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return scheme + "://…"
+	}
+	redacted := scheme + "://"
+	if u.User != nil {
+		redacted += "****@"
+	}
+	redacted += u.Host
+	if scheme == "gotify" || scheme == "gotifys" {
+		// Gotify 令牌位于路径最后一段
+		return redacted + "/****"
+	}
+	return redacted + u.EscapedPath()
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sim-sms-forward/pkg/types"
+)
+
+// TestWebhookURLTemplate 目标 URL 路径和查询参数中的模板在发送时渲染，不会被转义为 %7B%7D
+func TestWebhookURLTemplate(t *testing.T) {
+	type request struct {
+		path, query, auth, body string
+	}
+	got := make(chan request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- request{r.URL.EscapedPath(), r.URL.RawQuery, r.Header.Get("Authorization"), string(body)}
+	}))
+	defer srv.Close()
+
+	raw := "webhook+" + srv.URL + "/push/{{.Sender}}?key=abc%2F1&to={{.Sender}}&method=POST&content_type=form&%2BAuthorization=Bearer%20xxx&-msg={{.Content}}"
+	notifier, err := ParseNotifyURL(raw)
+	if err != nil {
+		t.Fatalf("解析通知 URL 失败: %v", err)
+	}
+	if err := notifier.SendSMS(&types.SMS{ID: "1", Sender: "10086", Content: "余额不足"}); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+
+	r := <-got
+	if r.path != "/push/10086" {
+		t.Errorf("路径为 %s，期望 /push/10086", r.path)
+	}
+	if r.query != "key=abc%2F1&to=10086" {
+		t.Errorf("查询参数为 %s，期望 key=abc%%2F1&to=10086", r.query)
+	}
+	if r.auth != "Bearer xxx" {
+		t.Errorf("Authorization 请求头为 %q", r.auth)
+	}
+	if !strings.Contains(r.body, "msg=%E4%BD%99") {
+		t.Errorf("表单字段未渲染: %s", r.body)
+	}
+}
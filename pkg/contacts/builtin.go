@@ -0,0 +1,61 @@
+// Package contacts 提供联系人通讯录，用于在通知中显示发送方名称
+package contacts
+
+// builtinContacts 内置的常见服务号码，用户通讯录中的同一号码优先
+var builtinContacts = map[string]string{
+	// 银行
+	"95588": "工商银行",
+	"95533": "建设银行",
+	"95599": "农业银行",
+	"95566": "中国银行",
+	"95559": "交通银行",
+	"95580": "邮储银行",
+	"95555": "招商银行",
+	"95561": "兴业银行",
+	"95568": "民生银行",
+	"95528": "浦发银行",
+	"95558": "中信银行",
+	"95595": "光大银行",
+	"95577": "华夏银行",
+	"95508": "广发银行",
+	"95511": "平安银行",
+	"95526": "北京银行",
+	"95594": "上海银行",
+
+	// 运营商
+	"10086": "中国移动",
+	"10010": "中国联通",
+	"10000": "中国电信",
+	"10099": "中国广电",
+
+	// 快递物流
+	"95338": "顺丰速运",
+	"11183": "中国邮政EMS",
+	"95311": "中通快递",
+	"95554": "圆通速递",
+	"95543": "申通快递",
+	"95546": "韵达快递",
+	"95353": "德邦快递",
+
+	// 支付和互联网服务
+	"95188": "支付宝",
+	"95017": "微信支付",
+	"95118": "京东",
+
+	// 公共服务
+	"12306": "铁路12306",
+	"12123": "交管12123",
+	"12345": "政务服务热线",
+	"12366": "纳税服务热线",
+	"12333": "人社服务热线",
+	"95598": "国家电网",
+	"95519": "中国人寿",
+	"95518": "中国人保",
+	"95500": "太平洋保险",
+
+	// 航空
+	"95583": "中国国航",
+	"95530": "东方航空",
+	"95539": "南方航空",
+	"95339": "海南航空",
+}
@@ -0,0 +1,256 @@
+// Package contacts 提供联系人通讯录，用于在通知中显示发送方名称
+package contacts
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sim-sms-forward/pkg/logger"
+)
+
+// Config 通讯录配置
+type Config struct {
+	File           string `json:"file,omitempty"`            // 通讯录文件路径，默认为数据目录下的 contacts.json
+	DisableBuiltin bool   `json:"disable_builtin,omitempty"` // 是否关闭内置的银行、运营商、快递等服务号码
+}
+
+// Contact 一个联系人号码
+type Contact struct {
+	Name   string `json:"name"`   // 显示名称
+	Number string `json:"number"` // 规范化后的号码
+}
+
+// Normalize 规范化电话号码，便于不同格式的同一号码互相匹配
+// 去掉空格、横线和括号，去掉 +86、0086 国家码，以及 11 位手机号前的 86、12520 等前缀
+// 参数: number - 原始号码
+// 返回: 规范化后的号码
+func Normalize(number string) string {
+	n := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '(', ')', '\t', '\u00a0', '\u3000':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(number))
+
+	switch {
+	case strings.HasPrefix(n, "+86"):
+		n = n[3:]
+	case strings.HasPrefix(n, "0086"):
+		n = n[4:]
+	case strings.HasPrefix(n, "86") && isMobile(n[2:]):
+		n = n[2:]
+	}
+	// 12520 为移动飞信前缀，12583、12593、17951、17911 为运营商的转接或 IP 拨号前缀
+	for _, prefix := range []string{"12520", "12583", "12593", "17951", "17911"} {
+		if strings.HasPrefix(n, prefix) && isMobile(n[len(prefix):]) {
+			n = n[len(prefix):]
+			break
+		}
+	}
+	return n
+}
+
+// isMobile 判断是否为 11 位手机号码
+func isMobile(n string) bool {
+	if len(n) != 11 || n[0] != '1' {
+		return false
+	}
+	for _, r := range n {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Book 通讯录
+// 用户联系人保存在 JSON 文件中，优先于内置号码；命令行修改文件后，运行中的服务会在下次查询时自动重新加载
+type Book struct {
+	path    string
+	builtin bool
+
+	mu       sync.Mutex
+	contacts map[string]string // 规范化号码 -> 名称
+	modTime  time.Time
+}
+
+// NewBook 创建通讯录
+// 参数:
+//   - cfg: 通讯录配置
+//   - dataDir: 数据目录，未配置 file 时通讯录保存为 contacts.json
+//
+// 返回: 初始化好的 Book 指针，文件不存在时为空通讯录
+func NewBook(cfg Config, dataDir string) *Book {
+	path := cfg.File
+	if path == "" {
+		path = filepath.Join(dataDir, "contacts.json")
+	}
+	return &Book{
+		path:     path,
+		builtin:  !cfg.DisableBuiltin,
+		contacts: make(map[string]string),
+	}
+}
+
+// Path 返回通讯录文件路径
+func (b *Book) Path() string {
+	return b.path
+}
+
+// Lookup 查找号码对应的名称
+// 参数: number - 原始号码
+// 返回: 联系人名称，未找到时返回空字符串
+func (b *Book) Lookup(number string) string {
+	normalized := Normalize(number)
+	if normalized == "" {
+		return ""
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.reload(); err != nil {
+		// 通讯录损坏不影响短信转发，继续使用上次加载的数据
+		logger.Errorf("加载通讯录失败: %v", err)
+	}
+	if name, ok := b.contacts[normalized]; ok {
+		return name
+	}
+	if b.builtin {
+		return builtinContacts[normalized]
+	}
+	return ""
+}
+
+// List 返回所有用户联系人，按名称排序
+func (b *Book) List() ([]Contact, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.reload(); err != nil {
+		return nil, err
+	}
+	return sortedContacts(b.contacts), nil
+}
+
+// Builtin 返回内置的服务号码，按名称排序
+func Builtin() []Contact {
+	return sortedContacts(builtinContacts)
+}
+
+// Add 添加或更新联系人并保存
+// 参数: contacts - 要添加的联系人，号码会被规范化，同一号码以最后一个为准
+// 返回: 实际添加或更新的数量和可能的错误
+func (b *Book) Add(contacts ...Contact) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.reload(); err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	for _, c := range contacts {
+		number := Normalize(c.Number)
+		name := strings.TrimSpace(c.Name)
+		if number == "" || name == "" {
+			continue
+		}
+		if b.contacts[number] != name {
+			b.contacts[number] = name
+			changed++
+		}
+	}
+	if changed == 0 {
+		return 0, nil
+	}
+	return changed, b.save()
+}
+
+// Remove 删除联系人并保存
+// 参数: number - 要删除的号码
+// 返回: 号码不存在时返回错误
+func (b *Book) Remove(number string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.reload(); err != nil {
+		return err
+	}
+	normalized := Normalize(number)
+	if _, ok := b.contacts[normalized]; !ok {
+		return fmt.Errorf("通讯录中没有号码 %s", normalized)
+	}
+	delete(b.contacts, normalized)
+	return b.save()
+}
+
+// reload 文件修改时间变化时重新读取通讯录，调用方需持有锁
+func (b *Book) reload() error {
+	info, err := os.Stat(b.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取通讯录失败: %v", err)
+	}
+	if info.ModTime().Equal(b.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(b.path)
+	if err != nil {
+		return fmt.Errorf("读取通讯录失败: %v", err)
+	}
+	var list []Contact
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("解析通讯录 %s 失败: %v", b.path, err)
+	}
+	contacts := make(map[string]string, len(list))
+	for _, c := range list {
+		contacts[Normalize(c.Number)] = c.Name
+	}
+	b.contacts = contacts
+	b.modTime = info.ModTime()
+	return nil
+}
+
+// save 先写入临时文件再重命名，避免服务读到写了一半的通讯录，调用方需持有锁
+func (b *Book) save() error {
+	data, err := json.MarshalIndent(sortedContacts(b.contacts), "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化通讯录失败: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		return fmt.Errorf("创建数据目录失败: %v", err)
+	}
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("写入通讯录失败: %v", err)
+	}
+	if err := os.Rename(tmp, b.path); err != nil {
+		return fmt.Errorf("写入通讯录失败: %v", err)
+	}
+	if info, err := os.Stat(b.path); err == nil {
+		b.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// sortedContacts 将号码映射转换为按名称、号码排序的联系人列表
+func sortedContacts(m map[string]string) []Contact {
+	list := make([]Contact, 0, len(m))
+	for number, name := range m {
+		list = append(list, Contact{Name: name, Number: number})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Number < list[j].Number
+	})
+	return list
+}
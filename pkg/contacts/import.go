@@ -0,0 +1,208 @@
+// Package contacts 提供联系人通讯录，用于在通知中显示发送方名称
+package contacts
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ImportFile 根据扩展名解析 vCard（.vcf）或 CSV（.csv）文件
+// 参数: path - 文件路径
+// 返回: 解析出的联系人和可能的错误
+func ImportFile(path string) ([]Contact, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开联系人文件失败: %v", err)
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".vcf", ".vcard":
+		return ParseVCard(file)
+	case ".csv":
+		return ParseCSV(file)
+	default:
+		return nil, fmt.Errorf("不支持的联系人文件格式: %s，仅支持 .vcf 和 .csv", filepath.Ext(path))
+	}
+}
+
+// ParseVCard 解析 vCard 文件，支持 2.1、3.0 和 4.0 版本
+// 每个联系人的所有 TEL 号码都使用 FN（没有 FN 时使用 N）作为名称
+func ParseVCard(r io.Reader) ([]Contact, error) {
+	lines, err := unfoldVCard(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var contacts []Contact
+	var name, structured string
+	var numbers []string
+	for _, line := range lines {
+		idx := strings.Index(line, ":")
+		if idx < 0 {
+			continue
+		}
+		params := strings.Split(line[:idx], ";")
+		key := strings.ToUpper(params[0])
+		// 去掉 item1.TEL 这类分组前缀
+		if dot := strings.LastIndex(key, "."); dot >= 0 {
+			key = key[dot+1:]
+		}
+		value := line[idx+1:]
+		if isQuotedPrintable(params) {
+			value = decodeQuotedPrintable(value)
+		}
+
+		switch key {
+		case "BEGIN":
+			name, structured, numbers = "", "", nil
+		case "FN":
+			name = unescapeVCard(value)
+		case "N":
+			// N:姓;名;中间名;前缀;后缀，中文习惯姓在前
+			parts := strings.Split(value, ";")
+			if len(parts) >= 2 {
+				structured = unescapeVCard(parts[0]) + unescapeVCard(parts[1])
+			} else {
+				structured = unescapeVCard(value)
+			}
+		case "TEL":
+			numbers = append(numbers, strings.TrimPrefix(value, "tel:"))
+		case "END":
+			if name == "" {
+				name = structured
+			}
+			for _, number := range numbers {
+				if name != "" && Normalize(number) != "" {
+					contacts = append(contacts, Contact{Name: name, Number: Normalize(number)})
+				}
+			}
+		}
+	}
+	return contacts, nil
+}
+
+// unfoldVCard 读取 vCard 行并合并折行
+// 以空格或制表符开头的行是上一行的延续，quoted-printable 编码以 = 结尾的行也与下一行相连
+func unfoldVCard(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 {
+			last := lines[len(lines)-1]
+			if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+				lines[len(lines)-1] = last + line[1:]
+				continue
+			}
+			if strings.HasSuffix(last, "=") && strings.Contains(strings.ToUpper(last), "QUOTED-PRINTABLE") {
+				lines[len(lines)-1] = strings.TrimSuffix(last, "=") + line
+				continue
+			}
+		}
+		lines = append(lines, strings.TrimPrefix(line, "\ufeff"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取 vCard 失败: %v", err)
+	}
+	return lines, nil
+}
+
+// isQuotedPrintable 判断 vCard 属性参数是否声明了 quoted-printable 编码（vCard 2.1 常见）
+func isQuotedPrintable(params []string) bool {
+	for _, p := range params[1:] {
+		p = strings.ToUpper(p)
+		if p == "ENCODING=QUOTED-PRINTABLE" || p == "QUOTED-PRINTABLE" {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeQuotedPrintable 解码 quoted-printable 文本，手机导出的中文联系人通常使用这种编码
+func decodeQuotedPrintable(value string) string {
+	var out []byte
+	for i := 0; i < len(value); i++ {
+		if value[i] == '=' && i+2 < len(value) {
+			var b byte
+			if _, err := fmt.Sscanf(value[i+1:i+3], "%02X", &b); err == nil {
+				out = append(out, b)
+				i += 2
+				continue
+			}
+		}
+		out = append(out, value[i])
+	}
+	return string(out)
+}
+
+// unescapeVCard 还原 vCard 中转义的字符
+func unescapeVCard(value string) string {
+	replacer := strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\N`, " ", `\\`, `\`)
+	return strings.TrimSpace(replacer.Replace(value))
+}
+
+// ParseCSV 解析 CSV 联系人文件
+// 有表头时按列名识别名称列（name、姓名、名称、备注）和号码列（number、phone、mobile、tel、电话、手机、号码），
+// 没有表头时第一列为名称、第二列为号码；同一行的多个号码列都会导入
+func ParseCSV(r io.Reader) ([]Contact, error) {
+	reader := csv.NewReader(bufio.NewReader(r))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("解析 CSV 失败: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	nameCol, numberCols := -1, []int(nil)
+	for i, header := range records[0] {
+		h := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header, "\ufeff")))
+		switch {
+		case nameCol < 0 && containsWord(h, "name", "姓名", "名称", "名字", "备注"):
+			nameCol = i
+		case containsWord(h, "number", "phone", "mobile", "tel", "电话", "手机", "号码"):
+			numberCols = append(numberCols, i)
+		}
+	}
+	if nameCol >= 0 && len(numberCols) > 0 {
+		records = records[1:]
+	} else {
+		nameCol, numberCols = 0, []int{1}
+	}
+
+	var contacts []Contact
+	for _, record := range records {
+		if nameCol >= len(record) {
+			continue
+		}
+		name := strings.TrimSpace(record[nameCol])
+		for _, col := range numberCols {
+			if col >= len(record) {
+				continue
+			}
+			if number := Normalize(record[col]); name != "" && number != "" {
+				contacts = append(contacts, Contact{Name: name, Number: number})
+			}
+		}
+	}
+	return contacts, nil
+}
+
+// containsWord 判断表头是否包含任意一个关键字
+func containsWord(header string, words ...string) bool {
+	for _, w := range words {
+		if strings.Contains(header, w) {
+			return true
+		}
+	}
+	return false
+}
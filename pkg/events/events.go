@@ -0,0 +1,296 @@
+// Package events 提供短信处理事件的发布和订阅
+// 事件追加保存到数据目录的 events.jsonl，订阅方断线重连时可以从上次收到的事件序号继续接收
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"sim-sms-forward/pkg/logger"
+	"sim-sms-forward/pkg/types"
+	"sim-sms-forward/pkg/vault"
+)
+
+// 事件类型
+const (
+	TypeSMSReceived    = "sms_received"    // 收到短信，已提取元数据
+	TypeSMSForwarded   = "sms_forwarded"   // 短信已发送到所有选中的通知渠道并归档
+	TypeSMSDeleted     = "sms_deleted"     // 短信已从调制解调器删除
+	TypeDeliveryFailed = "delivery_failed" // 某个通知渠道发送失败
+	TypeModemState     = "modem_state"     // 调制解调器状态或网络注册状态变化
+)
+
+// maxEvents events.jsonl 中保留的事件数，超过两倍时只保留最近的这些事件
+const maxEvents = 5000
+
+// subscriberBuffer 每个订阅方的缓冲区大小，订阅方处理不过来时丢弃事件，由订阅方发现序号不连续后通过 Since 补齐
+const subscriberBuffer = 64
+
+// Event 一个事件
+type Event struct {
+	ID   int64           `json:"id"`   // 递增的事件序号
+	Type string          `json:"type"` // 事件类型
+	Time time.Time       `json:"time"` // 发生时间
+	Data json.RawMessage `json:"data"` // 事件内容
+}
+
+// SMS sms_received、sms_forwarded 和 sms_deleted 事件的内容
+type SMS struct {
+	SMSID      string               `json:"sms_id"`               // 短信在调制解调器上的ID
+	ArchiveID  int64                `json:"archive_id,omitempty"` // 归档序号，归档后才有，可以通过 /api/messages 查询完整记录
+	ModemID    string               `json:"modem_id"`
+	Sender     string               `json:"sender,omitempty"`
+	SenderName string               `json:"sender_name,omitempty"`
+	Location   types.NumberLocation `json:"location,omitempty"`
+	Timestamp  string               `json:"timestamp,omitempty"`
+	Content    string               `json:"content,omitempty"`
+	Code       string               `json:"code,omitempty"`
+	Priority   string               `json:"priority,omitempty"`
+	Spam       bool                 `json:"spam,omitempty"`
+	Channels   []string             `json:"channels,omitempty"` // sms_forwarded: 发送成功的通知渠道
+	Dropped    bool                 `json:"dropped,omitempty"`  // sms_forwarded: 被路由规则丢弃或作为垃圾短信不推送
+}
+
+// Delivery delivery_failed 事件的内容
+type Delivery struct {
+	SMSID     string `json:"sms_id"`
+	ArchiveID int64  `json:"archive_id,omitempty"`
+	Sender    string `json:"sender"`
+	Channel   string `json:"channel"`
+	Error     string `json:"error"`
+	Replay    bool   `json:"replay,omitempty"` // 是否为重新发送
+}
+
+// Modem modem_state 事件的内容
+type Modem struct {
+	ModemID       string `json:"modem_id"`
+	State         string `json:"state"`                   // 当前状态，调制解调器不可用时为 unavailable
+	Previous      string `json:"previous,omitempty"`      // 变化前的状态
+	Registration  string `json:"registration,omitempty"`  // 网络注册状态
+	SignalQuality int    `json:"signal_quality"`          // 信号质量（0-100）
+	FailedReason  string `json:"failed_reason,omitempty"` // 状态为 failed 时的原因
+	Error         string `json:"error,omitempty"`         // 获取状态失败的原因
+}
+
+// Bus 事件总线
+type Bus struct {
+	path string
+	key  *vault.Key // 加密密钥，未配置加密时为 nil
+
+	mu          sync.Mutex
+	nextID      int64
+	count       int // 文件中的事件数
+	subscribers map[chan Event]bool
+}
+
+// Path 返回数据目录中事件文件的路径
+func Path(dataDir string) string {
+	return filepath.Join(dataDir, "events.jsonl")
+}
+
+// Open 打开事件总线，读取已有事件的序号，事件过多时只保留最近的事件
+// 参数:
+//   - dataDir: 数据目录
+//   - key: 加密密钥，为 nil 时不加密
+//
+// 返回: 事件总线和可能的错误，事件文件无法用 key 解密时返回错误
+func Open(dataDir string, key *vault.Key) (*Bus, error) {
+	b := &Bus{
+		path:        Path(dataDir),
+		key:         key,
+		nextID:      1,
+		subscribers: make(map[chan Event]bool),
+	}
+	err := b.scan(func(e Event) {
+		if e.ID >= b.nextID {
+			b.nextID = e.ID + 1
+		}
+		b.count++
+	})
+	if err != nil {
+		return nil, err
+	}
+	if b.count > maxEvents {
+		if err := b.trim(b.key); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Publish 发布事件，保存到事件文件并发送给所有订阅方
+// 保存失败只记录日志，不影响短信处理
+// 参数:
+//   - typ: 事件类型
+//   - data: 事件内容，序列化为 JSON
+func (b *Bus) Publish(typ string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		logger.Errorf("序列化 %s 事件失败: %v", typ, err)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e := Event{ID: b.nextID, Type: typ, Time: time.Now(), Data: payload}
+	b.nextID++
+	if err := b.append(e); err != nil {
+		logger.Errorf("保存 %s 事件失败: %v", typ, err)
+	}
+	if b.count > 2*maxEvents {
+		if err := b.trim(b.key); err != nil {
+			logger.Errorf("清理事件文件失败: %v", err)
+		}
+	}
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe 订阅之后发布的事件
+// 返回: 接收事件的通道和取消订阅的函数，取消后通道关闭
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	b.subscribers[ch] = true
+	b.mu.Unlock()
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.subscribers[ch] {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// LastID 返回最后发布的事件序号，还没有事件时为 0
+func (b *Bus) LastID() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.nextID - 1
+}
+
+// Since 读取序号大于 id 的已保存事件，用于订阅方断线后补齐
+// 参数: id - 订阅方最后收到的事件序号
+// 返回: 按序号排列的事件和可能的错误
+func (b *Bus) Since(id int64) ([]Event, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var list []Event
+	err := b.scan(func(e Event) {
+		if e.ID > id {
+			list = append(list, e)
+		}
+	})
+	return list, err
+}
+
+// Rekey 用新密钥重新加密事件文件，newKey 为 nil 时解密为明文
+func (b *Bus) Rekey(newKey *vault.Key) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.trim(newKey); err != nil {
+		return fmt.Errorf("重新加密事件文件失败: %v", err)
+	}
+	b.key = newKey
+	return nil
+}
+
+// append 追加一个事件，调用方需持有锁
+func (b *Bus) append(e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		return fmt.Errorf("创建数据目录失败: %v", err)
+	}
+	file, err := os.OpenFile(b.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(append(vault.Encode(b.key, data), '\n')); err != nil {
+		return err
+	}
+	b.count++
+	return nil
+}
+
+// trim 只保留最近的 maxEvents 个事件，用 key 加密后整体写回，调用方需持有锁或在初始化时调用
+func (b *Bus) trim(key *vault.Key) error {
+	var kept [][]byte
+	err := b.scanLines(func(line []byte) {
+		kept = append(kept, line)
+		if len(kept) > maxEvents {
+			kept = kept[1:]
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, line := range kept {
+		buf.Write(vault.Encode(key, line))
+		buf.WriteByte('\n')
+	}
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("写入事件文件失败: %v", err)
+	}
+	if err := os.Rename(tmp, b.path); err != nil {
+		return fmt.Errorf("替换事件文件失败: %v", err)
+	}
+	b.count = len(kept)
+	return nil
+}
+
+// scan 按写入顺序读取所有事件，无法解析的行跳过
+func (b *Bus) scan(fn func(e Event)) error {
+	return b.scanLines(func(line []byte) {
+		var e Event
+		if err := json.Unmarshal(line, &e); err == nil {
+			fn(e)
+		}
+	})
+}
+
+// scanLines 按写入顺序读取所有事件解密后的 JSON，无法解密时返回错误
+func (b *Bus) scanLines(fn func(line []byte)) error {
+	file, err := os.Open(b.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("打开事件文件失败: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		data, err := vault.Decode(b.key, scanner.Bytes())
+		if err != nil {
+			return fmt.Errorf("事件文件 %s 第 %d 行: %v", b.path, line, err)
+		}
+		fn(append([]byte(nil), data...))
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取事件文件失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,322 @@
+// Package health 提供存活和就绪检查端点，供看门狗脚本和外部监控使用
+// 存活检查只看处理循环是否还在推进，就绪检查还包括调制解调器、SIM 卡注册和通知渠道的投递情况
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"sim-sms-forward/pkg/logger"
+	"sim-sms-forward/pkg/types"
+)
+
+// Config 健康检查端点的配置
+type Config struct {
+	Enable          bool   `json:"enable"`                      // 是否启用健康检查端点
+	Listen          string `json:"listen,omitempty"`            // 监听地址，为空时挂载在监控端点（metrics）上
+	MaxMissedCycles int    `json:"max_missed_cycles,omitempty"` // 超过多少个检查间隔没有完成一轮处理时判定为失去响应，默认 3
+	NotifierWindow  int    `json:"notifier_window,omitempty"`   // 检查通知渠道投递结果的时间范围（小时），默认 24
+}
+
+// Validate 验证健康检查配置
+func (c *Config) Validate() error {
+	if c.MaxMissedCycles < 0 {
+		return fmt.Errorf("health.max_missed_cycles 不能小于 0")
+	}
+	if c.NotifierWindow < 0 {
+		return fmt.Errorf("health.notifier_window 不能小于 0")
+	}
+	return nil
+}
+
+// minLoopTimeout 判定处理循环失去响应的最短时间
+// 检查间隔很短时，一轮处理中的通知发送耗时就可能超过几个间隔
+const minLoopTimeout = 2 * time.Minute
+
+// modemStale 调制解调器状态超过这个时间没有更新时，认为状态不可信
+const modemStale = 3 * time.Minute
+
+// Check 一项检查的结果
+type Check struct {
+	OK      bool        `json:"ok"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"` // 检查的详细数据
+}
+
+// Report 存活或就绪检查的结果
+type Report struct {
+	Status string           `json:"status"` // ok 或 fail
+	Time   time.Time        `json:"time"`
+	Checks map[string]Check `json:"checks"`
+}
+
+// OK 判断所有检查是否都通过
+func (r *Report) OK() bool {
+	return r.Status == "ok"
+}
+
+// ChannelState 一个通知渠道最近的投递情况
+type ChannelState struct {
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastFailure *time.Time `json:"last_failure,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// Monitor 记录处理循环、调制解调器和通知渠道的状态，生成检查结果
+// 未启用健康检查时为 nil，nil 上的记录方法不做任何事
+type Monitor struct {
+	cfg      Config
+	interval time.Duration
+	started  time.Time
+
+	mu        sync.Mutex
+	lastCycle time.Time
+	modem     *types.ModemStatus // 最近一次获取的调制解调器状态，获取失败时为 nil
+	modemErr  string
+	modemAt   time.Time
+	channels  map[string]*ChannelState
+}
+
+// NewMonitor 创建健康检查
+// 参数:
+//   - cfg: 健康检查配置
+//   - interval: 处理循环的检查间隔
+//
+// 返回: 初始化好的 Monitor 指针，监听独立地址时需要调用 Start
+func NewMonitor(cfg Config, interval time.Duration) *Monitor {
+	if cfg.MaxMissedCycles == 0 {
+		cfg.MaxMissedCycles = 3
+	}
+	if cfg.NotifierWindow == 0 {
+		cfg.NotifierWindow = 24
+	}
+	return &Monitor{
+		cfg:      cfg,
+		interval: interval,
+		started:  time.Now(),
+		channels: make(map[string]*ChannelState),
+	}
+}
+
+// CycleDone 记录处理循环完成了一轮，检查失败（如调制解调器不可用）也算完成
+func (m *Monitor) CycleDone() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastCycle = time.Now()
+}
+
+// SetModem 记录最近一次获取的调制解调器状态
+// 参数:
+//   - status: 调制解调器状态，获取失败时为 nil
+//   - err: 获取状态失败的原因
+func (m *Monitor) SetModem(status *types.ModemStatus, err error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.modem = status
+	m.modemErr = ""
+	if err != nil {
+		m.modemErr = err.Error()
+	}
+	m.modemAt = time.Now()
+}
+
+// Delivered 记录一次通知投递的结果
+// 参数:
+//   - channel: 通知渠道名称
+//   - err: 投递失败的原因，成功时为 nil
+func (m *Monitor) Delivered(channel string, err error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state, ok := m.channels[channel]
+	if !ok {
+		state = &ChannelState{}
+		m.channels[channel] = state
+	}
+	now := time.Now()
+	if err != nil {
+		state.LastFailure = &now
+		state.LastError = err.Error()
+	} else {
+		state.LastSuccess = &now
+	}
+}
+
+// loopTimeout 处理循环超过这个时间没有完成一轮时判定为失去响应
+func (m *Monitor) loopTimeout() time.Duration {
+	timeout := time.Duration(m.cfg.MaxMissedCycles) * m.interval
+	if timeout < minLoopTimeout {
+		timeout = minLoopTimeout
+	}
+	return timeout
+}
+
+// Liveness 检查处理循环是否还在推进，启动后还没有完成第一轮时从启动时间开始计算
+func (m *Monitor) Liveness() *Report {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	return newReport(now, map[string]Check{"loop": m.checkLoop(now)})
+}
+
+// Readiness 检查调制解调器是否存在、SIM 卡是否已注册到网络，以及通知渠道最近是否投递成功
+func (m *Monitor) Readiness() *Report {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	return newReport(now, map[string]Check{
+		"loop":      m.checkLoop(now),
+		"modem":     m.checkModem(now),
+		"sim":       m.checkSIM(now),
+		"notifiers": m.checkNotifiers(now),
+	})
+}
+
+// newReport 根据各项检查的结果生成报告
+func newReport(now time.Time, checks map[string]Check) *Report {
+	report := &Report{Status: "ok", Time: now, Checks: checks}
+	for _, check := range checks {
+		if !check.OK {
+			report.Status = "fail"
+		}
+	}
+	return report
+}
+
+func (m *Monitor) checkLoop(now time.Time) Check {
+	timeout := m.loopTimeout()
+	data := map[string]interface{}{"timeout_seconds": int(timeout.Seconds())}
+	since := m.started
+	if m.lastCycle.IsZero() {
+		data["started"] = m.started
+	} else {
+		since = m.lastCycle
+		data["last_cycle"] = m.lastCycle
+	}
+	age := now.Sub(since).Truncate(time.Second)
+	if age > timeout {
+		return Check{Message: fmt.Sprintf("处理循环已有 %s 没有完成（超时 %s）", age, timeout), Data: data}
+	}
+	if m.lastCycle.IsZero() {
+		return Check{OK: true, Message: "等待完成第一轮处理", Data: data}
+	}
+	return Check{OK: true, Message: fmt.Sprintf("%s 前完成一轮处理", age), Data: data}
+}
+
+func (m *Monitor) checkModem(now time.Time) Check {
+	switch {
+	case m.modemAt.IsZero():
+		return Check{Message: "还没有获取调制解调器状态"}
+	case now.Sub(m.modemAt) > modemStale:
+		return Check{Message: fmt.Sprintf("调制解调器状态已有 %s 没有更新", now.Sub(m.modemAt).Truncate(time.Second))}
+	case m.modem == nil:
+		return Check{Message: "调制解调器不可用: " + m.modemErr}
+	case m.modem.State == "failed":
+		return Check{Message: fmt.Sprintf("调制解调器状态为 failed（%s）", m.modem.FailedReason), Data: m.modem}
+	}
+	return Check{
+		OK:      true,
+		Message: fmt.Sprintf("调制解调器状态为 %s，信号 %d%%", m.modem.State, m.modem.SignalQuality),
+		Data:    m.modem,
+	}
+}
+
+func (m *Monitor) checkSIM(now time.Time) Check {
+	if m.modem == nil || now.Sub(m.modemAt) > modemStale {
+		return Check{Message: "调制解调器状态未知"}
+	}
+	if m.modem.SIMPath == "" {
+		return Check{Message: "未检测到 SIM 卡"}
+	}
+	data := map[string]string{"registration": m.modem.RegistrationState, "operator": m.modem.OperatorName}
+	switch m.modem.RegistrationState {
+	case "home", "roaming":
+		return Check{OK: true, Message: fmt.Sprintf("已注册到网络（%s）", m.modem.RegistrationState), Data: data}
+	case "":
+		return Check{Message: "未注册到网络", Data: data}
+	}
+	return Check{Message: fmt.Sprintf("未注册到网络（%s）", m.modem.RegistrationState), Data: data}
+}
+
+// checkNotifiers 时间范围内有投递时，要求至少有一个通知渠道投递成功；没有投递时视为正常
+func (m *Monitor) checkNotifiers(now time.Time) Check {
+	window := time.Duration(m.cfg.NotifierWindow) * time.Hour
+	since := now.Add(-window)
+	recent := func(t *time.Time) bool { return t != nil && t.After(since) }
+
+	var succeeded, failed []string
+	data := make(map[string]ChannelState, len(m.channels))
+	for name, state := range m.channels {
+		data[name] = *state
+		if recent(state.LastSuccess) {
+			succeeded = append(succeeded, name)
+		} else if recent(state.LastFailure) {
+			failed = append(failed, name)
+		}
+	}
+	sort.Strings(succeeded)
+	sort.Strings(failed)
+
+	switch {
+	case len(succeeded) > 0:
+		return Check{OK: true, Message: fmt.Sprintf("最近 %d 小时投递成功的通知渠道: %v", m.cfg.NotifierWindow, succeeded), Data: data}
+	case len(failed) > 0:
+		return Check{Message: fmt.Sprintf("最近 %d 小时所有通知渠道都投递失败: %v", m.cfg.NotifierWindow, failed), Data: data}
+	}
+	return Check{OK: true, Message: fmt.Sprintf("最近 %d 小时没有投递", m.cfg.NotifierWindow), Data: data}
+}
+
+// Handle 在 mux 上注册 /healthz 和 /readyz
+// 参数: handle - 注册处理器的函数，如 http.ServeMux.Handle
+func (m *Monitor) Handle(handle func(pattern string, handler http.Handler)) {
+	handle("GET /healthz", m.serve(m.Liveness))
+	handle("GET /readyz", m.serve(m.Readiness))
+}
+
+// Start 在独立的地址上开始监听
+func (m *Monitor) Start() {
+	mux := http.NewServeMux()
+	m.Handle(mux.Handle)
+	server := &http.Server{
+		Addr:              m.cfg.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		logger.Infof("健康检查端点已启动: %s", m.cfg.Listen)
+		if err := server.ListenAndServe(); err != nil {
+			logger.Errorf("健康检查端点停止: %v", err)
+		}
+	}()
+}
+
+// serve 以 JSON 输出检查结果，检查失败时返回 503
+func (m *Monitor) serve(check func() *Report) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := check()
+		status := http.StatusOK
+		if !report.OK() {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			logger.Errorf("写入健康检查响应失败: %v", err)
+		}
+	})
+}
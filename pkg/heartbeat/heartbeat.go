@@ -0,0 +1,328 @@
+// Package heartbeat 提供心跳功能，用于在设备停止工作时让外部得知
+// 支持两种方式：每轮处理成功后请求 healthchecks.io 风格的 ping 地址，超时未收到请求时由外部服务告警，
+// 投递失败时可以改为请求失败地址让外部服务立即告警；
+// 以及每天定时推送一条“仍在运行”的汇总通知
+package heartbeat
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"sim-sms-forward/pkg/logger"
+	"sim-sms-forward/pkg/rules"
+	"sim-sms-forward/pkg/types"
+)
+
+// Config 心跳配置
+type Config struct {
+	PingURL      string   `json:"ping_url,omitempty"`      // 每轮处理成功后请求的地址，如 https://hc-ping.com/<uuid>，为空时不发送
+	FailURL      string   `json:"fail_url,omitempty"`      // 本轮检查失败或有短信投递失败时请求的地址，如 https://hc-ping.com/<uuid>/fail，为空时只是不请求 ping_url
+	PingInterval int      `json:"ping_interval,omitempty"` // 两次请求的最短间隔（秒），默认 60
+	SummaryAt    string   `json:"summary_at,omitempty"`    // 每天推送运行汇总的时间，格式 HH:MM，为空时不推送
+	Notifiers    []string `json:"notifiers,omitempty"`     // 推送运行汇总的通知渠道，为空时使用所有渠道（MQTT 除外）
+}
+
+// Validate 验证心跳配置，通知渠道是否存在由调用方检查
+func (c *Config) Validate() error {
+	if c.PingURL != "" {
+		u, err := url.Parse(c.PingURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("heartbeat.ping_url 无效: %s", c.PingURL)
+		}
+	}
+	if c.FailURL != "" {
+		if c.PingURL == "" {
+			return fmt.Errorf("配置 heartbeat.fail_url 时必须同时配置 heartbeat.ping_url")
+		}
+		u, err := url.Parse(c.FailURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("heartbeat.fail_url 无效: %s", c.FailURL)
+		}
+	}
+	if c.PingInterval < 0 {
+		return fmt.Errorf("heartbeat.ping_interval 不能小于 0")
+	}
+	if c.SummaryAt != "" {
+		if _, err := rules.ParseClock(c.SummaryAt); err != nil {
+			return fmt.Errorf("heartbeat.summary_at 无效，应为 HH:MM: %s", c.SummaryAt)
+		}
+	}
+	return nil
+}
+
+// Enabled 判断是否配置了任意一种心跳
+func (c *Config) Enabled() bool {
+	return c.PingURL != "" || c.SummaryAt != ""
+}
+
+// pingTimeout 请求 ping 地址的超时时间
+const pingTimeout = 10 * time.Second
+
+// StatusFunc 获取调制解调器状态的函数
+type StatusFunc func() (*types.ModemStatus, error)
+
+// SendFunc 推送运行汇总的函数
+type SendFunc func(sms *types.SMS) error
+
+// Summary 运行汇总的内容
+type Summary struct {
+	DeviceID  string
+	Since     time.Time          // 统计的起始时间，上次汇总或程序启动的时间
+	Uptime    time.Duration      // 已运行时间
+	Received  int                // 统计期间收到的短信数
+	Failed    int                // 统计期间投递失败的次数
+	Pending   int                // 暂存队列中等待发送的短信数
+	Status    *types.ModemStatus // 调制解调器状态，获取失败时为 nil
+	StatusErr error              // 获取调制解调器状态失败的原因
+}
+
+// Message 将运行汇总生成一条通知，标题和正文已填好，优先级为低
+func (s *Summary) Message(now time.Time) *types.SMS {
+	var body strings.Builder
+	fmt.Fprintf(&body, "设备: %s\n", s.DeviceID)
+	fmt.Fprintf(&body, "已运行: %s\n", FormatUptime(s.Uptime))
+	fmt.Fprintf(&body, "%s以来收到 %d 条短信，投递失败 %d 次\n", s.Since.Format("01-02 15:04"), s.Received, s.Failed)
+	if s.Pending > 0 {
+		fmt.Fprintf(&body, "等待发送: %d 条\n", s.Pending)
+	}
+	if s.Status != nil {
+		fmt.Fprintf(&body, "调制解调器: %s，信号 %d%%", s.Status.State, s.Status.SignalQuality)
+		if s.Status.AccessTech != "" {
+			fmt.Fprintf(&body, "（%s）", s.Status.AccessTech)
+		}
+		if s.Status.OperatorName != "" {
+			fmt.Fprintf(&body, "，%s", s.Status.OperatorName)
+		}
+	} else {
+		fmt.Fprintf(&body, "调制解调器不可用: %v", s.StatusErr)
+	}
+
+	title := "短信转发运行正常"
+	if s.Status == nil || s.Status.State == "failed" {
+		title = "短信转发运行中，调制解调器异常"
+	}
+	return &types.SMS{
+		ID:        fmt.Sprintf("heartbeat-%d", now.Unix()),
+		Sender:    "heartbeat",
+		Timestamp: now.Format(time.RFC3339),
+		Content:   body.String(),
+		ModemID:   deref(s.Status).ModemID,
+		Priority:  types.PriorityLow,
+		Title:     title,
+		Body:      body.String(),
+	}
+}
+
+// deref 返回状态的副本，状态为 nil 时返回零值
+func deref(status *types.ModemStatus) types.ModemStatus {
+	if status == nil {
+		return types.ModemStatus{}
+	}
+	return *status
+}
+
+// FormatUptime 将运行时间格式化为“3天4小时5分”的形式
+func FormatUptime(d time.Duration) string {
+	minutes := int(d.Minutes())
+	days, hours := minutes/(24*60), minutes/60%24
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%d天%d小时%d分", days, hours, minutes%60)
+	case hours > 0:
+		return fmt.Sprintf("%d小时%d分", hours, minutes%60)
+	}
+	return fmt.Sprintf("%d分", minutes%60)
+}
+
+// Heartbeat 心跳，统计汇总用的短信数并按配置发送 ping 和运行汇总
+// 未配置心跳时为 nil，nil 上的统计方法不做任何事
+type Heartbeat struct {
+	cfg      Config
+	deviceID string
+	started  time.Time
+	client   *http.Client
+
+	mu       sync.Mutex
+	lastPing time.Time
+	lastOK   bool // 上次成功请求的是 ping_url 还是 fail_url
+	pinging  bool
+	since    time.Time // 本次统计的起始时间
+	received int
+	failed   int
+}
+
+// New 创建心跳
+// 参数:
+//   - cfg: 心跳配置
+//   - deviceID: 设备标识，显示在运行汇总中
+//
+// 返回: 初始化好的 Heartbeat 指针，推送运行汇总需要调用 Start
+func New(cfg Config, deviceID string) *Heartbeat {
+	if cfg.PingInterval == 0 {
+		cfg.PingInterval = 60
+	}
+	if deviceID == "" {
+		deviceID = "sim-sms-forward"
+	}
+	now := time.Now()
+	return &Heartbeat{
+		cfg:      cfg,
+		deviceID: deviceID,
+		started:  now,
+		since:    now,
+		client:   &http.Client{Timeout: pingTimeout},
+	}
+}
+
+// Received 统计收到一条短信
+func (h *Heartbeat) Received() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.received++
+}
+
+// Failed 统计一次投递失败
+func (h *Heartbeat) Failed() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failed++
+}
+
+// CycleDone 在处理循环完成一轮后调用，本轮成功时在后台请求 ping 地址
+// 本轮检查失败或有短信投递失败时不请求 ping 地址，外部服务超时后告警；配置了 fail_url 时改为请求 fail_url
+// 距上次请求超过 ping_interval，或者结果与上次请求的不同时才请求；
+// 上一次请求还没有结束时跳过，避免网络缓慢时拖慢处理循环或堆积请求
+// 参数: ok - 本轮检查成功且所有短信都投递成功
+func (h *Heartbeat) CycleDone(ok bool) {
+	if h == nil {
+		return
+	}
+	target := h.cfg.PingURL
+	if !ok {
+		target = h.cfg.FailURL
+	}
+	if target == "" {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	if h.pinging || (ok == h.lastOK && now.Sub(h.lastPing) < time.Duration(h.cfg.PingInterval)*time.Second) {
+		return
+	}
+	h.pinging = true
+	go func() {
+		err := h.request(target)
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.pinging = false
+		if err != nil {
+			logger.Errorf("%v", err)
+			return
+		}
+		h.lastPing = now
+		h.lastOK = ok
+	}()
+}
+
+// Ping 请求 ping 地址
+// 返回: 请求失败或返回非 2xx 状态码时返回错误
+func (h *Heartbeat) Ping() error {
+	return h.request(h.cfg.PingURL)
+}
+
+// request 请求 ping 地址或失败地址
+func (h *Heartbeat) request(target string) error {
+	resp, err := h.client.Get(target)
+	if err != nil {
+		return fmt.Errorf("心跳请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("心跳请求失败，状态码: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Start 在后台按 summary_at 每天推送运行汇总，未配置时不做任何事
+// 参数:
+//   - send: 推送运行汇总的函数
+//   - status: 获取调制解调器状态的函数
+//   - pending: 获取暂存队列长度的函数，可以为 nil
+func (h *Heartbeat) Start(send SendFunc, status StatusFunc, pending func() int) {
+	if h.cfg.SummaryAt == "" {
+		return
+	}
+	// 配置文件加载时已经验证过时间格式
+	at, _ := rules.ParseClock(h.cfg.SummaryAt)
+	go func() {
+		for {
+			time.Sleep(time.Until(nextSummary(time.Now(), at)))
+			if err := h.SendSummary(send, status, pending); err != nil {
+				logger.Errorf("%v", err)
+				continue
+			}
+			logger.Infof("已推送运行汇总")
+		}
+	}()
+}
+
+// SendSummary 生成并推送一条运行汇总，推送成功后重新开始统计，失败时保留统计数据等下次推送
+// 参数同 Start
+func (h *Heartbeat) SendSummary(send SendFunc, status StatusFunc, pending func() int) error {
+	summary := h.Collect(status, pending)
+	if err := send(summary.Message(time.Now())); err != nil {
+		return fmt.Errorf("推送运行汇总失败: %v", err)
+	}
+	h.Reset()
+	return nil
+}
+
+// Collect 生成当前的运行汇总
+// 参数:
+//   - status: 获取调制解调器状态的函数
+//   - pending: 获取暂存队列长度的函数，可以为 nil
+func (h *Heartbeat) Collect(status StatusFunc, pending func() int) *Summary {
+	s := &Summary{DeviceID: h.deviceID}
+	s.Status, s.StatusErr = status()
+	if pending != nil {
+		s.Pending = pending()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s.Since = h.since
+	s.Uptime = time.Since(h.started)
+	s.Received = h.received
+	s.Failed = h.failed
+	return s
+}
+
+// Reset 推送运行汇总后重新开始统计
+func (h *Heartbeat) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.since = time.Now()
+	h.received = 0
+	h.failed = 0
+}
+
+// nextSummary 返回 now 之后下一个推送运行汇总的时间
+// 参数: at - 推送时间（当天的分钟数）
+func nextSummary(now time.Time, at int) time.Time {
+	y, m, d := now.Date()
+	next := time.Date(y, m, d, at/60, at%60, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
@@ -0,0 +1,170 @@
+package heartbeat
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sim-sms-forward/pkg/types"
+)
+
+// pingServer 记录收到的请求路径，按 status 返回状态码
+type pingServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	paths  []string
+	status int
+}
+
+func newPingServer(t *testing.T) *pingServer {
+	t.Helper()
+	s := &pingServer{status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.paths = append(s.paths, r.URL.Path)
+		w.WriteHeader(s.status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *pingServer) setStatus(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+func (s *pingServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.paths...)
+}
+
+// cycle 调用 CycleDone 并等待后台请求结束
+func cycle(t *testing.T, h *Heartbeat, ok bool) {
+	t.Helper()
+	h.CycleDone(ok)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		h.mu.Lock()
+		pinging := h.pinging
+		h.mu.Unlock()
+		if !pinging {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("等待心跳请求超时")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// rewind 把上次请求的时间往前拨，模拟经过了 ping_interval
+func rewind(h *Heartbeat) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastPing = h.lastPing.Add(-time.Duration(h.cfg.PingInterval) * time.Second)
+}
+
+func TestCycleDoneInterval(t *testing.T) {
+	srv := newPingServer(t)
+	h := New(Config{PingURL: srv.URL + "/ping", PingInterval: 60}, "test")
+
+	cycle(t, h, true)
+	cycle(t, h, true)
+	if got := srv.requests(); len(got) != 1 {
+		t.Fatalf("ping_interval 内应只请求一次，实际请求 %q", got)
+	}
+	rewind(h)
+	cycle(t, h, true)
+	if got := srv.requests(); len(got) != 2 {
+		t.Fatalf("超过 ping_interval 后应再次请求，实际请求 %q", got)
+	}
+}
+
+// TestCycleDoneFailure 有短信投递失败时不请求 ping_url，配置了 fail_url 时立即请求 fail_url
+func TestCycleDoneFailure(t *testing.T) {
+	srv := newPingServer(t)
+	h := New(Config{PingURL: srv.URL + "/ping", PingInterval: 60}, "test")
+	cycle(t, h, false)
+	rewind(h)
+	cycle(t, h, false)
+	if got := srv.requests(); len(got) != 0 {
+		t.Fatalf("未配置 fail_url 时失败的一轮不应请求，实际请求 %q", got)
+	}
+
+	h = New(Config{PingURL: srv.URL + "/ping", FailURL: srv.URL + "/ping/fail", PingInterval: 60}, "test")
+	cycle(t, h, true)
+	cycle(t, h, false)
+	cycle(t, h, false)
+	cycle(t, h, true)
+	want := []string{"/ping", "/ping/fail", "/ping"}
+	if got := srv.requests(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("请求为 %q，期望 %q", got, want)
+	}
+}
+
+// TestCycleDoneNon2xx 返回非 2xx 状态码时视为请求失败，下一轮立即重试
+func TestCycleDoneNon2xx(t *testing.T) {
+	srv := newPingServer(t)
+	srv.setStatus(http.StatusServiceUnavailable)
+	h := New(Config{PingURL: srv.URL + "/ping", PingInterval: 60}, "test")
+
+	if err := h.Ping(); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("状态码 503 时 Ping() 应返回错误，实际为 %v", err)
+	}
+	cycle(t, h, true)
+	srv.setStatus(http.StatusOK)
+	cycle(t, h, true)
+	cycle(t, h, true)
+	if got := srv.requests(); len(got) != 3 {
+		t.Errorf("请求失败后应在下一轮重试，成功后按间隔请求，实际请求 %q", got)
+	}
+}
+
+func TestSendSummary(t *testing.T) {
+	h := New(Config{SummaryAt: "09:00"}, "test")
+	h.Received()
+	h.Received()
+	h.Failed()
+	status := func() (*types.ModemStatus, error) {
+		return &types.ModemStatus{ModemID: "0", State: "registered", SignalQuality: 80}, nil
+	}
+
+	// 推送失败时保留统计数据
+	err := h.SendSummary(func(*types.SMS) error { return errors.New("网络错误") }, status, nil)
+	if err == nil {
+		t.Fatal("推送失败时应返回错误")
+	}
+	var sent *types.SMS
+	if err := h.SendSummary(func(sms *types.SMS) error { sent = sms; return nil }, status, func() int { return 4 }); err != nil {
+		t.Fatalf("SendSummary 返回错误: %v", err)
+	}
+	if sent.Title != "短信转发运行正常" || sent.Priority != types.PriorityLow {
+		t.Errorf("运行汇总标题或优先级不正确: %s, %v", sent.Title, sent.Priority)
+	}
+	for _, want := range []string{"设备: test", "收到 2 条短信，投递失败 1 次", "等待发送: 4 条", "信号 80%"} {
+		if !strings.Contains(sent.Body, want) {
+			t.Errorf("运行汇总缺少 %q:\n%s", want, sent.Body)
+		}
+	}
+
+	// 推送成功后重新开始统计
+	if s := h.Collect(status, nil); s.Received != 0 || s.Failed != 0 {
+		t.Errorf("推送成功后统计未清零: 收到 %d，失败 %d", s.Received, s.Failed)
+	}
+}
+
+func TestValidateFailURL(t *testing.T) {
+	if err := (&Config{FailURL: "https://hc-ping.com/x/fail"}).Validate(); err == nil {
+		t.Error("只配置 fail_url 时应返回错误")
+	}
+	if err := (&Config{PingURL: "https://hc-ping.com/x", FailURL: "hc-ping.com/x/fail"}).Validate(); err == nil {
+		t.Error("fail_url 无效时应返回错误")
+	}
+}
@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// Privacy 日志隐私级别，控制日志中电话号码和短信内容的详细程度
+type Privacy int32
+
+const (
+	PrivacyFull   Privacy = iota // 完整记录号码和内容
+	PrivacyMasked                // 号码部分隐藏，内容截断并隐藏数字（包括验证码）
+	PrivacyNone                  // 不记录号码和内容
+)
+
+// maskedContentLength masked 级别下保留的内容长度（字符数）
+const maskedContentLength = 10
+
+// privacy 当前的日志隐私级别，默认完整记录
+var privacy atomic.Int32
+
+// String 返回隐私级别的名称
+func (p Privacy) String() string {
+	switch p {
+	case PrivacyMasked:
+		return "masked"
+	case PrivacyNone:
+		return "none"
+	default:
+		return "full"
+	}
+}
+
+// ParsePrivacy 将隐私级别名称解析为 Privacy
+// 参数: name - 级别名称: full、masked、none，为空时为 full
+// 返回: 对应的隐私级别和可能的错误
+func ParsePrivacy(name string) (Privacy, error) {
+	switch name {
+	case "", "full":
+		return PrivacyFull, nil
+	case "masked":
+		return PrivacyMasked, nil
+	case "none":
+		return PrivacyNone, nil
+	default:
+		return PrivacyFull, fmt.Errorf("未知的日志隐私级别 %s，可选 full、masked、none", name)
+	}
+}
+
+// SetPrivacy 设置日志隐私级别，对之后通过 Phone 和 Content 格式化的日志生效
+func SetPrivacy(level Privacy) {
+	privacy.Store(int32(level))
+}
+
+// Phone 按日志隐私级别格式化电话号码
+// masked 级别保留前3位和后4位，10086、95588 这类不超过6位的服务号码原样保留
+func Phone(number string) string {
+	switch Privacy(privacy.Load()) {
+	case PrivacyMasked:
+		runes := []rune(number)
+		if len(runes) <= 6 {
+			return number
+		}
+		keep := 4
+		if len(runes) < 11 {
+			keep = 2
+		}
+		return string(runes[:3]) + strings.Repeat("*", len(runes)-3-keep) + string(runes[len(runes)-keep:])
+	case PrivacyNone:
+		return "[已隐藏]"
+	default:
+		return number
+	}
+}
+
+// Content 按日志隐私级别格式化短信内容
+// masked 级别只保留开头几个字，并把其中的数字替换为 *，避免验证码、卡号和金额写入日志
+func Content(content string) string {
+	switch Privacy(privacy.Load()) {
+	case PrivacyMasked:
+		total := utf8.RuneCountInString(content)
+		var b strings.Builder
+		count := 0
+		for _, r := range content {
+			if count == maskedContentLength {
+				break
+			}
+			if r >= '0' && r <= '9' {
+				r = '*'
+			}
+			b.WriteRune(r)
+			count++
+		}
+		if total > maskedContentLength {
+			fmt.Fprintf(&b, "…（共 %d 字）", total)
+		}
+		return b.String()
+	case PrivacyNone:
+		return fmt.Sprintf("[已隐藏，共 %d 字]", utf8.RuneCountInString(content))
+	default:
+		return content
+	}
+}
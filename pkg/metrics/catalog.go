@@ -0,0 +1,70 @@
+package metrics
+
+// 程序的所有监控指标，名称统一使用 sms_forward_ 前缀
+var (
+	// SMSReceived 从调制解调器读取的短信数
+	SMSReceived = NewCounter("sms_forward_sms_received_total",
+		"从调制解调器读取并开始处理的短信数", "modem")
+
+	// SMSForwarded 各通知渠道发送成功的短信数
+	SMSForwarded = NewCounter("sms_forward_sms_forwarded_total",
+		"通知渠道发送成功的短信数", "notifier", "modem")
+
+	// SMSFailed 各通知渠道发送失败的次数
+	SMSFailed = NewCounter("sms_forward_sms_failed_total",
+		"通知渠道发送失败的次数", "notifier", "modem")
+
+	// DeliveryLatency 从短信时间戳到通知发送成功的时间
+	DeliveryLatency = NewHistogram("sms_forward_delivery_latency_seconds",
+		"从短信时间戳到通知渠道发送成功的端到端延迟（秒）",
+		[]float64{1, 2, 5, 10, 30, 60, 120, 300, 600, 1800, 3600}, "notifier")
+
+	// MMCLIDuration mmcli 命令的执行时间
+	MMCLIDuration = NewHistogram("sms_forward_mmcli_duration_seconds",
+		"mmcli 命令的执行时间（秒）",
+		[]float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}, "operation")
+
+	// MMCLIErrors mmcli 命令执行失败的次数
+	MMCLIErrors = NewCounter("sms_forward_mmcli_errors_total",
+		"mmcli 命令执行失败的次数", "operation")
+
+	// SignalQuality 调制解调器信号质量
+	SignalQuality = NewGauge("sms_forward_modem_signal_quality_percent",
+		"调制解调器信号质量（0-100）", "modem")
+
+	// ModemState 调制解调器当前状态，当前状态为 1
+	ModemState = NewGauge("sms_forward_modem_state",
+		"调制解调器当前状态，当前状态的值为 1，调制解调器不可用时 state 为 unavailable", "modem", "state")
+
+	// RegistrationState 网络注册状态，当前状态为 1
+	RegistrationState = NewGauge("sms_forward_modem_registration_state",
+		"网络注册状态，当前状态的值为 1", "modem", "state")
+
+	// StoredSMS 调制解调器上保存的短信数
+	StoredSMS = NewGauge("sms_forward_modem_stored_sms",
+		"调制解调器上保存的短信数（所有状态）", "modem")
+
+	// StorageUsed 接收短信的存储中已用的条数
+	StorageUsed = NewGauge("sms_forward_modem_storage_used",
+		"调制解调器接收短信的存储中已用的条数", "modem")
+
+	// StorageCapacity 接收短信的存储的容量
+	StorageCapacity = NewGauge("sms_forward_modem_storage_capacity",
+		"调制解调器接收短信的存储的容量，未知时为 0", "modem")
+
+	// RecoveryActions 执行自动恢复措施的次数
+	RecoveryActions = NewCounter("sms_forward_recovery_actions_total",
+		"执行调制解调器自动恢复措施的次数，result 为 ok 或 error", "step", "result")
+
+	// OutboxDepth 待发送队列中的短信数
+	OutboxDepth = NewGauge("sms_forward_outbox_depth",
+		"免打扰和汇总推送的待发送队列中的短信数", "notifier", "kind")
+
+	// LastCycle 最后一次成功完成短信检查的时间
+	LastCycle = NewGauge("sms_forward_last_successful_cycle_timestamp_seconds",
+		"最后一次成功完成短信检查循环的 Unix 时间戳")
+
+	// StartTime 服务启动时间
+	StartTime = NewGauge("sms_forward_start_time_seconds",
+		"服务启动的 Unix 时间戳")
+)
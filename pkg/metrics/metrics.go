@@ -0,0 +1,262 @@
+// Package metrics 提供 Prometheus 文本格式的监控指标
+// 只实现本程序用到的计数器、仪表和直方图，不依赖 Prometheus 客户端库
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// metric 可以输出为 Prometheus 文本格式的指标
+type metric interface {
+	write(w io.Writer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []metric
+	collectors []func() // 输出指标前调用，用于更新按需读取的仪表
+)
+
+// register 注册指标，输出时按注册顺序排列
+func register(m metric) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, m)
+}
+
+// OnCollect 注册在每次输出指标前调用的函数，用于更新只在抓取时读取的指标，如队列长度
+func OnCollect(fn func()) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	collectors = append(collectors, fn)
+}
+
+// WriteText 以 Prometheus 文本格式输出所有指标
+func WriteText(w io.Writer) {
+	registryMu.Lock()
+	metrics := append([]metric(nil), registry...)
+	fns := append([]func(){}, collectors...)
+	registryMu.Unlock()
+
+	for _, fn := range fns {
+		fn()
+	}
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// desc 指标的名称、说明和标签名
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+// header 输出 HELP 和 TYPE 行
+func (d *desc) header(w io.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, d.help, d.name, typ)
+}
+
+// key 将标签值拼接为 map 的键
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("指标 %s 需要 %d 个标签值，实际为 %d 个", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelString 生成 {a="1",b="2"} 格式的标签，extra 为额外的标签，如直方图的 le
+func (d *desc) labelString(key string, extra ...string) string {
+	var pairs []string
+	if len(d.labels) > 0 {
+		for i, value := range strings.Split(key, "\xff") {
+			pairs = append(pairs, d.labels[i]+`="`+escape(value)+`"`)
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escape(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// escape 转义标签值中的反斜杠、双引号和换行
+func escape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// formatFloat 按 Prometheus 的习惯格式化数值
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// sortedKeys 返回排序后的键，保证输出顺序稳定
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Counter 只增不减的计数器
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounter 创建并注册计数器
+// 参数:
+//   - name: 指标名称
+//   - help: 说明
+//   - labels: 标签名
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name, help, labels}, values: make(map[string]float64)}
+	register(c)
+	return c
+}
+
+// Inc 计数加一
+// 参数: labelValues - 按创建时的顺序提供的标签值
+func (c *Counter) Inc(labelValues ...string) {
+	key := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key]++
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.header(w, "counter")
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelString(key), formatFloat(c.values[key]))
+	}
+}
+
+// Gauge 可增可减的仪表
+type Gauge struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewGauge 创建并注册仪表
+// 参数:
+//   - name: 指标名称
+//   - help: 说明
+//   - labels: 标签名
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{desc: desc{name, help, labels}, values: make(map[string]float64)}
+	register(g)
+	return g
+}
+
+// Set 设置仪表的值
+// 参数:
+//   - value: 值
+//   - labelValues: 按创建时的顺序提供的标签值
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	key := g.key(labelValues)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.values[key] = value
+}
+
+// Reset 删除所有标签组合的值，用于重新设置只保留当前状态的指标
+func (g *Gauge) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.values = make(map[string]float64)
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.header(w, "gauge")
+	for _, key := range sortedKeys(g.values) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.labelString(key), formatFloat(g.values[key]))
+	}
+}
+
+// Histogram 直方图，按区间统计观测值的分布
+type Histogram struct {
+	desc
+	buckets []float64 // 各区间的上界，从小到大
+	mu      sync.Mutex
+	values  map[string]*histogramValue
+}
+
+// histogramValue 一个标签组合的统计
+type histogramValue struct {
+	counts []uint64 // 各区间的计数（不累加）
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram 创建并注册直方图
+// 参数:
+//   - name: 指标名称
+//   - help: 说明
+//   - buckets: 区间上界，从小到大
+//   - labels: 标签名
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{desc: desc{name, help, labels}, buckets: buckets, values: make(map[string]*histogramValue)}
+	register(h)
+	return h
+}
+
+// Observe 记录一个观测值
+// 参数:
+//   - value: 观测值
+//   - labelValues: 按创建时的顺序提供的标签值
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	v, ok := h.values[key]
+	if !ok {
+		v = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = v
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		v.counts[i]++
+	}
+	v.sum += value
+	v.count++
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(w, "histogram")
+	for _, key := range sortedKeys(h.values) {
+		v := h.values[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += v.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(key, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(key, "le", "+Inf"), v.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelString(key), formatFloat(v.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelString(key), v.count)
+	}
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"sim-sms-forward/pkg/logger"
+)
+
+// Config 监控端点的配置
+type Config struct {
+	Enable bool   `json:"enable"`           // 是否启用监控端点
+	Listen string `json:"listen,omitempty"` // 监听地址，默认 :9108
+	Token  string `json:"token,omitempty"`  // 访问令牌，配置后需要 Authorization: Bearer 请求头
+}
+
+// Server 监控端点，提供 /metrics
+type Server struct {
+	cfg Config
+	mux *http.ServeMux
+}
+
+// NewServer 创建监控端点
+// 参数: cfg - 监控端点配置
+// 返回: 初始化好的 Server 指针，调用 Start 后才会监听
+func NewServer(cfg Config) *Server {
+	if cfg.Listen == "" {
+		cfg.Listen = ":9108"
+	}
+	s := &Server{cfg: cfg, mux: http.NewServeMux()}
+	s.Handle("GET /metrics", http.HandlerFunc(handleMetrics))
+	return s
+}
+
+// Handle 在监控端点上注册其他处理器
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start 在后台开始监听
+func (s *Server) Start() {
+	server := &http.Server{
+		Addr:              s.cfg.Listen,
+		Handler:           s.auth(s.mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		logger.Infof("监控端点已启动: %s", s.cfg.Listen)
+		if err := server.ListenAndServe(); err != nil {
+			logger.Errorf("监控端点停止: %v", err)
+		}
+	}()
+}
+
+// auth 配置了访问令牌时检查请求头
+func (s *Server) auth(next http.Handler) http.Handler {
+	if s.cfg.Token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.Token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleMetrics 以 Prometheus 文本格式输出所有指标
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	WriteText(w)
+}
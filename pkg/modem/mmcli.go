@@ -0,0 +1,35 @@
+package modem
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"sim-sms-forward/pkg/metrics"
+)
+
+// mmcliTimeout mmcli 命令的最长执行时间，调制解调器无响应时 mmcli 可能一直不返回
+const mmcliTimeout = 30 * time.Second
+
+// mmcli 执行 mmcli 命令并返回标准输出，同时记录执行时间和失败次数
+// 参数:
+//   - operation: 操作名称，用作监控指标的标签，如 list、read、delete
+//   - args: mmcli 的参数
+func mmcli(operation string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mmcliTimeout)
+	defer cancel()
+	start := time.Now()
+	cmd := exec.CommandContext(ctx, "mmcli", args...)
+	// 超时结束进程后不再等待可能仍占用输出管道的子进程
+	cmd.WaitDelay = 5 * time.Second
+	output, err := cmd.Output()
+	metrics.MMCLIDuration.Observe(time.Since(start).Seconds(), operation)
+	if err != nil {
+		metrics.MMCLIErrors.Inc(operation)
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("mmcli 执行超时（%s）", mmcliTimeout)
+		}
+	}
+	return output, err
+}
@@ -6,14 +6,23 @@ import (
 	"os/exec"
 	"regexp"
 	"strings"
+	"sync"
 
 	"sim-sms-forward/pkg/logger"
 	"sim-sms-forward/pkg/types"
 )
 
 // Manager 调制解调器管理器
+// 调制解调器重置或重新插拔后 ModemManager 可能为它分配新的ID，ID 通过 ID 和 SetID 读写
 type Manager struct {
-	ModemID string // 调制解调器的ID，用于指定要操作的硬件设备
+	mu        sync.RWMutex
+	id        string // 调制解调器的ID，用于指定要操作的硬件设备
+	equipment string // 设备标识（IMEI 等），用于ID变化后重新找到调制解调器
+	checkErr  string // 上次检查调制解调器失败的原因，同样的原因只记录一次日志
+
+	// outgoing 在发送短信的整个过程中持有，清理短信时也持有，
+	// 避免已创建还没有发送的短信被当作草稿删除
+	outgoing sync.Mutex
 }
 
 // NewManager 创建一个新的调制解调器管理器
@@ -21,17 +30,31 @@ type Manager struct {
 // 返回: 初始化好的 Manager 指针
 func NewManager(modemID string) *Manager {
 	return &Manager{
-		ModemID: modemID,
+		id: modemID,
 	}
 }
 
+// ID 返回调制解调器当前的ID
+func (m *Manager) ID() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.id
+}
+
+// SetID 更新调制解调器的ID，用于 ModemManager 重新分配ID之后
+func (m *Manager) SetID(modemID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.id = modemID
+}
+
 // CheckMMCLI 检查系统中是否安装了 mmcli 命令行工具
 // mmcli 是 ModemManager 提供的命令行接口，用于与调制解调器通信
 // 返回: 如果未找到 mmcli 命令则返回错误，否则返回 nil
 func (m *Manager) CheckMMCLI() error {
 	_, err := exec.LookPath("mmcli")
 	if err != nil {
-		logger.Errorf("未找到mmcli命令，请确保已安装ModemManager: %v", err)
+		// 由调用方记录日志，处理循环中同样的错误只记录一次
 		return fmt.Errorf("错误: 未找到mmcli命令，请确保已安装ModemManager")
 	}
 	//logger.Info("mmcli 命令检查通过")
@@ -40,16 +63,23 @@ func (m *Manager) CheckMMCLI() error {
 
 // CheckModem 验证指定ID的调制解调器是否存在且可访问
 // 通过执行 mmcli --modem=<ID> 命令来检查调制解调器状态
+// 每个处理周期都会调用，mmcli 返回的失败原因和上次相同时不再记录日志，恢复后再次失败时重新记录
 // 返回: 如果调制解调器不存在或不可访问则返回错误，否则返回 nil
 func (m *Manager) CheckModem() error {
-	//logger.Infof("检查调制解调器 ID: %s", m.ModemID)
-	cmd := exec.Command("mmcli", "--modem="+m.ModemID)
-	err := cmd.Run()
+	//logger.Infof("检查调制解调器 ID: %s", m.ID())
+	id := m.ID()
+	_, err := mmcli("check", "--modem="+id)
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if err != nil {
-		logger.Errorf("未找到调制解调器 ID %s: %v", m.ModemID, err)
-		return fmt.Errorf("错误: 未找到ID为 %s 的调制解调器", m.ModemID)
+		if reason := id + ": " + err.Error(); reason != m.checkErr {
+			m.checkErr = reason
+			logger.Errorf("未找到调制解调器 ID %s: %v", id, err)
+		}
+		return fmt.Errorf("错误: 未找到ID为 %s 的调制解调器", id)
 	}
-	//logger.Infof("调制解调器 %s 检查通过", m.ModemID)
+	m.checkErr = ""
+	//logger.Infof("调制解调器 %s 检查通过", m.ID())
 	return nil
 }
 
@@ -58,9 +88,8 @@ func (m *Manager) CheckModem() error {
 // 使用正则表达式解析输出，提取状态为 "(received)" 的短信ID
 // 返回: 短信ID字符串切片和可能的错误
 func (m *Manager) GetSMSList() ([]string, error) {
-	//logger.Infof("获取调制解调器 %s 的短信列表", m.ModemID)
-	cmd := exec.Command("mmcli", "--modem="+m.ModemID, "--messaging-list-sms")
-	output, err := cmd.Output()
+	//logger.Infof("获取调制解调器 %s 的短信列表", m.ID())
+	output, err := mmcli("list", "--modem="+m.ID(), "--messaging-list-sms")
 	if err != nil {
 		logger.Errorf("获取短信列表失败: %v", err)
 		return nil, fmt.Errorf("获取短信列表失败: %v", err)
@@ -89,8 +118,7 @@ func (m *Manager) GetSMSList() ([]string, error) {
 // 返回: SMS结构体指针和可能的错误
 func (m *Manager) ExtractSMSInfo(smsID string) (*types.SMS, error) {
 	logger.Infof("提取短信 %s 的详细信息", smsID)
-	cmd := exec.Command("mmcli", "-s", smsID)
-	output, err := cmd.Output()
+	output, err := mmcli("read", "-s", smsID)
 	if err != nil {
 		logger.Errorf("获取短信 %s 详情失败: %v", smsID, err)
 		return nil, fmt.Errorf("获取短信 %s 详情失败: %v", smsID, err)
@@ -158,7 +186,7 @@ func (m *Manager) ExtractSMSInfo(smsID string) (*types.SMS, error) {
 		sms.Content = "无内容"
 	}
 
-	logger.Infof("成功提取短信信息 - ID: %s, 发送方: %s, 时间: %s", sms.ID, sms.Sender, sms.Timestamp)
+	logger.Infof("成功提取短信信息 - ID: %s, 发送方: %s, 时间: %s", sms.ID, logger.Phone(sms.Sender), sms.Timestamp)
 	return sms, nil
 }
 
@@ -168,8 +196,7 @@ func (m *Manager) ExtractSMSInfo(smsID string) (*types.SMS, error) {
 // 返回: 删除成功返回 nil，失败返回错误
 func (m *Manager) DeleteSMS(smsID string) error {
 	logger.Infof("删除短信 %s", smsID)
-	cmd := exec.Command("mmcli", "-m", m.ModemID, "--messaging-delete-sms="+smsID)
-	err := cmd.Run()
+	_, err := mmcli("delete", "-m", m.ID(), "--messaging-delete-sms="+smsID)
 	if err != nil {
 		logger.Errorf("删除短信 %s 失败: %v", smsID, err)
 		return fmt.Errorf("删除短信 %s 失败: %v", smsID, err)
@@ -0,0 +1,128 @@
+package modem
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"sim-sms-forward/pkg/logger"
+)
+
+// modemPathPattern 匹配 mmcli -L 输出中的调制解调器对象路径
+// 匹配格式: /org/freedesktop/ModemManager1/Modem/<数字> [厂商] 型号
+var modemPathPattern = regexp.MustCompile(`/org/freedesktop/ModemManager1/Modem/(\d+)`)
+
+// Enable 重新启用调制解调器
+// 执行 mmcli -m <ID> -e，用于调制解调器处于 disabled 等状态时恢复
+// 返回: 执行失败时返回错误
+func (m *Manager) Enable() error {
+	logger.Infof("重新启用调制解调器 %s", m.ID())
+	if _, err := mmcli("enable", "-m", m.ID(), "-e"); err != nil {
+		return fmt.Errorf("启用调制解调器 %s 失败: %v", m.ID(), err)
+	}
+	return nil
+}
+
+// Reset 重置调制解调器
+// 执行 mmcli -m <ID> -r，调制解调器重新初始化后 ModemManager 可能为它分配新的序号
+// 返回: 执行失败时返回错误
+func (m *Manager) Reset() error {
+	logger.Infof("重置调制解调器 %s", m.ID())
+	if _, err := mmcli("reset", "-m", m.ID(), "-r"); err != nil {
+		return fmt.Errorf("重置调制解调器 %s 失败: %v", m.ID(), err)
+	}
+	return nil
+}
+
+// ScanModems 让 ModemManager 重新扫描调制解调器
+// 执行 mmcli -S，用于调制解调器从 ModemManager 中消失的情况
+// 返回: 执行失败时返回错误
+func ScanModems() error {
+	logger.Info("重新扫描调制解调器")
+	if _, err := mmcli("scan", "-S"); err != nil {
+		return fmt.Errorf("重新扫描调制解调器失败: %v", err)
+	}
+	return nil
+}
+
+// Identify 记录调制解调器的设备标识，已记录时不做任何事
+// 执行 mmcli -m <ID> -K 读取 equipment-identifier（通常为 IMEI），应在调制解调器可用时调用
+// 返回: 读取失败时返回错误
+func (m *Manager) Identify() error {
+	m.mu.RLock()
+	known := m.equipment != ""
+	m.mu.RUnlock()
+	if known {
+		return nil
+	}
+	id := m.ID()
+	equipment, err := equipmentID(id)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.equipment = equipment
+	return nil
+}
+
+// Rediscover 调制解调器不可用时，检查 ModemManager 是否为它分配了新的ID
+// 执行 mmcli -L 列出所有调制解调器，按 Identify 记录的设备标识找到它并更新ID；
+// 没有记录设备标识时，只在仅有一个调制解调器时采用它。ID 不是数字（如 any）时不做任何事
+// 返回: 新的ID，ID 没有变化或没有找到时为空字符串
+func (m *Manager) Rediscover() (string, error) {
+	current := m.ID()
+	if _, err := strconv.Atoi(current); err != nil {
+		return "", nil
+	}
+	output, err := mmcli("modems", "-L")
+	if err != nil {
+		return "", fmt.Errorf("列出调制解调器失败: %v", err)
+	}
+	var ids []string
+	for _, match := range modemPathPattern.FindAllStringSubmatch(string(output), -1) {
+		ids = append(ids, match[1])
+	}
+	if len(ids) == 0 || slices.Contains(ids, current) {
+		return "", nil
+	}
+
+	m.mu.RLock()
+	equipment := m.equipment
+	m.mu.RUnlock()
+	found := ""
+	switch {
+	case equipment != "":
+		for _, id := range ids {
+			if e, err := equipmentID(id); err == nil && e == equipment {
+				found = id
+				break
+			}
+		}
+	case len(ids) == 1:
+		found = ids[0]
+	}
+	if found == "" {
+		return "", nil
+	}
+	logger.Infof("调制解调器的ID已从 %s 变为 %s", current, found)
+	m.SetID(found)
+	return found, nil
+}
+
+// equipmentID 读取指定ID的调制解调器的设备标识，没有 equipment-identifier 时使用 IMEI
+func equipmentID(modemID string) (string, error) {
+	output, err := mmcli("status", "-m", modemID, "-K")
+	if err != nil {
+		return "", fmt.Errorf("获取调制解调器 %s 的设备标识失败: %v", modemID, err)
+	}
+	kv := parseKeyValue(string(output))
+	if id := kv["modem.generic.equipment-identifier"]; id != "" {
+		return id, nil
+	}
+	if id := kv["modem.3gpp.imei"]; id != "" {
+		return id, nil
+	}
+	return "", fmt.Errorf("调制解调器 %s 没有设备标识", modemID)
+}
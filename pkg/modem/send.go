@@ -0,0 +1,68 @@
+// Package modem 提供与 ModemManager 调制解调器交互的功能
+package modem
+
+import (
+	"fmt"
+	"strings"
+
+	"sim-sms-forward/pkg/logger"
+)
+
+// SendSMS 通过调制解调器发送一条短信
+// 先执行 mmcli -m <ID> --messaging-create-sms 创建短信对象，再执行 mmcli -s <smsID> --send 发送，
+// 发送完成后删除该短信对象，避免占用存储空间
+// 参数:
+//   - number: 收信号码
+//   - text: 短信内容
+//
+// 返回: 发送成功返回 nil，失败返回错误
+func (m *Manager) SendSMS(number, text string) error {
+	if number == "" || text == "" {
+		return fmt.Errorf("收信号码和短信内容不能为空")
+	}
+	if strings.ContainsAny(number, "'\",") {
+		return fmt.Errorf("收信号码格式不正确: %s", logger.Phone(number))
+	}
+	logger.Infof("发送短信到 %s", logger.Phone(number))
+	m.outgoing.Lock()
+	defer m.outgoing.Unlock()
+
+	createArg := fmt.Sprintf("--messaging-create-sms=number='%s',text=%s", number, quoteSMSText(text))
+	output, err := mmcli("create", "-m", m.ID(), createArg)
+	if err != nil {
+		logger.Errorf("创建短信失败: %v", err)
+		return fmt.Errorf("创建短信失败: %v", err)
+	}
+
+	match := smsPathPattern.FindStringSubmatch(string(output))
+	if len(match) < 2 {
+		logger.Errorf("无法从 mmcli 输出中解析短信ID: %s", strings.TrimSpace(string(output)))
+		return fmt.Errorf("无法从 mmcli 输出中解析短信ID")
+	}
+	smsID := match[1]
+
+	if _, err := mmcli("send", "-s", smsID, "--send"); err != nil {
+		logger.Errorf("发送短信 %s 失败: %v", smsID, err)
+		// 发送失败也尝试清理已创建的短信对象
+		_ = m.DeleteSMS(smsID)
+		return fmt.Errorf("发送短信 %s 失败: %v", smsID, err)
+	}
+	logger.Infof("短信 %s 已发送到 %s", smsID, logger.Phone(number))
+
+	if err := m.DeleteSMS(smsID); err != nil {
+		logger.Errorf("清理已发送短信 %s 失败: %v", smsID, err)
+	}
+	return nil
+}
+
+// quoteSMSText 为 mmcli 的键值参数加引号
+// mmcli 的引号内不支持转义，因此选择内容中没有出现的引号；两种引号都出现时把单引号替换为全角引号
+func quoteSMSText(text string) string {
+	if !strings.Contains(text, "'") {
+		return "'" + text + "'"
+	}
+	if !strings.Contains(text, `"`) {
+		return `"` + text + `"`
+	}
+	return "'" + strings.ReplaceAll(text, "'", "’") + "'"
+}
@@ -0,0 +1,61 @@
+package modem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeMMCLI 模拟 mmcli 的脚本，创建的短信ID固定为 7，发送需要 0.3 秒，调用记录在脚本所在目录的 calls 文件
+const fakeMMCLI = `#!/bin/sh
+D=$(dirname "$0")
+echo "$*" >> "$D/calls"
+case "$*" in
+  *--messaging-create-sms*) touch "$D/sms"; echo "Successfully created new SMS: /org/freedesktop/ModemManager1/SMS/7";;
+  "-s 7 --send") sleep 0.3; echo "successfully sent the SMS";;
+  *--messaging-list-sms) [ -f "$D/sms" ] && echo "    /org/freedesktop/ModemManager1/SMS/7 (unknown)";;
+  *--messaging-delete-sms=7) rm -f "$D/sms";;
+esac
+exit 0
+`
+
+// TestPurgeWaitsForSend 发送过程中清理 unknown 状态的短信时，等待发送完成，不删除刚创建的短信
+func TestPurgeWaitsForSend(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "mmcli"), []byte(fakeMMCLI), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	calls := func() string {
+		data, _ := os.ReadFile(filepath.Join(dir, "calls"))
+		return string(data)
+	}
+
+	m := NewManager("0")
+	sent := make(chan error, 1)
+	go func() { sent <- m.SendSMS("10086", "查询余额") }()
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(calls(), "--messaging-create-sms") {
+		if time.Now().After(deadline) {
+			t.Fatal("等待创建短信超时")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	deleted, err := m.PurgeSMS([]string{"unknown"})
+	if err != nil {
+		t.Fatalf("PurgeSMS 返回错误: %v", err)
+	}
+	if err := <-sent; err != nil {
+		t.Fatalf("SendSMS 返回错误: %v", err)
+	}
+	if deleted != 0 {
+		t.Errorf("清理了 %d 条短信，发送中的短信不应被清理", deleted)
+	}
+	log := calls()
+	if send, del := strings.Index(log, "--send"), strings.Index(log, "--messaging-delete-sms"); send < 0 || del < send {
+		t.Errorf("短信在发送之前被删除:\n%s", log)
+	}
+}
@@ -0,0 +1,102 @@
+// Package modem 提供与 ModemManager 调制解调器交互的功能
+package modem
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"sim-sms-forward/pkg/logger"
+	"sim-sms-forward/pkg/types"
+)
+
+// smsPathPattern 匹配任意状态的短信对象路径
+var smsPathPattern = regexp.MustCompile(`/org/freedesktop/ModemManager1/SMS/(\d+)`)
+
+// parseKeyValue 解析 mmcli -K 的键值对输出
+// 每行格式为 "modem.generic.state    : registered"，值为 "--" 表示空
+// 返回: 键到值的映射
+func parseKeyValue(output string) map[string]string {
+	values := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		idx := strings.Index(line, ":")
+		if idx < 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:idx])
+		value := strings.TrimSpace(line[idx+1:])
+		if value == "--" {
+			value = ""
+		}
+		if key != "" {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// GetStatus 获取调制解调器和 SIM 卡的运行状态
+// 执行 mmcli -m <ID> -K 获取机器可读的状态信息，并统计调制解调器上保存的短信数量
+// 返回: ModemStatus 结构体指针和可能的错误
+func (m *Manager) GetStatus() (*types.ModemStatus, error) {
+	output, err := mmcli("status", "-m", m.ID(), "-K")
+	if err != nil {
+		logger.Errorf("获取调制解调器 %s 状态失败: %v", m.ID(), err)
+		return nil, fmt.Errorf("获取调制解调器 %s 状态失败: %v", m.ID(), err)
+	}
+
+	kv := parseKeyValue(string(output))
+	status := &types.ModemStatus{
+		ModemID:           m.ID(),
+		State:             kv["modem.generic.state"],
+		FailedReason:      kv["modem.generic.state-failed-reason"],
+		AccessTech:        kv["modem.generic.access-technologies.value[1]"],
+		RegistrationState: kv["modem.3gpp.registration-state"],
+		OperatorName:      kv["modem.3gpp.operator-name"],
+		OperatorCode:      kv["modem.3gpp.operator-code"],
+		IMEI:              kv["modem.3gpp.imei"],
+		SIMPath:           kv["modem.generic.sim"],
+	}
+	if status.FailedReason == "unknown" {
+		status.FailedReason = ""
+	}
+	if quality, err := strconv.Atoi(kv["modem.generic.signal-quality.value"]); err == nil {
+		status.SignalQuality = quality
+	}
+
+	// 统计所有状态的短信数量，用于估算存储占用
+	if listOutput, err := mmcli("list", "-m", m.ID(), "--messaging-list-sms"); err == nil {
+		status.StoredSMS = len(smsPathPattern.FindAllString(string(listOutput), -1))
+	}
+
+	return status, nil
+}
+
+// GetSIMInfo 获取调制解调器当前 SIM 卡的识别信息
+// 先通过 mmcli -m <ID> -K 获取 SIM 卡路径和本机号码，再执行 mmcli -i <SIM路径> -K 读取 ICCID 等信息
+// 返回: SIMInfo 结构体指针和可能的错误，未插入 SIM 卡时返回错误
+func (m *Manager) GetSIMInfo() (*types.SIMInfo, error) {
+	output, err := mmcli("status", "-m", m.ID(), "-K")
+	if err != nil {
+		return nil, fmt.Errorf("获取调制解调器 %s 状态失败: %v", m.ID(), err)
+	}
+	modemKV := parseKeyValue(string(output))
+	simPath := modemKV["modem.generic.sim"]
+	if simPath == "" {
+		return nil, fmt.Errorf("调制解调器 %s 未检测到 SIM 卡", m.ID())
+	}
+
+	simOutput, err := mmcli("sim", "-i", simPath, "-K")
+	if err != nil {
+		return nil, fmt.Errorf("获取 SIM 卡 %s 信息失败: %v", simPath, err)
+	}
+	simKV := parseKeyValue(string(simOutput))
+
+	return &types.SIMInfo{
+		ICCID:        simKV["sim.properties.iccid"],
+		IMSI:         simKV["sim.properties.imsi"],
+		OperatorName: simKV["sim.properties.operator-name"],
+		OwnNumbers:   modemKV["modem.generic.own-numbers.value[1]"],
+	}, nil
+}
@@ -0,0 +1,171 @@
+package modem
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"sim-sms-forward/pkg/logger"
+	"sim-sms-forward/pkg/types"
+)
+
+// StorageConfig 短信存储检查的配置
+type StorageConfig struct {
+	Enable    bool     `json:"enable"`              // 是否定期检查短信存储
+	Threshold int      `json:"threshold,omitempty"` // 使用率达到这个百分比时告警，默认 80
+	Capacity  int      `json:"capacity,omitempty"`  // 无法通过 AT+CPMS? 读取容量时使用的存储容量（条），0 表示未知
+	Purge     []string `json:"purge,omitempty"`     // 定期删除的短信状态，可选 sent、stored、unknown
+	Notifiers []string `json:"notifiers,omitempty"` // 接收告警的通知渠道，为空时使用所有渠道（MQTT 除外）
+}
+
+// PurgeableStates 可以清理的短信状态
+// received 状态的短信由处理流程转发后删除，receiving、sending 状态的短信还在传输中，都不能清理
+var PurgeableStates = []string{"sent", "stored", "unknown"}
+
+// Validate 验证短信存储检查配置，通知渠道是否存在由调用方检查
+func (c *StorageConfig) Validate() error {
+	if c.Threshold < 0 || c.Threshold > 100 {
+		return fmt.Errorf("storage.threshold 应在 0-100 之间")
+	}
+	if c.Capacity < 0 {
+		return fmt.Errorf("storage.capacity 不能小于 0")
+	}
+	for _, state := range c.Purge {
+		if !slices.Contains(PurgeableStates, state) {
+			return fmt.Errorf("storage.purge 不支持的短信状态: %s，可选 %s", state, strings.Join(PurgeableStates, "、"))
+		}
+	}
+	return nil
+}
+
+// smsEntryPattern 匹配短信列表中的对象路径和状态
+// 匹配格式: /org/freedesktop/ModemManager1/SMS/<数字> (<状态>)
+var smsEntryPattern = regexp.MustCompile(`/org/freedesktop/ModemManager1/SMS/(\d+)\s+\(([\w-]+)\)`)
+
+// cpmsPattern 匹配 AT+CPMS? 响应中的存储名称、已用条数和容量
+var cpmsPattern = regexp.MustCompile(`"(\w+)"\s*,\s*(\d+)\s*,\s*(\d+)`)
+
+// SMSEntry 调制解调器上的一条短信
+type SMSEntry struct {
+	ID    string
+	State string // 短信状态，如 received、sent、stored、unknown
+}
+
+// ListSMS 获取调制解调器上所有状态的短信
+// 返回: 短信ID和状态的列表和可能的错误
+func (m *Manager) ListSMS() ([]SMSEntry, error) {
+	output, err := mmcli("list", "-m", m.ID(), "--messaging-list-sms")
+	if err != nil {
+		return nil, fmt.Errorf("获取短信列表失败: %v", err)
+	}
+	var entries []SMSEntry
+	for _, match := range smsEntryPattern.FindAllStringSubmatch(string(output), -1) {
+		entries = append(entries, SMSEntry{ID: match[1], State: match[2]})
+	}
+	return entries, nil
+}
+
+// GetStorageStatus 获取短信存储的使用情况
+// 执行 mmcli -m <ID> --messaging-status -K 获取支持的存储和默认存储，
+// 再执行 mmcli -m <ID> --command=AT+CPMS? 读取各存储的已用条数和容量。
+// AT 命令需要 ModemManager 以 --debug 模式运行，失败时以调制解调器上的短信总数作为已用条数，
+// 以 capacity 作为容量
+// 参数: capacity - 无法读取容量时使用的存储容量，0 表示未知
+// 返回: StorageStatus 结构体指针和可能的错误
+func (m *Manager) GetStorageStatus(capacity int) (*types.StorageStatus, error) {
+	output, err := mmcli("storage", "-m", m.ID(), "--messaging-status", "-K")
+	if err != nil {
+		return nil, fmt.Errorf("获取调制解调器 %s 短信存储状态失败: %v", m.ID(), err)
+	}
+	kv := parseKeyValue(string(output))
+	status := &types.StorageStatus{
+		DefaultStorage: kv["modem.messaging.default-storage"],
+		States:         make(map[string]int),
+	}
+	for i := 1; ; i++ {
+		storage, ok := kv[fmt.Sprintf("modem.messaging.supported-storages.value[%d]", i)]
+		if !ok {
+			break
+		}
+		status.SupportedStorages = append(status.SupportedStorages, storage)
+	}
+
+	entries, err := m.ListSMS()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		status.States[e.State]++
+	}
+
+	if usage, err := m.readCPMS(); err == nil {
+		status.Storages = usage
+		// 第三组为接收短信使用的存储
+		receive := usage[len(usage)-1]
+		status.Used, status.Total = receive.Used, receive.Total
+	} else {
+		status.Used, status.Total = len(entries), capacity
+	}
+	return status, nil
+}
+
+// readCPMS 通过 AT+CPMS? 读取各存储的用量
+// 响应格式: +CPMS: "SM",5,50,"SM",5,50,"SM",5,50，依次为读取删除、写入发送、接收使用的存储
+func (m *Manager) readCPMS() ([]types.StorageUsage, error) {
+	output, err := mmcli("command", "-m", m.ID(), "--command=AT+CPMS?")
+	if err != nil {
+		return nil, fmt.Errorf("执行 AT+CPMS? 失败: %v", err)
+	}
+	_, response, ok := strings.Cut(string(output), "+CPMS:")
+	if !ok {
+		return nil, fmt.Errorf("AT+CPMS? 响应格式不正确: %s", strings.TrimSpace(string(output)))
+	}
+	var usage []types.StorageUsage
+	for _, match := range cpmsPattern.FindAllStringSubmatch(response, -1) {
+		used, _ := strconv.Atoi(match[2])
+		total, _ := strconv.Atoi(match[3])
+		usage = append(usage, types.StorageUsage{Name: match[1], Used: used, Total: total})
+	}
+	if len(usage) == 0 {
+		return nil, fmt.Errorf("AT+CPMS? 响应格式不正确: %s", strings.TrimSpace(string(output)))
+	}
+	return usage, nil
+}
+
+// PurgeSMS 删除调制解调器上指定状态的短信，用于清理不会被处理流程删除的已发送、已保存等短信
+// 参数: states - 要删除的短信状态，只允许 PurgeableStates 中的状态
+// 清理期间等待正在发送的短信完成，不会删除同一进程中刚创建还没有发送的短信
+// 返回: 删除的条数和遇到的第一个错误，单条删除失败时继续删除其他短信
+func (m *Manager) PurgeSMS(states []string) (int, error) {
+	for _, state := range states {
+		if !slices.Contains(PurgeableStates, state) {
+			return 0, fmt.Errorf("不能清理 %s 状态的短信", state)
+		}
+	}
+	m.outgoing.Lock()
+	defer m.outgoing.Unlock()
+	entries, err := m.ListSMS()
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	var firstErr error
+	for _, e := range entries {
+		if !slices.Contains(states, e.State) {
+			continue
+		}
+		if err := m.DeleteSMS(e.ID); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		deleted++
+	}
+	if deleted > 0 {
+		logger.Infof("已清理调制解调器 %s 上 %d 条 %s 状态的短信", m.ID(), deleted, strings.Join(states, "、"))
+	}
+	return deleted, firstErr
+}
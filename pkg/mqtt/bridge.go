@@ -0,0 +1,408 @@
+// Package mqtt 提供一个精简的 MQTT 3.1.1 客户端，以及将短信和调制解调器状态发布到 MQTT 的桥接
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"sim-sms-forward/pkg/logger"
+	"sim-sms-forward/pkg/types"
+)
+
+// Config MQTT 桥接的配置
+type Config struct {
+	Enable          bool   `json:"enable"`                     // 是否启用 MQTT
+	Broker          string `json:"broker"`                     // 服务器地址，如 tcp://192.168.1.2:1883 或 tls://broker:8883
+	ClientID        string `json:"client_id,omitempty"`        // 客户端ID，默认使用设备标识
+	Username        string `json:"username,omitempty"`         // 用户名
+	Password        string `json:"password,omitempty"`         // 密码
+	TopicPrefix     string `json:"topic_prefix,omitempty"`     // 主题前缀，默认 sim-sms-forward/<设备标识>
+	Discovery       bool   `json:"discovery"`                  // 是否发布 Home Assistant 自动发现消息
+	DiscoveryPrefix string `json:"discovery_prefix,omitempty"` // Home Assistant 自动发现前缀，默认 homeassistant
+	StatusInterval  int    `json:"status_interval,omitempty"`  // 调制解调器状态发布间隔（秒），默认 60
+	AllowSend       bool   `json:"allow_send"`                 // 是否允许通过命令主题发送短信
+	SkipVerify      bool   `json:"skip_verify,omitempty"`      // TLS 连接时是否跳过证书校验
+}
+
+// Validate 验证 MQTT 配置
+func (c *Config) Validate() error {
+	if !c.Enable {
+		return nil
+	}
+	if c.Broker == "" {
+		return fmt.Errorf("启用MQTT时，broker 不能为空")
+	}
+	if c.StatusInterval < 0 {
+		return fmt.Errorf("MQTT status_interval 不能小于0")
+	}
+	return nil
+}
+
+// StatusFunc 获取调制解调器状态的函数
+type StatusFunc func() (*types.ModemStatus, error)
+
+// SendFunc 发送短信的函数
+type SendFunc func(number, text string) error
+
+// TrainFunc 将短信内容标记为垃圾短信或正常短信的函数
+type TrainFunc func(content string, spam bool) error
+
+// Command 命令主题接收的消息格式
+type Command struct {
+	Action string `json:"action"` // send_sms（默认）、mark_read、mark_spam 或 mark_ham
+	Number string `json:"number"` // 收信号码
+	Text   string `json:"text"`   // 短信内容，mark_spam/mark_ham 时为要标记的短信内容
+}
+
+// smsPayload 发布到 sms 主题的短信消息
+type smsPayload struct {
+	ID         string               `json:"id"`
+	Sender     string               `json:"sender"`
+	SenderName string               `json:"sender_name,omitempty"`
+	Location   types.NumberLocation `json:"location"`
+	Timestamp  string               `json:"timestamp"`
+	Content    string               `json:"content"`
+	Code       string               `json:"code,omitempty"`
+	Priority   string               `json:"priority"`
+	Spam       bool                 `json:"spam,omitempty"`
+	Replay     bool                 `json:"replay,omitempty"`
+	ModemID    string               `json:"modem_id"`
+	Received   string               `json:"received_at"`
+}
+
+// Bridge 将短信和调制解调器状态发布到 MQTT，并接收发送短信的命令
+// Bridge 实现了 notification.Notifier 接口，可以作为普通通知渠道使用
+type Bridge struct {
+	cfg      Config
+	deviceID string
+	nodeID   string // Home Assistant 设备标识，由设备标识转换而来
+	prefix   string
+	client   *Client
+
+	status StatusFunc
+	send   SendFunc
+	train  TrainFunc
+
+	mu      sync.Mutex
+	unread  int
+	pending [][]byte // 未连接时暂存的短信消息，连接后按顺序发布
+}
+
+// maxPending 未连接时最多暂存的短信消息数，超出时丢弃最早的消息
+const maxPending = 100
+
+// nodeIDPattern Home Assistant 的 node_id 只允许字母、数字、下划线和连字符
+var nodeIDPattern = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// NewBridge 创建 MQTT 桥接
+// 参数:
+//   - cfg: MQTT 配置
+//   - deviceID: 设备标识，用于主题和 Home Assistant 设备名称
+//
+// 返回: 初始化好的 Bridge 指针，调用 Start 后才会连接
+func NewBridge(cfg Config, deviceID string) *Bridge {
+	if deviceID == "" {
+		deviceID = "sim-sms-forward"
+	}
+	if cfg.ClientID == "" {
+		cfg.ClientID = deviceID
+	}
+	if cfg.DiscoveryPrefix == "" {
+		cfg.DiscoveryPrefix = "homeassistant"
+	}
+	if cfg.StatusInterval == 0 {
+		cfg.StatusInterval = 60
+	}
+	prefix := cfg.TopicPrefix
+	if prefix == "" {
+		prefix = "sim-sms-forward/" + deviceID
+	}
+
+	b := &Bridge{
+		cfg:      cfg,
+		deviceID: deviceID,
+		nodeID:   nodeIDPattern.ReplaceAllString(deviceID, "_"),
+		prefix:   strings.TrimRight(prefix, "/"),
+	}
+	b.client = NewClient(ClientOptions{
+		Broker:     cfg.Broker,
+		ClientID:   cfg.ClientID,
+		Username:   cfg.Username,
+		Password:   cfg.Password,
+		SkipVerify: cfg.SkipVerify,
+		Will:       &Will{Topic: b.topic("availability"), Payload: []byte("offline"), Retain: true},
+		OnConnect:  b.onConnect,
+	})
+	return b
+}
+
+// Name 返回通知渠道名称
+func (b *Bridge) Name() string {
+	return "mqtt"
+}
+
+// Start 连接 MQTT 服务器并启动状态发布
+// 参数:
+//   - status: 获取调制解调器状态的函数，为 nil 时不发布状态
+//   - send: 发送短信的函数，为 nil 或未开启 allow_send 时忽略发送命令
+func (b *Bridge) Start(status StatusFunc, send SendFunc) {
+	b.status = status
+	b.send = send
+	_ = b.client.Subscribe(b.topic("command"), b.handleCommand)
+	b.client.Start()
+
+	if status != nil {
+		go func() {
+			ticker := time.NewTicker(time.Duration(b.cfg.StatusInterval) * time.Second)
+			defer ticker.Stop()
+			for range ticker.C {
+				b.PublishStatus()
+			}
+		}()
+	}
+}
+
+// SetTrainFunc 设置垃圾短信标记函数，设置后命令主题支持 mark_spam 和 mark_ham
+func (b *Bridge) SetTrainFunc(train TrainFunc) {
+	b.train = train
+}
+
+// Stop 发布离线状态并断开连接
+func (b *Bridge) Stop() {
+	_ = b.client.Publish(b.topic("availability"), []byte("offline"), true)
+	b.client.Stop()
+}
+
+// topic 拼接完整主题
+func (b *Bridge) topic(name string) string {
+	return b.prefix + "/" + name
+}
+
+// onConnect 每次连接成功后发布在线状态、自动发现配置和当前状态
+func (b *Bridge) onConnect() {
+	if err := b.client.Publish(b.topic("availability"), []byte("online"), true); err != nil {
+		logger.Errorf("发布 MQTT 在线状态失败: %v", err)
+	}
+	if b.cfg.Discovery {
+		b.publishDiscovery()
+	}
+	b.flushPending()
+	b.publishUnread()
+	go b.PublishStatus()
+}
+
+// flushPending 发布未连接时暂存的短信消息，发布失败的消息留到下次连接
+func (b *Bridge) flushPending() {
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+
+	for i, payload := range pending {
+		if err := b.client.Publish(b.topic("sms"), payload, true); err != nil {
+			logger.Errorf("发布暂存的 MQTT 短信消息失败: %v", err)
+			b.mu.Lock()
+			b.pending = append(pending[i:], b.pending...)
+			b.mu.Unlock()
+			return
+		}
+	}
+	if len(pending) > 0 {
+		logger.Infof("已发布 %d 条暂存的 MQTT 短信消息", len(pending))
+	}
+}
+
+// queue 暂存一条短信消息，超出 maxPending 时丢弃最早的消息
+func (b *Bridge) queue(payload []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending = append(b.pending, payload)
+	if len(b.pending) > maxPending {
+		logger.Errorf("MQTT 暂存的短信消息超过 %d 条，丢弃最早的消息", maxPending)
+		b.pending = b.pending[len(b.pending)-maxPending:]
+	}
+}
+
+// SendSMS 将短信以 JSON 格式发布到 sms 主题，并更新未读计数
+// MQTT 只尽力投递：未连接或发布失败时暂存消息，连接后再发布，不返回错误，
+// 避免短信因此留在调制解调器上，下一轮又重复推送到其他渠道
+// 参数: sms - 包含短信信息的 SMS 结构体指针
+// 返回: 只有序列化失败时返回错误
+func (b *Bridge) SendSMS(sms *types.SMS) error {
+	logger.Infof("开始发布 MQTT 短信消息 - 短信 ID: %s, 发送方: %s", sms.ID, logger.Phone(sms.Sender))
+
+	payload, err := json.Marshal(smsPayload{
+		ID:         sms.ID,
+		Sender:     sms.Sender,
+		SenderName: sms.SenderName,
+		Location:   sms.Location,
+		Timestamp:  sms.Timestamp,
+		Content:    sms.Content,
+		Code:       sms.Code,
+		Priority:   sms.Priority.String(),
+		Spam:       sms.Spam,
+		Replay:     sms.Replay,
+		ModemID:    sms.ModemID,
+		Received:   time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		return fmt.Errorf("JSON序列化失败: %v", err)
+	}
+
+	// 保留最后一条短信，Home Assistant 重启后仍能显示
+	b.mu.Lock()
+	b.unread++
+	b.mu.Unlock()
+	if err := b.client.Publish(b.topic("sms"), payload, true); err != nil {
+		logger.Errorf("发布 MQTT 短信消息失败，连接后重新发布: %v", err)
+		b.queue(payload)
+		return nil
+	}
+	b.publishUnread()
+
+	logger.Info("MQTT 短信消息发布成功")
+	return nil
+}
+
+// PublishStatus 获取并发布调制解调器状态
+func (b *Bridge) PublishStatus() {
+	if b.status == nil || !b.client.Connected() {
+		return
+	}
+	status, err := b.status()
+	if err != nil {
+		logger.Errorf("获取调制解调器状态失败，跳过 MQTT 状态发布: %v", err)
+		return
+	}
+	payload, err := json.Marshal(status)
+	if err != nil {
+		logger.Errorf("序列化调制解调器状态失败: %v", err)
+		return
+	}
+	if err := b.client.Publish(b.topic("status"), payload, true); err != nil {
+		logger.Errorf("发布 MQTT 调制解调器状态失败: %v", err)
+	}
+}
+
+// publishUnread 发布未读短信计数
+func (b *Bridge) publishUnread() {
+	b.mu.Lock()
+	unread := b.unread
+	b.mu.Unlock()
+	if err := b.client.Publish(b.topic("unread"), []byte(fmt.Sprint(unread)), true); err != nil {
+		logger.Errorf("发布 MQTT 未读计数失败: %v", err)
+	}
+}
+
+// handleCommand 处理命令主题收到的消息
+// 发送短信可能耗时数秒，在单独的协程中执行，避免阻塞 MQTT 读取
+func (b *Bridge) handleCommand(msg Message) {
+	var cmd Command
+	if err := json.Unmarshal(msg.Payload, &cmd); err != nil {
+		logger.Errorf("解析 MQTT 命令失败: %v", err)
+		return
+	}
+	if cmd.Action == "" {
+		cmd.Action = "send_sms"
+	}
+
+	switch cmd.Action {
+	case "mark_read":
+		b.mu.Lock()
+		b.unread = 0
+		b.mu.Unlock()
+		b.publishUnread()
+	case "mark_spam", "mark_ham":
+		if b.train == nil {
+			logger.Errorf("收到 MQTT %s 命令，但未启用垃圾短信过滤，已忽略", cmd.Action)
+			return
+		}
+		result := map[string]string{"action": cmd.Action, "status": "ok"}
+		if err := b.train(cmd.Text, cmd.Action == "mark_spam"); err != nil {
+			logger.Errorf("执行 MQTT %s 命令失败: %v", cmd.Action, err)
+			result["status"] = "failed"
+			result["error"] = err.Error()
+		}
+		payload, _ := json.Marshal(result)
+		_ = b.client.Publish(b.topic("command/result"), payload, false)
+	case "send_sms":
+		if !b.cfg.AllowSend || b.send == nil {
+			logger.Errorf("收到 MQTT 发送短信命令，但未开启 allow_send，已忽略")
+			return
+		}
+		go func() {
+			result := map[string]string{"number": cmd.Number, "status": "sent"}
+			if err := b.send(cmd.Number, cmd.Text); err != nil {
+				logger.Errorf("执行 MQTT 发送短信命令失败: %v", err)
+				result["status"] = "failed"
+				result["error"] = err.Error()
+			}
+			payload, _ := json.Marshal(result)
+			_ = b.client.Publish(b.topic("command/result"), payload, false)
+		}()
+	default:
+		logger.Errorf("未知的 MQTT 命令: %s", cmd.Action)
+	}
+}
+
+// publishDiscovery 发布 Home Assistant MQTT 自动发现配置
+// 创建 "最新短信"、"信号质量"、"未读短信" 三个传感器实体，归属于同一个设备
+func (b *Bridge) publishDiscovery() {
+	device := map[string]interface{}{
+		"identifiers":  []string{b.nodeID},
+		"name":         b.deviceID,
+		"manufacturer": "sim-sms-forward",
+		"model":        "SIM SMS Forward (mmcli)",
+	}
+	availability := b.topic("availability")
+
+	entities := []struct {
+		objectID string
+		config   map[string]interface{}
+	}{
+		{"last_sms", map[string]interface{}{
+			"name":                  "最新短信",
+			"icon":                  "mdi:message-text",
+			"state_topic":           b.topic("sms"),
+			"value_template":        "{{ value_json.content[:250] }}",
+			"json_attributes_topic": b.topic("sms"),
+		}},
+		{"signal_quality", map[string]interface{}{
+			"name":                  "信号质量",
+			"icon":                  "mdi:signal",
+			"state_topic":           b.topic("status"),
+			"value_template":        "{{ value_json.signal_quality }}",
+			"unit_of_measurement":   "%",
+			"state_class":           "measurement",
+			"json_attributes_topic": b.topic("status"),
+		}},
+		{"unread_count", map[string]interface{}{
+			"name":        "未读短信",
+			"icon":        "mdi:message-badge",
+			"state_topic": b.topic("unread"),
+			"state_class": "measurement",
+		}},
+	}
+
+	for _, entity := range entities {
+		cfg := entity.config
+		cfg["unique_id"] = b.nodeID + "_" + entity.objectID
+		cfg["object_id"] = b.nodeID + "_" + entity.objectID
+		cfg["availability_topic"] = availability
+		cfg["device"] = device
+
+		payload, err := json.Marshal(cfg)
+		if err != nil {
+			logger.Errorf("序列化自动发现配置失败: %v", err)
+			continue
+		}
+		topic := fmt.Sprintf("%s/sensor/%s/%s/config", b.cfg.DiscoveryPrefix, b.nodeID, entity.objectID)
+		if err := b.client.Publish(topic, payload, true); err != nil {
+			logger.Errorf("发布自动发现配置 %s 失败: %v", topic, err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"sim-sms-forward/pkg/types"
+)
+
+// broker 测试用的 MQTT 服务器替身，只接受一个连接，记录收到的 PUBLISH 报文
+type broker struct {
+	ln        net.Listener
+	published chan Message
+}
+
+// newBroker 在本地随机端口启动服务器替身
+func newBroker(t *testing.T) *broker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	b := &broker{ln: ln, published: make(chan Message, 100)}
+	t.Cleanup(func() { ln.Close() })
+	go b.serve()
+	return b
+}
+
+func (b *broker) url() string {
+	return "tcp://" + b.ln.Addr().String()
+}
+
+func (b *broker) serve() {
+	conn, err := b.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		header, body, err := readPacket(reader)
+		if err != nil {
+			return
+		}
+		switch header >> 4 {
+		case packetConnect:
+			_, _ = conn.Write([]byte{packetConnack << 4, 2, 0, 0})
+		case packetSubscribe:
+			_, _ = conn.Write([]byte{packetSuback << 4, 3, body[0], body[1], 0})
+		case packetPingreq:
+			_, _ = conn.Write([]byte{packetPingresp << 4, 0})
+		case packetPublish:
+			topic, payload, err := readString(body)
+			if err == nil {
+				b.published <- Message{Topic: topic, Payload: payload}
+			}
+		}
+	}
+}
+
+// next 等待发布到指定主题的下一条消息，忽略其他主题
+func (b *broker) next(t *testing.T, topic string) Message {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg := <-b.published:
+			if msg.Topic == topic {
+				return msg
+			}
+		case <-timeout:
+			t.Fatalf("等待主题 %s 的消息超时", topic)
+		}
+	}
+}
+
+func TestBridgeSendSMS(t *testing.T) {
+	srv := newBroker(t)
+	bridge := NewBridge(Config{Enable: true, Broker: srv.url()}, "test")
+	bridge.Start(nil, nil)
+	defer bridge.client.Stop()
+
+	srv.next(t, "sim-sms-forward/test/availability")
+	if err := bridge.SendSMS(&types.SMS{ID: "1", Sender: "10086", Content: "余额 12.30 元"}); err != nil {
+		t.Fatalf("SendSMS 返回错误: %v", err)
+	}
+	msg := srv.next(t, "sim-sms-forward/test/sms")
+	var payload smsPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		t.Fatalf("解析短信消息失败: %v", err)
+	}
+	if payload.Sender != "10086" || payload.Content != "余额 12.30 元" {
+		t.Errorf("短信消息不正确: %+v", payload)
+	}
+	if unread := srv.next(t, "sim-sms-forward/test/unread"); string(unread.Payload) != "1" {
+		t.Errorf("未读计数为 %s，期望 1", unread.Payload)
+	}
+}
+
+// TestBridgeSendSMSOffline 未连接时 SendSMS 不返回错误，消息在连接后按顺序发布
+func TestBridgeSendSMSOffline(t *testing.T) {
+	srv := newBroker(t)
+	bridge := NewBridge(Config{Enable: true, Broker: srv.url()}, "test")
+	for _, id := range []string{"1", "2"} {
+		if err := bridge.SendSMS(&types.SMS{ID: id, Sender: "10086"}); err != nil {
+			t.Fatalf("未连接时 SendSMS 返回错误: %v", err)
+		}
+	}
+
+	bridge.Start(nil, nil)
+	defer bridge.client.Stop()
+	for _, want := range []string{"1", "2"} {
+		var payload smsPayload
+		if err := json.Unmarshal(srv.next(t, "sim-sms-forward/test/sms").Payload, &payload); err != nil {
+			t.Fatalf("解析短信消息失败: %v", err)
+		}
+		if payload.ID != want {
+			t.Errorf("短信 ID 为 %s，期望 %s", payload.ID, want)
+		}
+	}
+	if unread := srv.next(t, "sim-sms-forward/test/unread"); string(unread.Payload) != "2" {
+		t.Errorf("未读计数为 %s，期望 2", unread.Payload)
+	}
+}
+
+func TestBridgeQueueLimit(t *testing.T) {
+	bridge := NewBridge(Config{Enable: true, Broker: "tcp://127.0.0.1:1"}, "test")
+	for i := 0; i < maxPending+5; i++ {
+		_ = bridge.SendSMS(&types.SMS{ID: "x"})
+	}
+	if len(bridge.pending) != maxPending {
+		t.Errorf("暂存 %d 条消息，期望 %d", len(bridge.pending), maxPending)
+	}
+}
+
+func TestTopicMatches(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"a/b/command", "a/b/command", true},
+		{"a/+/command", "a/b/command", true},
+		{"a/#", "a/b/command", true},
+		{"a/b/command", "a/b/command/result", false},
+		{"a/+", "a/b/c", false},
+	}
+	for _, tt := range tests {
+		if got := topicMatches(tt.filter, tt.topic); got != tt.want {
+			t.Errorf("topicMatches(%q, %q) = %v，期望 %v", tt.filter, tt.topic, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,494 @@
+// Package mqtt 提供一个精简的 MQTT 3.1.1 客户端，以及将短信和调制解调器状态发布到 MQTT 的桥接
+// 客户端只实现本项目需要的功能：QoS 0 发布/订阅、遗嘱消息、心跳和断线重连
+package mqtt
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"sim-sms-forward/pkg/logger"
+)
+
+// MQTT 控制报文类型
+const (
+	packetConnect    byte = 1
+	packetConnack    byte = 2
+	packetPublish    byte = 3
+	packetPuback     byte = 4
+	packetSubscribe  byte = 8
+	packetSuback     byte = 9
+	packetPingreq    byte = 12
+	packetPingresp   byte = 13
+	packetDisconnect byte = 14
+)
+
+// Message 收到的 MQTT 消息
+type Message struct {
+	Topic   string
+	Payload []byte
+}
+
+// Handler 订阅消息的处理函数
+type Handler func(msg Message)
+
+// Will 遗嘱消息，客户端异常断开时由服务器代为发布
+type Will struct {
+	Topic   string
+	Payload []byte
+	Retain  bool
+}
+
+// ClientOptions MQTT 客户端选项
+type ClientOptions struct {
+	Broker     string        // 服务器地址，如 tcp://127.0.0.1:1883 或 tls://broker:8883
+	ClientID   string        // 客户端ID
+	Username   string        // 用户名
+	Password   string        // 密码
+	KeepAlive  time.Duration // 心跳间隔，默认 60 秒
+	Will       *Will         // 遗嘱消息，可为空
+	SkipVerify bool          // TLS 连接时是否跳过证书校验
+	OnConnect  func()        // 每次连接（包括重连）成功后调用，用于重新发布在线状态等
+}
+
+// Client MQTT 客户端
+// 调用 Start 后在后台维持连接，断线后自动重连并恢复订阅
+type Client struct {
+	opts ClientOptions
+
+	mu        sync.Mutex
+	conn      net.Conn
+	writeMu   sync.Mutex
+	handlers  map[string]Handler // 订阅的主题过滤器 -> 处理函数
+	packetID  uint16
+	connected bool
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewClient 创建 MQTT 客户端
+// 参数: opts - 客户端选项
+// 返回: 初始化好的 Client 指针
+func NewClient(opts ClientOptions) *Client {
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = 60 * time.Second
+	}
+	return &Client{
+		opts:     opts,
+		handlers: make(map[string]Handler),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start 在后台建立连接并维持，断线后按指数退避重连
+func (c *Client) Start() {
+	go c.run()
+}
+
+// Stop 断开连接并停止重连
+func (c *Client) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+		c.mu.Lock()
+		conn := c.conn
+		c.mu.Unlock()
+		if conn != nil {
+			_ = c.writePacket(conn, packetDisconnect<<4, nil)
+			conn.Close()
+		}
+	})
+}
+
+// Connected 返回当前是否已连接到服务器
+func (c *Client) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected
+}
+
+// Subscribe 订阅主题，连接断开重连后会自动重新订阅
+// 参数:
+//   - filter: 主题过滤器，支持 + 和 # 通配符
+//   - handler: 消息处理函数
+func (c *Client) Subscribe(filter string, handler Handler) error {
+	c.mu.Lock()
+	c.handlers[filter] = handler
+	conn := c.conn
+	c.mu.Unlock()
+
+	if conn == nil {
+		// 尚未连接，连接成功后统一订阅
+		return nil
+	}
+	return c.sendSubscribe(conn, filter)
+}
+
+// Publish 以 QoS 0 发布消息
+// 参数:
+//   - topic: 主题
+//   - payload: 消息内容
+//   - retain: 是否保留消息
+//
+// 返回: 未连接或写入失败时返回错误
+func (c *Client) Publish(topic string, payload []byte, retain bool) error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return errors.New("MQTT 未连接")
+	}
+
+	header := packetPublish << 4
+	if retain {
+		header |= 0x01
+	}
+	body := appendString(nil, topic)
+	body = append(body, payload...)
+	return c.writePacket(conn, header, body)
+}
+
+// run 连接维持循环
+func (c *Client) run() {
+	backoff := time.Second
+	for {
+		select {
+		case <-c.stop:
+			return
+		default:
+		}
+
+		conn, reader, err := c.connect()
+		if err != nil {
+			logger.Errorf("连接 MQTT 服务器 %s 失败: %v，%v 后重试", c.opts.Broker, err, backoff)
+			select {
+			case <-c.stop:
+				return
+			case <-time.After(backoff):
+			}
+			if backoff < 2*time.Minute {
+				backoff *= 2
+			}
+			continue
+		}
+		backoff = time.Second
+		logger.Infof("已连接到 MQTT 服务器 %s", c.opts.Broker)
+
+		c.mu.Lock()
+		c.conn = conn
+		c.connected = true
+		filters := make([]string, 0, len(c.handlers))
+		for filter := range c.handlers {
+			filters = append(filters, filter)
+		}
+		c.mu.Unlock()
+
+		for _, filter := range filters {
+			if err := c.sendSubscribe(conn, filter); err != nil {
+				logger.Errorf("订阅 MQTT 主题 %s 失败: %v", filter, err)
+			}
+		}
+		if c.opts.OnConnect != nil {
+			c.opts.OnConnect()
+		}
+
+		done := make(chan struct{})
+		go c.keepAlive(conn, done)
+		err = c.readLoop(conn, reader)
+		close(done)
+
+		c.mu.Lock()
+		c.conn = nil
+		c.connected = false
+		c.mu.Unlock()
+		conn.Close()
+
+		select {
+		case <-c.stop:
+			return
+		default:
+			logger.Errorf("MQTT 连接断开: %v", err)
+		}
+	}
+}
+
+// connect 建立网络连接并完成 CONNECT/CONNACK 握手
+// 返回连接和后续读取报文使用的缓冲读取器
+func (c *Client) connect() (net.Conn, *bufio.Reader, error) {
+	u, err := url.Parse(c.opts.Broker)
+	if err != nil {
+		return nil, nil, fmt.Errorf("MQTT 服务器地址无效: %v", err)
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	switch u.Scheme {
+	case "tcp", "mqtt", "":
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "1883")
+		}
+		conn, err = dialer.Dial("tcp", host)
+	case "tls", "ssl", "mqtts":
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "8883")
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, &tls.Config{
+			ServerName:         u.Hostname(),
+			InsecureSkipVerify: c.opts.SkipVerify,
+		})
+	default:
+		return nil, nil, fmt.Errorf("不支持的 MQTT 协议: %s", u.Scheme)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := c.writePacket(conn, packetConnect<<4, c.connectBody()); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	header, body, err := readPacket(reader)
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("读取 CONNACK 失败: %v", err)
+	}
+	if header>>4 != packetConnack || len(body) < 2 {
+		conn.Close()
+		return nil, nil, fmt.Errorf("期望 CONNACK，收到报文类型 %d", header>>4)
+	}
+	if body[1] != 0 {
+		conn.Close()
+		return nil, nil, fmt.Errorf("服务器拒绝连接，返回码 %d", body[1])
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	return conn, reader, nil
+}
+
+// connectBody 构建 CONNECT 报文的可变头和有效载荷
+func (c *Client) connectBody() []byte {
+	var flags byte = 0x02 // clean session
+	if c.opts.Will != nil {
+		flags |= 0x04
+		if c.opts.Will.Retain {
+			flags |= 0x20
+		}
+	}
+	if c.opts.Username != "" {
+		flags |= 0x80
+		if c.opts.Password != "" {
+			flags |= 0x40
+		}
+	}
+
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(c.opts.KeepAlive/time.Second))
+	body = appendString(body, c.opts.ClientID)
+	if c.opts.Will != nil {
+		body = appendString(body, c.opts.Will.Topic)
+		body = binary.BigEndian.AppendUint16(body, uint16(len(c.opts.Will.Payload)))
+		body = append(body, c.opts.Will.Payload...)
+	}
+	if c.opts.Username != "" {
+		body = appendString(body, c.opts.Username)
+		if c.opts.Password != "" {
+			body = appendString(body, c.opts.Password)
+		}
+	}
+	return body
+}
+
+// sendSubscribe 发送 QoS 0 的 SUBSCRIBE 报文
+func (c *Client) sendSubscribe(conn net.Conn, filter string) error {
+	c.mu.Lock()
+	c.packetID++
+	if c.packetID == 0 {
+		c.packetID = 1
+	}
+	id := c.packetID
+	c.mu.Unlock()
+
+	body := binary.BigEndian.AppendUint16(nil, id)
+	body = appendString(body, filter)
+	body = append(body, 0)
+	return c.writePacket(conn, packetSubscribe<<4|0x02, body)
+}
+
+// keepAlive 按心跳间隔发送 PINGREQ
+func (c *Client) keepAlive(conn net.Conn, done chan struct{}) {
+	ticker := time.NewTicker(c.opts.KeepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.writePacket(conn, packetPingreq<<4, nil); err != nil {
+				conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// readLoop 读取服务器报文，分发收到的消息
+// 超过 1.5 倍心跳间隔没有收到任何报文时认为连接已断开
+func (c *Client) readLoop(conn net.Conn, reader *bufio.Reader) error {
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(c.opts.KeepAlive * 3 / 2))
+		header, body, err := readPacket(reader)
+		if err != nil {
+			return err
+		}
+
+		switch header >> 4 {
+		case packetPublish:
+			c.handlePublish(conn, header, body)
+		case packetSuback, packetPingresp, packetPuback:
+			// 无需处理
+		default:
+			logger.Errorf("收到未处理的 MQTT 报文类型 %d", header>>4)
+		}
+	}
+}
+
+// handlePublish 解析 PUBLISH 报文并调用匹配的处理函数
+func (c *Client) handlePublish(conn net.Conn, header byte, body []byte) {
+	topic, rest, err := readString(body)
+	if err != nil {
+		logger.Errorf("解析 MQTT 消息失败: %v", err)
+		return
+	}
+	qos := (header >> 1) & 0x03
+	if qos > 0 {
+		if len(rest) < 2 {
+			return
+		}
+		id := rest[:2]
+		rest = rest[2:]
+		if qos == 1 {
+			_ = c.writePacket(conn, packetPuback<<4, id)
+		}
+	}
+
+	c.mu.Lock()
+	var matched []Handler
+	for filter, handler := range c.handlers {
+		if topicMatches(filter, topic) {
+			matched = append(matched, handler)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, handler := range matched {
+		handler(Message{Topic: topic, Payload: rest})
+	}
+}
+
+// writePacket 写入一个完整的 MQTT 报文
+func (c *Client) writePacket(conn net.Conn, header byte, body []byte) error {
+	packet := []byte{header}
+	packet = appendRemainingLength(packet, len(body))
+	packet = append(packet, body...)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := conn.Write(packet)
+	return err
+}
+
+// readPacket 读取一个完整的 MQTT 报文，返回固定头首字节和剩余部分
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i >= 4 {
+			return 0, nil, errors.New("剩余长度字段过长")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(b&0x7f) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+// appendRemainingLength 按 MQTT 可变长度编码追加剩余长度
+func appendRemainingLength(buf []byte, length int) []byte {
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if length == 0 {
+			return buf
+		}
+	}
+}
+
+// appendString 追加带两字节长度前缀的 UTF-8 字符串
+func appendString(buf []byte, s string) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
+
+// readString 读取带两字节长度前缀的字符串，返回字符串和剩余数据
+func readString(buf []byte) (string, []byte, error) {
+	if len(buf) < 2 {
+		return "", nil, errors.New("数据过短")
+	}
+	n := int(binary.BigEndian.Uint16(buf))
+	if len(buf) < 2+n {
+		return "", nil, errors.New("字符串长度超出报文")
+	}
+	return string(buf[2 : 2+n]), buf[2+n:], nil
+}
+
+// topicMatches 判断主题是否匹配订阅过滤器（支持 + 和 # 通配符）
+func topicMatches(filter, topic string) bool {
+	fi, ti := 0, 0
+	for fi < len(filter) {
+		switch {
+		case filter[fi] == '#':
+			return true
+		case filter[fi] == '+':
+			for ti < len(topic) && topic[ti] != '/' {
+				ti++
+			}
+			fi++
+		default:
+			if ti >= len(topic) || filter[fi] != topic[ti] {
+				return false
+			}
+			fi++
+			ti++
+		}
+	}
+	return ti == len(topic)
+}
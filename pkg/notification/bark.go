@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 
 	"sim-sms-forward/pkg/logger"
@@ -13,8 +14,16 @@ import (
 
 // BarkClient Bark 通知客户端
 type BarkClient struct {
-	APIKey string // Bark 服务的 API 密钥，用于身份验证
-	APIURL string // Bark API 服务器地址
+	APIKey  string           // Bark 服务的 API 密钥，用于身份验证
+	APIURL  string           // Bark API 服务器地址
+	Success SuccessCondition // 响应成功判定条件，默认要求 JSON 中 code 为 200
+
+	// 以下为可选的推送参数，为空时使用 Bark 默认值
+	ChannelName string // 渠道名称，默认为 "bark"
+	Group       string // 消息分组
+	Level       string // 中断级别: active、timeSensitive、passive、critical
+	Sound       string // 提示音
+	Icon        string // 通知图标 URL
 }
 
 // NewBarkClient 创建一个新的 Bark 通知客户端
@@ -24,8 +33,31 @@ type BarkClient struct {
 // 返回: 初始化好的 BarkClient 指针
 func NewBarkClient(apiKey, apiURL string) *BarkClient {
 	return &BarkClient{
-		APIKey: apiKey,
-		APIURL: apiURL,
+		APIKey:  apiKey,
+		APIURL:  apiURL,
+		Success: SuccessCondition{JSONPath: "code", JSONValue: "200"},
+	}
+}
+
+// Name 返回通知渠道名称
+func (bc *BarkClient) Name() string {
+	if bc.ChannelName != "" {
+		return bc.ChannelName
+	}
+	return "bark"
+}
+
+// barkLevel 将短信优先级映射为 Bark 的中断级别
+// 低优先级使用 passive（不亮屏、不响铃），高优先级和紧急使用 timeSensitive（可突破专注模式），
+// 普通优先级使用配置的级别
+func (bc *BarkClient) barkLevel(p types.Priority) string {
+	switch {
+	case p <= types.PriorityLow:
+		return "passive"
+	case p >= types.PriorityHigh && bc.Level != "critical":
+		return "timeSensitive"
+	default:
+		return bc.Level
 	}
 }
 
@@ -34,15 +66,20 @@ func NewBarkClient(apiKey, apiURL string) *BarkClient {
 // 参数: sms - 包含短信信息的 SMS 结构体指针
 // 返回: 发送成功返回 nil，失败返回错误
 func (bc *BarkClient) SendSMS(sms *types.SMS) error {
-	logger.Infof("开始发送 Bark 通知 - 短信 ID: %s, 发送方: %s", sms.ID, sms.Sender)
+	logger.Infof("开始发送 Bark 通知 - 短信 ID: %s, 发送方: %s", sms.ID, logger.Phone(sms.Sender))
 
 	// 构建通知内容，格式化标题和正文
-	title := fmt.Sprintf("短信转发 %s", sms.Sender)
-	body := fmt.Sprintf("%s\n\n发信电话:%s\n时间:%s", sms.Content, sms.Sender, sms.Timestamp)
+	title := FormatTitle(sms)
+	body := FormatBody(sms)
 
 	barkReq := types.BarkRequest{
 		Body:  body,
 		Title: title,
+		Group: bc.Group,
+		Level: bc.barkLevel(sms.Priority),
+		Sound: bc.Sound,
+		Icon:  bc.Icon,
+		Copy:  sms.Code,
 	}
 
 	// 将请求数据序列化为 JSON 格式
@@ -54,28 +91,35 @@ func (bc *BarkClient) SendSMS(sms *types.SMS) error {
 
 	// 发送 HTTP POST 请求到 Bark API
 	url := fmt.Sprintf("%s/%s", bc.APIURL, bc.APIKey)
-	logger.Infof("发送 Bark 请求到: %s", url)
+	// URL 的路径是设备密钥，日志中只记录服务器地址
+	logger.Infof("发送 Bark 请求到: %s", bc.APIURL)
 
 	resp, err := http.Post(url, "application/json; charset=utf-8", bytes.NewBuffer(jsonData))
 	if err != nil {
+		err = stripURL(err)
 		logger.Errorf("发送 Bark 通知失败: %v", err)
 		return fmt.Errorf("发送 Bark 通知失败: %v", err)
 	}
 	defer resp.Body.Close()
 
 	// 解析 Bark API 的 JSON 响应
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.Errorf("读取 Bark 响应失败: %v", err)
+		return fmt.Errorf("读取 Bark 响应失败: %v", err)
+	}
 	var barkResp types.BarkResponse
-	if err := json.NewDecoder(resp.Body).Decode(&barkResp); err != nil {
+	if err := json.Unmarshal(respBody, &barkResp); err != nil {
 		logger.Errorf("解析 Bark 响应失败: %v", err)
 		return fmt.Errorf("解析 Bark 响应失败: %v", err)
 	}
 
-	// 检查 Bark API 响应状态码，200 表示成功
+	// 按成功条件检查 Bark API 响应，默认要求 code 为 200
 	logger.Infof("Bark 响应: code=%d", barkResp.Code)
 
-	if barkResp.Code != 200 {
-		logger.Errorf("Bark API 返回错误代码: %d", barkResp.Code)
-		return fmt.Errorf("错误：code非200或格式不正确")
+	if err := bc.Success.Check(resp.StatusCode, respBody); err != nil {
+		logger.Errorf("Bark API 返回错误: %v", err)
+		return fmt.Errorf("错误：Bark 返回失败: %v", err)
 	}
 
 	logger.Info("Bark 通知发送成功")
@@ -0,0 +1,24 @@
+package notification
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sim-sms-forward/pkg/types"
+)
+
+// TestBarkErrorHidesKey 请求失败时错误信息中不包含设备密钥
+func TestBarkErrorHidesKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Close()
+
+	err := NewBarkClient("device-key-123", srv.URL).SendSMS(&types.SMS{ID: "1", Sender: "10086", Content: "余额不足"})
+	if err == nil {
+		t.Fatal("服务器已关闭，发送应失败")
+	}
+	if strings.Contains(err.Error(), "device-key-123") {
+		t.Errorf("错误信息泄露了设备密钥: %v", err)
+	}
+}
@@ -0,0 +1,410 @@
+// Package notification 提供通知服务功能，主要是 Bark 推送通知
+package notification
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	texttemplate "text/template"
+
+	"sim-sms-forward/pkg/logger"
+	"sim-sms-forward/pkg/types"
+)
+
+// SMTP 连接的安全模式
+const (
+	EmailSecurityStartTLS = "starttls" // 明文连接后通过 STARTTLS 升级（默认，通常为 587 端口）
+	EmailSecurityTLS      = "tls"      // 直接建立 TLS 连接（通常为 465 端口）
+	EmailSecurityNone     = "none"     // 不加密，仅用于本地中继或测试
+)
+
+// SMTP 认证方式
+const (
+	EmailAuthPlain = "plain" // AUTH PLAIN（默认）
+	EmailAuthLogin = "login" // AUTH LOGIN，部分国内邮箱和 Exchange 只支持该方式
+	EmailAuthNone  = "none"  // 不认证
+)
+
+// EmailConfig 邮件通知的配置
+type EmailConfig struct {
+	Name       string   `json:"name"`                  // 渠道名称，默认为 "email"
+	Host       string   `json:"host"`                  // SMTP 服务器地址
+	Port       int      `json:"port,omitempty"`        // SMTP 端口，默认 starttls 为 587，tls 为 465，none 为 25
+	Security   string   `json:"security,omitempty"`    // 安全模式: starttls、tls、none，默认 starttls
+	Auth       string   `json:"auth,omitempty"`        // 认证方式: plain、login、none，默认 plain
+	Username   string   `json:"username,omitempty"`    // 认证用户名
+	Password   string   `json:"password,omitempty"`    // 认证密码或授权码
+	From       string   `json:"from"`                  // 发件人地址，可以带显示名称，如 "短信转发 <sms@example.com>"
+	To         []string `json:"to"`                    // 收件人地址列表，可以带显示名称
+	Subject    string   `json:"subject,omitempty"`     // 邮件主题模板，默认与其他渠道的通知标题相同
+	SkipVerify bool     `json:"skip_verify,omitempty"` // 是否跳过 TLS 证书校验，仅用于自签名证书的内网服务器
+	Timeout    int      `json:"timeout,omitempty"`     // 连接超时时间（秒），默认 15
+}
+
+// EmailClient SMTP 邮件通知客户端
+// 同一发送方的短信会通过 In-Reply-To/References 头归入同一个邮件会话
+type EmailClient struct {
+	cfg     EmailConfig
+	from    *mail.Address          // 解析后的发件人
+	to      []*mail.Address        // 解析后的收件人
+	subject *texttemplate.Template // 配置的主题模板，未配置时为 nil，使用 FormatTitle
+	domain  string                 // 生成 Message-ID 使用的域名
+
+	mu       sync.Mutex
+	lastSent map[string]string // 发送方号码 -> 该会话最近一封邮件的 Message-ID
+}
+
+// emailHTMLTemplate 邮件 HTML 正文模板，路由规则设置了正文时代替短信内容
+var emailHTMLTemplate = template.Must(template.New("email.html").Parse(`<!DOCTYPE html>
+<html><body style="font-family: -apple-system, 'PingFang SC', 'Microsoft YaHei', sans-serif;">
+<div style="font-size: 16px; white-space: pre-wrap; padding: 12px; border-left: 4px solid #4a90d9; background: #f6f8fa;">{{if .Body}}{{.Body}}{{else}}{{.Content}}{{end}}</div>
+{{if .Code}}<p style="font-size: 20px;">验证码: <b>{{.Code}}</b></p>{{end}}
+<table style="font-size: 13px; color: #666; margin-top: 12px;">
+<tr><td>发信电话:</td><td>{{.DisplaySender}}{{if .SenderName}} ({{.Sender}}){{end}}</td></tr>
+{{with .Location.String}}<tr><td>归属地:</td><td>{{.}}</td></tr>{{end}}
+<tr><td>时间:</td><td>{{.Timestamp}}</td></tr>
+{{if .ModemID}}<tr><td>调制解调器:</td><td>{{.ModemID}}</td></tr>{{end}}
+</table>
+</body></html>
+`))
+
+// NewEmailClient 根据配置创建邮件通知客户端
+// 参数: cfg - 邮件配置
+// 返回: 初始化好的 EmailClient 指针和可能的错误
+func NewEmailClient(cfg EmailConfig) (*EmailClient, error) {
+	if cfg.Name == "" {
+		cfg.Name = "email"
+	}
+	cfg.Security = strings.ToLower(cfg.Security)
+	if cfg.Security == "" {
+		cfg.Security = EmailSecurityStartTLS
+	}
+	cfg.Auth = strings.ToLower(cfg.Auth)
+	if cfg.Auth == "" {
+		cfg.Auth = EmailAuthPlain
+	}
+	if cfg.Port == 0 {
+		switch cfg.Security {
+		case EmailSecurityTLS:
+			cfg.Port = 465
+		case EmailSecurityNone:
+			cfg.Port = 25
+		default:
+			cfg.Port = 587
+		}
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 15
+	}
+
+	switch cfg.Security {
+	case EmailSecurityStartTLS, EmailSecurityTLS, EmailSecurityNone:
+	default:
+		return nil, fmt.Errorf("邮件 %s 不支持的 security: %s", cfg.Name, cfg.Security)
+	}
+	switch cfg.Auth {
+	case EmailAuthPlain, EmailAuthLogin:
+		if cfg.Username == "" {
+			return nil, fmt.Errorf("邮件 %s 启用认证时 username 不能为空", cfg.Name)
+		}
+	case EmailAuthNone:
+	default:
+		return nil, fmt.Errorf("邮件 %s 不支持的 auth: %s", cfg.Name, cfg.Auth)
+	}
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("邮件 %s 的 host 不能为空", cfg.Name)
+	}
+	if cfg.From == "" {
+		return nil, fmt.Errorf("邮件 %s 的 from 不能为空", cfg.Name)
+	}
+	if len(cfg.To) == 0 {
+		return nil, fmt.Errorf("邮件 %s 的 to 不能为空", cfg.Name)
+	}
+
+	var subject *texttemplate.Template
+	if cfg.Subject != "" {
+		var err error
+		if subject, err = ParseTemplate(cfg.Name+".subject", cfg.Subject); err != nil {
+			return nil, err
+		}
+	}
+
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("邮件 %s 的 from 无效: %v", cfg.Name, err)
+	}
+	to := make([]*mail.Address, 0, len(cfg.To))
+	for _, raw := range cfg.To {
+		addr, err := mail.ParseAddress(raw)
+		if err != nil {
+			return nil, fmt.Errorf("邮件 %s 的收件人 %s 无效: %v", cfg.Name, raw, err)
+		}
+		to = append(to, addr)
+	}
+
+	domain := "localhost"
+	if at := strings.LastIndex(from.Address, "@"); at >= 0 {
+		domain = from.Address[at+1:]
+	}
+
+	return &EmailClient{
+		cfg:      cfg,
+		from:     from,
+		to:       to,
+		subject:  subject,
+		domain:   domain,
+		lastSent: make(map[string]string),
+	}, nil
+}
+
+// Name 返回通知渠道名称
+func (ec *EmailClient) Name() string {
+	return ec.cfg.Name
+}
+
+// SendSMS 将短信以 HTML + 纯文本邮件的形式发送给所有收件人
+// 参数: sms - 包含短信信息的 SMS 结构体指针
+// 返回: 发送成功返回 nil，失败返回错误
+func (ec *EmailClient) SendSMS(sms *types.SMS) error {
+	logger.Infof("开始发送邮件(%s)通知 - 短信 ID: %s, 发送方: %s", ec.cfg.Name, sms.ID, logger.Phone(sms.Sender))
+
+	messageID := ec.newMessageID()
+	msg, err := ec.buildMessage(sms, messageID)
+	if err != nil {
+		logger.Errorf("构建邮件失败: %v", err)
+		return err
+	}
+
+	if err := ec.send(msg); err != nil {
+		logger.Errorf("发送邮件(%s)通知失败: %v", ec.cfg.Name, err)
+		return fmt.Errorf("发送邮件(%s)通知失败: %v", ec.cfg.Name, err)
+	}
+
+	ec.mu.Lock()
+	ec.lastSent[sms.Sender] = messageID
+	ec.mu.Unlock()
+
+	logger.Infof("邮件(%s)通知发送成功", ec.cfg.Name)
+	return nil
+}
+
+// threadRootID 返回某个发送方会话的根 Message-ID
+// 根 ID 由发送方号码推导，程序重启后同一发送方的邮件仍然归入同一会话
+func (ec *EmailClient) threadRootID(sender string) string {
+	sum := sha1.Sum([]byte(sender))
+	return fmt.Sprintf("<sms-thread-%s@%s>", hex.EncodeToString(sum[:8]), ec.domain)
+}
+
+// newMessageID 生成新的唯一 Message-ID
+func (ec *EmailClient) newMessageID() string {
+	buf := make([]byte, 8)
+	_, _ = rand.Read(buf)
+	return fmt.Sprintf("<sms-%d-%s@%s>", time.Now().UnixNano(), hex.EncodeToString(buf), ec.domain)
+}
+
+// buildMessage 构建 multipart/alternative 格式的完整邮件
+func (ec *EmailClient) buildMessage(sms *types.SMS, messageID string) ([]byte, error) {
+	// 未配置主题时与其他渠道一致，使用路由规则设置的标题
+	subject := FormatTitle(sms)
+	if ec.subject != nil {
+		rendered, err := RenderTemplate(ec.subject, sms)
+		if err != nil {
+			return nil, err
+		}
+		subject = rendered
+		if sms.Replay {
+			subject = ReplayMark + subject
+		}
+	}
+
+	var htmlBody bytes.Buffer
+	if err := emailHTMLTemplate.Execute(&htmlBody, sms); err != nil {
+		return nil, fmt.Errorf("渲染邮件 HTML 失败: %v", err)
+	}
+
+	// 会话头：References 始终以根 ID 开头，In-Reply-To 指向该会话最近一封邮件
+	root := ec.threadRootID(sms.Sender)
+	ec.mu.Lock()
+	parent, ok := ec.lastSent[sms.Sender]
+	ec.mu.Unlock()
+	references := root
+	if ok {
+		references = root + " " + parent
+	} else {
+		parent = root
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	headers := [][2]string{
+		{"From", ec.from.String()},
+		{"To", joinAddresses(ec.to)},
+		{"Subject", mime.BEncoding.Encode("utf-8", strings.TrimSpace(subject))},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", messageID},
+		{"In-Reply-To", parent},
+		{"References", references},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + writer.Boundary()},
+	}
+	for _, h := range headers {
+		fmt.Fprintf(&buf, "%s: %s\r\n", h[0], h[1])
+	}
+	buf.WriteString("\r\n")
+
+	parts := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=utf-8", FormatBody(sms)},
+		{"text/html; charset=utf-8", htmlBody.String()},
+	}
+	for _, part := range parts {
+		w, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("创建邮件分段失败: %v", err)
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, fmt.Errorf("写入邮件分段失败: %v", err)
+		}
+		if err := qp.Close(); err != nil {
+			return nil, fmt.Errorf("写入邮件分段失败: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("结束邮件分段失败: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// send 连接 SMTP 服务器并投递邮件
+func (ec *EmailClient) send(msg []byte) error {
+	addr := net.JoinHostPort(ec.cfg.Host, strconv.Itoa(ec.cfg.Port))
+	timeout := time.Duration(ec.cfg.Timeout) * time.Second
+	tlsConfig := &tls.Config{ServerName: ec.cfg.Host, InsecureSkipVerify: ec.cfg.SkipVerify}
+
+	var conn net.Conn
+	var err error
+	if ec.cfg.Security == EmailSecurityTLS {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("连接 SMTP 服务器 %s 失败: %v", addr, err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(2 * timeout))
+
+	client, err := smtp.NewClient(conn, ec.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP 握手失败: %v", err)
+	}
+	defer client.Close()
+
+	if ec.cfg.Security == EmailSecurityStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("SMTP 服务器不支持 STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS 失败: %v", err)
+		}
+	}
+
+	switch ec.cfg.Auth {
+	case EmailAuthPlain:
+		if err := client.Auth(smtp.PlainAuth("", ec.cfg.Username, ec.cfg.Password, ec.cfg.Host)); err != nil {
+			return fmt.Errorf("SMTP 认证失败: %v", err)
+		}
+	case EmailAuthLogin:
+		if err := client.Auth(&loginAuth{username: ec.cfg.Username, password: ec.cfg.Password}); err != nil {
+			return fmt.Errorf("SMTP 认证失败: %v", err)
+		}
+	}
+
+	if err := client.Mail(ec.from.Address); err != nil {
+		return fmt.Errorf("MAIL FROM 失败: %v", err)
+	}
+	for _, to := range ec.to {
+		if err := client.Rcpt(to.Address); err != nil {
+			return fmt.Errorf("RCPT TO %s 失败: %v", to.Address, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("DATA 失败: %v", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("写入邮件内容失败: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("提交邮件失败: %v", err)
+	}
+	return client.Quit()
+}
+
+// joinAddresses 生成 To 邮件头，显示名称按 RFC 2047 编码
+func joinAddresses(addrs []*mail.Address) string {
+	list := make([]string, len(addrs))
+	for i, addr := range addrs {
+		list[i] = addr.String()
+	}
+	return strings.Join(list, ", ")
+}
+
+// loginAuth 实现 SMTP AUTH LOGIN 认证，net/smtp 只内置了 PLAIN 和 CRAM-MD5
+type loginAuth struct {
+	username string
+	password string
+}
+
+// Start 开始 LOGIN 认证
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("未加密的连接不允许使用 LOGIN 认证")
+	}
+	return "LOGIN", nil, nil
+}
+
+// Next 根据服务器提示依次返回用户名和密码
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	prompt := strings.ToLower(strings.TrimSpace(string(fromServer)))
+	switch {
+	case strings.HasPrefix(prompt, "username"):
+		return []byte(a.username), nil
+	case strings.HasPrefix(prompt, "password"):
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("未知的 LOGIN 认证提示: %s", fromServer)
+	}
+}
+
+// isLocalhost 判断主机名是否为本机地址
+func isLocalhost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
@@ -0,0 +1,436 @@
+package notification
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sim-sms-forward/pkg/types"
+)
+
+// smtpMail SMTP 服务器替身收到的一封邮件
+type smtpMail struct {
+	TLS  bool   // 投递时连接是否已加密
+	Auth string // 认证方式，未认证时为空
+	From string
+	To   []string
+	Data string
+}
+
+// smtpServer 测试用的 SMTP 服务器替身，支持 STARTTLS、直接 TLS 和 AUTH PLAIN/LOGIN
+type smtpServer struct {
+	ln         net.Listener
+	tlsConfig  *tls.Config
+	noStartTLS bool // 不声明 STARTTLS 扩展
+
+	mu    sync.Mutex
+	mails []smtpMail
+}
+
+const (
+	smtpUser     = "sms@example.com"
+	smtpPassword = "授权码-123"
+)
+
+// newSMTPServer 在本地随机端口启动服务器替身，implicitTLS 为 true 时连接建立后直接进行 TLS 握手
+func newSMTPServer(t *testing.T, implicitTLS bool) *smtpServer {
+	t.Helper()
+	s := &smtpServer{tlsConfig: &tls.Config{Certificates: []tls.Certificate{selfSigned(t)}}}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	if implicitTLS {
+		ln = tls.NewListener(ln, s.tlsConfig)
+	}
+	s.ln = ln
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+	return s
+}
+
+// selfSigned 生成 127.0.0.1 的自签名证书
+func selfSigned(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// port 返回监听的端口
+func (s *smtpServer) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpServer) received() []smtpMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smtpMail(nil), s.mails...)
+}
+
+// handle 处理一个连接上的 SMTP 会话
+func (s *smtpServer) handle(conn net.Conn) {
+	defer func() { conn.Close() }()
+	_, secure := conn.(*tls.Conn)
+	tp := textproto.NewConn(conn)
+	var mail smtpMail
+	_ = tp.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			if !secure && !s.noStartTLS {
+				_ = tp.PrintfLine("250-localhost")
+				_ = tp.PrintfLine("250-STARTTLS")
+			} else {
+				_ = tp.PrintfLine("250-localhost")
+			}
+			_ = tp.PrintfLine("250 AUTH PLAIN LOGIN")
+		case "STARTTLS":
+			_ = tp.PrintfLine("220 ready to start TLS")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, secure = tlsConn, true
+			tp = textproto.NewConn(conn)
+		case "AUTH":
+			mech, initial, _ := strings.Cut(arg, " ")
+			var user, pass string
+			switch strings.ToUpper(mech) {
+			case "PLAIN":
+				data, _ := base64.StdEncoding.DecodeString(initial)
+				if fields := strings.Split(string(data), "\x00"); len(fields) == 3 {
+					user, pass = fields[1], fields[2]
+				}
+			case "LOGIN":
+				user = s.prompt(tp, "Username:")
+				pass = s.prompt(tp, "Password:")
+			}
+			if user != smtpUser || pass != smtpPassword {
+				_ = tp.PrintfLine("535 authentication failed")
+				continue
+			}
+			mail.Auth = strings.ToUpper(mech)
+			_ = tp.PrintfLine("235 authenticated")
+		case "MAIL":
+			mail.From = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			_ = tp.PrintfLine("250 ok")
+		case "RCPT":
+			mail.To = append(mail.To, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			_ = tp.PrintfLine("250 ok")
+		case "DATA":
+			_ = tp.PrintfLine("354 end with .")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			mail.TLS = secure
+			mail.Data = string(data)
+			s.mu.Lock()
+			s.mails = append(s.mails, mail)
+			s.mu.Unlock()
+			mail = smtpMail{Auth: mail.Auth}
+			_ = tp.PrintfLine("250 queued")
+		case "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			return
+		default:
+			_ = tp.PrintfLine("250 ok")
+		}
+	}
+}
+
+// prompt 发送 AUTH LOGIN 的提示并读取客户端的回答
+func (s *smtpServer) prompt(tp *textproto.Conn, text string) string {
+	_ = tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(text)))
+	line, _ := tp.ReadLine()
+	data, _ := base64.StdEncoding.DecodeString(line)
+	return string(data)
+}
+
+func newTestEmailClient(t *testing.T, srv *smtpServer, security, auth string) *EmailClient {
+	t.Helper()
+	client, err := NewEmailClient(EmailConfig{
+		Host:       "127.0.0.1",
+		Port:       srv.port(),
+		Security:   security,
+		Auth:       auth,
+		Username:   smtpUser,
+		Password:   smtpPassword,
+		From:       "forward@example.com",
+		To:         []string{"me@example.com", "backup@example.com"},
+		SkipVerify: true,
+		Timeout:    5,
+	})
+	if err != nil {
+		t.Fatalf("创建邮件客户端失败: %v", err)
+	}
+	return client
+}
+
+// parsedMail 解析后的邮件
+type parsedMail struct {
+	header mail.Header
+	parts  map[string]string // Content-Type 的媒体类型 -> 解码后的内容
+}
+
+func parseMail(t *testing.T, data string) parsedMail {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("解析邮件失败: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type 为 %q，期望 multipart/alternative", msg.Header.Get("Content-Type"))
+	}
+	parsed := parsedMail{header: msg.Header, parts: make(map[string]string)}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("读取邮件分段失败: %v", err)
+		}
+		// multipart.Reader 会自动解码 quoted-printable
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("读取邮件分段失败: %v", err)
+		}
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parsed.parts[contentType] = string(body)
+	}
+	return parsed
+}
+
+// TestEmailStartTLS 通过 STARTTLS 升级后使用 AUTH PLAIN 投递，检查 multipart 正文和会话头
+func TestEmailStartTLS(t *testing.T) {
+	srv := newSMTPServer(t, false)
+	client := newTestEmailClient(t, srv, EmailSecurityStartTLS, EmailAuthPlain)
+
+	sms := []*types.SMS{
+		{ID: "1", Sender: "95588", Content: "您的验证码是 123456，5分钟内有效。<勿泄露>", Code: "123456", Timestamp: "2026-10-18T09:00:00+08:00", ModemID: "0"},
+		{ID: "2", Sender: "95588", Content: "您尾号 1234 的账户支出 12.30 元", Timestamp: "2026-10-18T09:05:00+08:00"},
+		{ID: "3", Sender: "10086", Content: "余额提醒", Timestamp: "2026-10-18T09:10:00+08:00"},
+	}
+	for _, s := range sms {
+		if err := client.SendSMS(s); err != nil {
+			t.Fatalf("发送邮件失败: %v", err)
+		}
+	}
+	mails := srv.received()
+	if len(mails) != 3 {
+		t.Fatalf("收到 %d 封邮件，期望 3", len(mails))
+	}
+	first := mails[0]
+	if !first.TLS || first.Auth != "PLAIN" {
+		t.Errorf("应在 STARTTLS 之后使用 PLAIN 认证，实际 TLS=%v 认证=%q", first.TLS, first.Auth)
+	}
+	if first.From != "forward@example.com" || strings.Join(first.To, ",") != "me@example.com,backup@example.com" {
+		t.Errorf("发件人或收件人不正确: %s -> %v", first.From, first.To)
+	}
+
+	msg := parseMail(t, first.Data)
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.header.Get("Subject"))
+	if err != nil || subject != "短信转发 95588" {
+		t.Errorf("主题为 %q，期望 短信转发 95588", subject)
+	}
+	if text := msg.parts["text/plain"]; !strings.Contains(text, sms[0].Content) || !strings.Contains(text, "发信电话:95588") {
+		t.Errorf("纯文本正文不正确:\n%s", text)
+	}
+	html := msg.parts["text/html"]
+	if !strings.Contains(html, "&lt;勿泄露&gt;") || !strings.Contains(html, "验证码: <b>123456</b>") {
+		t.Errorf("HTML 正文未转义短信内容或缺少验证码:\n%s", html)
+	}
+
+	// 同一发送方的邮件归入同一会话，References 以根 ID 开头，In-Reply-To 指向上一封
+	root := client.threadRootID("95588")
+	if got := msg.header.Get("In-Reply-To"); got != root {
+		t.Errorf("第一封邮件的 In-Reply-To 为 %s，期望会话根 %s", got, root)
+	}
+	if got := msg.header.Get("References"); got != root {
+		t.Errorf("第一封邮件的 References 为 %s，期望 %s", got, root)
+	}
+	second := parseMail(t, mails[1].Data)
+	firstID := msg.header.Get("Message-ID")
+	if got := second.header.Get("In-Reply-To"); got != firstID {
+		t.Errorf("第二封邮件的 In-Reply-To 为 %s，期望 %s", got, firstID)
+	}
+	if got := second.header.Get("References"); got != root+" "+firstID {
+		t.Errorf("第二封邮件的 References 为 %s，期望 %s %s", got, root, firstID)
+	}
+	other := parseMail(t, mails[2].Data)
+	if got := other.header.Get("In-Reply-To"); got != client.threadRootID("10086") || got == root {
+		t.Errorf("其他发送方的邮件应归入自己的会话，In-Reply-To 为 %s", got)
+	}
+}
+
+// TestEmailLogin 使用 AUTH LOGIN 认证
+func TestEmailLogin(t *testing.T) {
+	srv := newSMTPServer(t, false)
+	client := newTestEmailClient(t, srv, EmailSecurityStartTLS, EmailAuthLogin)
+	if err := client.SendSMS(&types.SMS{ID: "1", Sender: "10086", Content: "测试"}); err != nil {
+		t.Fatalf("发送邮件失败: %v", err)
+	}
+	if mails := srv.received(); len(mails) != 1 || mails[0].Auth != "LOGIN" || !mails[0].TLS {
+		t.Errorf("应在 STARTTLS 之后使用 LOGIN 认证，实际为 %+v", mails)
+	}
+
+	client.cfg.Password = "错误的密码"
+	if err := client.SendSMS(&types.SMS{ID: "2", Sender: "10086"}); err == nil || !strings.Contains(err.Error(), "认证失败") {
+		t.Errorf("密码错误时应返回认证失败，实际为 %v", err)
+	}
+}
+
+// TestEmailImplicitTLS 使用直接 TLS 连接（465 端口的方式）投递
+func TestEmailImplicitTLS(t *testing.T) {
+	srv := newSMTPServer(t, true)
+	client := newTestEmailClient(t, srv, EmailSecurityTLS, EmailAuthPlain)
+	if err := client.SendSMS(&types.SMS{ID: "1", Sender: "10086", Content: "测试"}); err != nil {
+		t.Fatalf("发送邮件失败: %v", err)
+	}
+	if mails := srv.received(); len(mails) != 1 || mails[0].Auth != "PLAIN" || !mails[0].TLS {
+		t.Errorf("应通过 TLS 连接使用 PLAIN 认证，实际为 %+v", mails)
+	}
+}
+
+// TestEmailStartTLSUnsupported 服务器不支持 STARTTLS 时不以明文发送密码
+func TestEmailStartTLSUnsupported(t *testing.T) {
+	srv := newSMTPServer(t, false)
+	srv.noStartTLS = true
+	client := newTestEmailClient(t, srv, EmailSecurityStartTLS, EmailAuthPlain)
+	if err := client.SendSMS(&types.SMS{ID: "1", Sender: "10086"}); err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("服务器不支持 STARTTLS 时应返回错误，实际为 %v", err)
+	}
+	if mails := srv.received(); len(mails) != 0 {
+		t.Errorf("不应投递邮件: %+v", mails)
+	}
+}
+
+// TestEmailRuleOverride 未配置主题时使用路由规则的标题，HTML 正文使用规则的正文并显示联系人名称和归属地
+func TestEmailRuleOverride(t *testing.T) {
+	srv := newSMTPServer(t, false)
+	client := newTestEmailClient(t, srv, EmailSecurityStartTLS, EmailAuthPlain)
+
+	sms := &types.SMS{ID: "1", Sender: "13800138000", SenderName: "张三", Content: "晚上一起吃饭", Location: types.NumberLocation{Province: "北京", City: "北京", Carrier: "移动"}}
+	if err := client.SendSMS(sms); err != nil {
+		t.Fatalf("发送邮件失败: %v", err)
+	}
+	override := *sms
+	override.Title = "[家人] 张三"
+	override.Body = "张三: 晚上一起吃饭"
+	override.Replay = true
+	if err := client.SendSMS(&override); err != nil {
+		t.Fatalf("发送邮件失败: %v", err)
+	}
+	mails := srv.received()
+	if len(mails) != 2 {
+		t.Fatalf("收到 %d 封邮件，期望 2", len(mails))
+	}
+
+	plain := parseMail(t, mails[0].Data)
+	if subject, _ := new(mime.WordDecoder).DecodeHeader(plain.header.Get("Subject")); subject != "短信转发 张三" {
+		t.Errorf("主题为 %q，期望 短信转发 张三", subject)
+	}
+	if html := plain.parts["text/html"]; !strings.Contains(html, "张三 (13800138000)") || !strings.Contains(html, "北京 移动") {
+		t.Errorf("HTML 正文缺少联系人名称或归属地:\n%s", html)
+	}
+
+	msg := parseMail(t, mails[1].Data)
+	if subject, _ := new(mime.WordDecoder).DecodeHeader(msg.header.Get("Subject")); subject != ReplayMark+"[家人] 张三" {
+		t.Errorf("主题为 %q，期望使用规则的标题", subject)
+	}
+	if text := msg.parts["text/plain"]; text != override.Body {
+		t.Errorf("纯文本正文为 %q，期望使用规则的正文", text)
+	}
+	if html := msg.parts["text/html"]; !strings.Contains(html, override.Body) {
+		t.Errorf("HTML 正文未使用规则的正文:\n%s", html)
+	}
+}
+
+// TestEmailDisplayName 发件人和收件人带显示名称时，信封只使用地址，邮件头中的名称经过编码
+func TestEmailDisplayName(t *testing.T) {
+	srv := newSMTPServer(t, false)
+	client, err := NewEmailClient(EmailConfig{
+		Host:       "127.0.0.1",
+		Port:       srv.port(),
+		Username:   smtpUser,
+		Password:   smtpPassword,
+		From:       "短信转发 <forward@example.com>",
+		To:         []string{"\"Me\" <me@example.com>", "backup@example.com"},
+		SkipVerify: true,
+		Timeout:    5,
+	})
+	if err != nil {
+		t.Fatalf("创建邮件客户端失败: %v", err)
+	}
+	if err := client.SendSMS(&types.SMS{ID: "1", Sender: "10086", Content: "测试"}); err != nil {
+		t.Fatalf("发送邮件失败: %v", err)
+	}
+	mails := srv.received()
+	if len(mails) != 1 {
+		t.Fatalf("收到 %d 封邮件，期望 1", len(mails))
+	}
+	if mails[0].From != "forward@example.com" || strings.Join(mails[0].To, ",") != "me@example.com,backup@example.com" {
+		t.Errorf("信封发件人或收件人不正确: %s -> %v", mails[0].From, mails[0].To)
+	}
+
+	msg := parseMail(t, mails[0].Data)
+	from, err := mail.ParseAddress(msg.header.Get("From"))
+	if err != nil || from.Name != "短信转发" || from.Address != "forward@example.com" {
+		t.Errorf("From 邮件头为 %q", msg.header.Get("From"))
+	}
+	if to, err := mail.ParseAddressList(msg.header.Get("To")); err != nil || len(to) != 2 || to[0].Name != "Me" {
+		t.Errorf("To 邮件头为 %q", msg.header.Get("To"))
+	}
+	if id := msg.header.Get("Message-ID"); !strings.HasSuffix(id, "@example.com>") {
+		t.Errorf("Message-ID 为 %s，域名应取自发件人地址", id)
+	}
+
+	if _, err := NewEmailClient(EmailConfig{Host: "127.0.0.1", Username: smtpUser, From: "forward", To: []string{"me@example.com"}}); err == nil {
+		t.Error("发件人地址无效时应返回错误")
+	}
+}
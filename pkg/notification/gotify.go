@@ -0,0 +1,115 @@
+// Package notification 提供通知服务功能，主要是 Bark 推送通知
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"sim-sms-forward/pkg/logger"
+	"sim-sms-forward/pkg/types"
+)
+
+// GotifyConfig Gotify 通知的配置
+type GotifyConfig struct {
+	Name     string `json:"name"`               // 渠道名称，默认为 "gotify"
+	URL      string `json:"url"`                // Gotify 服务器地址，如 https://gotify.example.com
+	Token    string `json:"token"`              // 应用令牌（App Token）
+	Priority int    `json:"priority,omitempty"` // 普通短信使用的优先级，默认 5，其他优先级在此基础上调整
+	Timeout  int    `json:"timeout,omitempty"`  // 请求超时时间（秒），默认 10
+}
+
+// GotifyClient Gotify 通知客户端
+type GotifyClient struct {
+	cfg        GotifyConfig
+	httpClient *http.Client
+}
+
+// gotifyMessage Gotify 消息接口的请求体
+type gotifyMessage struct {
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+}
+
+// NewGotifyClient 根据配置创建 Gotify 通知客户端
+// 参数: cfg - Gotify 配置
+// 返回: 初始化好的 GotifyClient 指针和可能的错误
+func NewGotifyClient(cfg GotifyConfig) (*GotifyClient, error) {
+	if cfg.Name == "" {
+		cfg.Name = "gotify"
+	}
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("Gotify %s 的 url 不能为空", cfg.Name)
+	}
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("Gotify %s 的 token 不能为空", cfg.Name)
+	}
+	if cfg.Priority == 0 {
+		cfg.Priority = 5
+	}
+	cfg.URL = strings.TrimRight(cfg.URL, "/")
+	return &GotifyClient{cfg: cfg, httpClient: newHTTPClient(cfg.Timeout)}, nil
+}
+
+// Name 返回通知渠道名称
+func (gc *GotifyClient) Name() string {
+	return gc.cfg.Name
+}
+
+// gotifyPriority 将短信优先级映射为 Gotify 的 0-10 优先级
+// Gotify 客户端通常 1-3 静默、4-7 提示音、8 以上弹出通知
+func (gc *GotifyClient) gotifyPriority(p types.Priority) int {
+	var value int
+	switch {
+	case p <= types.PriorityLow:
+		value = 2
+	case p == types.PriorityNormal:
+		value = gc.cfg.Priority
+	case p == types.PriorityHigh:
+		value = 8
+	default:
+		value = 10
+	}
+	if value < 0 {
+		value = 0
+	}
+	if value > 10 {
+		value = 10
+	}
+	return value
+}
+
+// SendSMS 将短信发送到 Gotify
+// 参数: sms - 包含短信信息的 SMS 结构体指针
+// 返回: 发送成功返回 nil，失败返回错误
+func (gc *GotifyClient) SendSMS(sms *types.SMS) error {
+	logger.Infof("开始发送 Gotify(%s) 通知 - 短信 ID: %s, 发送方: %s", gc.cfg.Name, sms.ID, logger.Phone(sms.Sender))
+
+	jsonData, err := json.Marshal(gotifyMessage{
+		Title:    FormatTitle(sms),
+		Message:  FormatBody(sms),
+		Priority: gc.gotifyPriority(sms.Priority),
+	})
+	if err != nil {
+		logger.Errorf("JSON序列化失败: %v", err)
+		return fmt.Errorf("JSON序列化失败: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, gc.cfg.URL+"/message", bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("创建 HTTP 请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("X-Gotify-Key", gc.cfg.Token)
+
+	if err := doRequest(gc.httpClient, req, SuccessCondition{}); err != nil {
+		logger.Errorf("发送 Gotify(%s) 通知失败: %v", gc.cfg.Name, err)
+		return fmt.Errorf("发送 Gotify(%s) 通知失败: %v", gc.cfg.Name, err)
+	}
+
+	logger.Infof("Gotify(%s) 通知发送成功", gc.cfg.Name)
+	return nil
+}
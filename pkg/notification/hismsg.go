@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 
 	"sim-sms-forward/pkg/logger"
@@ -13,9 +14,13 @@ import (
 
 // HismsgClient Hismsg 通知客户端
 type HismsgClient struct {
-	userKey  string // Hismsg 服务的 API 密钥，用于身份验证
-	APIURL   string // Hismsg API 服务器地址
-	DeviceID string // 设备标识，用于标识不同的设备来源
+	userKey  string           // Hismsg 服务的 API 密钥，用于身份验证
+	APIURL   string           // Hismsg API 服务器地址
+	DeviceID string           // 设备标识，用于标识不同的设备来源
+	Success  SuccessCondition // 响应成功判定条件，默认要求 JSON 中 code 为 200
+
+	ChannelName string   // 渠道名称，默认为 "hismsg"
+	Tags        []string // 消息标签，默认为 ["短信"]
 }
 
 // NewHismsgClient 创建一个新的 Hismsg 通知客户端
@@ -30,7 +35,17 @@ func NewHismsgClient(userKey, apiURL, deviceID string) *HismsgClient {
 		userKey:  userKey,
 		APIURL:   apiURL,
 		DeviceID: deviceID,
+		Success:  SuccessCondition{JSONPath: "code", JSONValue: "200"},
+		Tags:     []string{"短信"},
+	}
+}
+
+// Name 返回通知渠道名称
+func (bc *HismsgClient) Name() string {
+	if bc.ChannelName != "" {
+		return bc.ChannelName
 	}
+	return "hismsg"
 }
 
 // SendSMS 将短信内容发送到 Hismsg 通知服务
@@ -38,18 +53,18 @@ func NewHismsgClient(userKey, apiURL, deviceID string) *HismsgClient {
 // 参数: sms - 包含短信信息的 SMS 结构体指针
 // 返回: 发送成功返回 nil，失败返回错误
 func (bc *HismsgClient) SendSMS(sms *types.SMS) error {
-	logger.Infof("开始发送 Hismsg 通知 - 短信 ID: %s, 发送方: %s", sms.ID, sms.Sender)
+	logger.Infof("开始发送 Hismsg 通知 - 短信 ID: %s, 发送方: %s", sms.ID, logger.Phone(sms.Sender))
 
 	// 构建通知内容，格式化标题和正文
-	title := fmt.Sprintf("短信转发 %s", sms.Sender)
-	body := fmt.Sprintf("%s\n\n发信电话:%s\n时间:%s", sms.Content, sms.Sender, sms.Timestamp)
+	title := FormatTitle(sms)
+	body := FormatBody(sms)
 
 	HismsgReq := types.HismsgRequest{
 		Content: body,
 		Title:   title,
 		Source:  bc.DeviceID,
 		UserKey: bc.userKey,
-		Tags:    []string{"短信"},
+		Tags:    bc.Tags,
 	}
 
 	// 将请求数据序列化为 JSON 格式
@@ -71,18 +86,23 @@ func (bc *HismsgClient) SendSMS(sms *types.SMS) error {
 	defer resp.Body.Close()
 
 	// 解析 Hismsg API 的 JSON 响应
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.Errorf("读取 Hismsg 响应失败: %v", err)
+		return fmt.Errorf("读取 Hismsg 响应失败: %v", err)
+	}
 	var HismsgResp types.HismsgResponse
-	if err := json.NewDecoder(resp.Body).Decode(&HismsgResp); err != nil {
+	if err := json.Unmarshal(respBody, &HismsgResp); err != nil {
 		logger.Errorf("解析 Hismsg 响应失败: %v", err)
 		return fmt.Errorf("解析 Hismsg 响应失败: %v", err)
 	}
 
-	// 检查 Hismsg API 响应状态码，200 表示成功
+	// 按成功条件检查 Hismsg API 响应，默认要求 code 为 200
 	logger.Infof("Hismsg 响应: code=%d", HismsgResp.Code)
 
-	if HismsgResp.Code != 200 {
-		logger.Errorf("Hismsg API 返回错误代码: %d", HismsgResp.Code)
-		return fmt.Errorf("错误：code非200或格式不正确")
+	if err := bc.Success.Check(resp.StatusCode, respBody); err != nil {
+		logger.Errorf("Hismsg API 返回错误: %v", err)
+		return fmt.Errorf("错误：Hismsg 返回失败: %v", err)
 	}
 
 	logger.Info("Hismsg 通知发送成功")
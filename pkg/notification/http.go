@@ -0,0 +1,54 @@
+// Package notification 提供通知服务功能，主要是 Bark 推送通知
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// newHTTPClient 创建带超时的 HTTP 客户端
+// 参数: timeout - 超时时间（秒），小于等于0时使用默认的10秒
+func newHTTPClient(timeout int) *http.Client {
+	if timeout <= 0 {
+		timeout = 10
+	}
+	return &http.Client{Timeout: time.Duration(timeout) * time.Second}
+}
+
+// doRequest 发送 HTTP 请求并按成功条件检查响应
+// 参数:
+//   - client: HTTP 客户端
+//   - req: 要发送的请求
+//   - success: 成功判定条件
+//
+// 返回: 成功返回 nil，失败返回错误
+func doRequest(client *http.Client, req *http.Request, success SuccessCondition) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求失败: %v", stripURL(err))
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %v", err)
+	}
+	if err := success.Check(resp.StatusCode, body); err != nil {
+		return fmt.Errorf("返回失败: %v", err)
+	}
+	return nil
+}
+
+// stripURL 去掉 url.Error 中的完整请求地址，只保留底层错误
+// 部分服务的地址中包含令牌，错误会写入日志、归档和事件记录
+func stripURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
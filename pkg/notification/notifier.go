@@ -0,0 +1,15 @@
+// Package notification 提供通知服务功能，主要是 Bark 推送通知
+package notification
+
+import (
+	"sim-sms-forward/pkg/types"
+)
+
+// Notifier 通知渠道接口
+// 所有推送服务（Bark、Hismsg、Webhook 等）都实现该接口，处理器按顺序调用
+type Notifier interface {
+	// Name 返回通知渠道名称，用于日志和路由
+	Name() string
+	// SendSMS 将短信推送到该渠道，成功返回 nil
+	SendSMS(sms *types.SMS) error
+}
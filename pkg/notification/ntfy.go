@@ -0,0 +1,148 @@
+// Package notification 提供通知服务功能，主要是 Bark 推送通知
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"sim-sms-forward/pkg/logger"
+	"sim-sms-forward/pkg/types"
+)
+
+// NtfyConfig ntfy 通知的配置
+// 认证方式二选一：Username/Password 使用 Basic 认证，Token 使用 Bearer 认证
+type NtfyConfig struct {
+	Name     string   `json:"name"`               // 渠道名称，默认为 "ntfy"
+	URL      string   `json:"url,omitempty"`      // ntfy 服务器地址，默认 https://ntfy.sh
+	Topic    string   `json:"topic"`              // 主题
+	Priority int      `json:"priority,omitempty"` // 普通短信使用的优先级（1-5），默认 3
+	Tags     []string `json:"tags,omitempty"`     // 标签，ntfy 会把 emoji 短代码显示为图标
+	Click    string   `json:"click,omitempty"`    // 点击通知打开的 URL 模板
+	Username string   `json:"username,omitempty"` // Basic 认证用户名
+	Password string   `json:"password,omitempty"` // Basic 认证密码
+	Token    string   `json:"token,omitempty"`    // Bearer 访问令牌
+	Timeout  int      `json:"timeout,omitempty"`  // 请求超时时间（秒），默认 10
+}
+
+// NtfyClient ntfy 通知客户端
+type NtfyClient struct {
+	cfg        NtfyConfig
+	click      *template.Template // 点击 URL 模板，未配置时为 nil
+	httpClient *http.Client
+}
+
+// ntfyMessage ntfy JSON 发布接口的请求体
+type ntfyMessage struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title"`
+	Message  string   `json:"message"`
+	Priority int      `json:"priority"`
+	Tags     []string `json:"tags,omitempty"`
+	Click    string   `json:"click,omitempty"`
+}
+
+// NewNtfyClient 根据配置创建 ntfy 通知客户端
+// 参数: cfg - ntfy 配置
+// 返回: 初始化好的 NtfyClient 指针和可能的错误
+func NewNtfyClient(cfg NtfyConfig) (*NtfyClient, error) {
+	if cfg.Name == "" {
+		cfg.Name = "ntfy"
+	}
+	if cfg.URL == "" {
+		cfg.URL = "https://ntfy.sh"
+	}
+	if cfg.Topic == "" {
+		return nil, fmt.Errorf("ntfy %s 的 topic 不能为空", cfg.Name)
+	}
+	if cfg.Priority == 0 {
+		cfg.Priority = 3
+	}
+	if cfg.Priority < 1 || cfg.Priority > 5 {
+		return nil, fmt.Errorf("ntfy %s 的 priority 必须在 1-5 之间", cfg.Name)
+	}
+	if cfg.Token != "" && cfg.Username != "" {
+		return nil, fmt.Errorf("ntfy %s 的 token 与 username 不能同时配置", cfg.Name)
+	}
+	cfg.URL = strings.TrimRight(cfg.URL, "/")
+
+	nc := &NtfyClient{cfg: cfg, httpClient: newHTTPClient(cfg.Timeout)}
+	if cfg.Click != "" {
+		var err error
+		if nc.click, err = ParseTemplate(cfg.Name+".click", cfg.Click); err != nil {
+			return nil, err
+		}
+	}
+	return nc, nil
+}
+
+// Name 返回通知渠道名称
+func (nc *NtfyClient) Name() string {
+	return nc.cfg.Name
+}
+
+// ntfyPriority 将短信优先级映射为 ntfy 的 1-5 优先级
+// 1=min 2=low 3=default 4=high 5=max
+func (nc *NtfyClient) ntfyPriority(p types.Priority) int {
+	switch {
+	case p <= types.PriorityLow:
+		return 2
+	case p == types.PriorityNormal:
+		return nc.cfg.Priority
+	case p == types.PriorityHigh:
+		return 4
+	default:
+		return 5
+	}
+}
+
+// SendSMS 将短信发布到 ntfy 主题
+// 参数: sms - 包含短信信息的 SMS 结构体指针
+// 返回: 发送成功返回 nil，失败返回错误
+func (nc *NtfyClient) SendSMS(sms *types.SMS) error {
+	logger.Infof("开始发送 ntfy(%s) 通知 - 短信 ID: %s, 发送方: %s", nc.cfg.Name, sms.ID, logger.Phone(sms.Sender))
+
+	msg := ntfyMessage{
+		Topic:    nc.cfg.Topic,
+		Title:    FormatTitle(sms),
+		Message:  FormatBody(sms),
+		Priority: nc.ntfyPriority(sms.Priority),
+		Tags:     nc.cfg.Tags,
+	}
+	if nc.click != nil {
+		click, err := RenderTemplate(nc.click, sms)
+		if err != nil {
+			return err
+		}
+		msg.Click = click
+	}
+
+	jsonData, err := json.Marshal(msg)
+	if err != nil {
+		logger.Errorf("JSON序列化失败: %v", err)
+		return fmt.Errorf("JSON序列化失败: %v", err)
+	}
+
+	// 使用 JSON 发布接口（POST 到服务器根路径），避免中文标题放在请求头中的编码问题
+	req, err := http.NewRequest(http.MethodPost, nc.cfg.URL, bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("创建 HTTP 请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if nc.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+nc.cfg.Token)
+	} else if nc.cfg.Username != "" {
+		req.SetBasicAuth(nc.cfg.Username, nc.cfg.Password)
+	}
+
+	if err := doRequest(nc.httpClient, req, SuccessCondition{}); err != nil {
+		logger.Errorf("发送 ntfy(%s) 通知失败: %v", nc.cfg.Name, err)
+		return fmt.Errorf("发送 ntfy(%s) 通知失败: %v", nc.cfg.Name, err)
+	}
+
+	logger.Infof("ntfy(%s) 通知发送成功", nc.cfg.Name)
+	return nil
+}
@@ -0,0 +1,125 @@
+// Package notification 提供通知服务功能，主要是 Bark 推送通知
+package notification
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"sim-sms-forward/pkg/logger"
+	"sim-sms-forward/pkg/types"
+)
+
+// PushoverConfig Pushover 通知的配置
+type PushoverConfig struct {
+	Name         string `json:"name"`                    // 渠道名称，默认为 "pushover"
+	URL          string `json:"url,omitempty"`           // 消息接口地址，默认 https://api.pushover.net/1/messages.json
+	UserKey      string `json:"user_key"`                // 用户或群组 Key
+	AppToken     string `json:"app_token"`               // 应用 API Token
+	Device       string `json:"device,omitempty"`        // 指定接收设备，为空时推送到全部设备
+	Sound        string `json:"sound,omitempty"`         // 提示音名称
+	EmergencyOTP bool   `json:"emergency_otp,omitempty"` // 是否将验证码短信以紧急优先级（2）发送，需要手动确认
+	Retry        int    `json:"retry,omitempty"`         // 紧急优先级的重复提醒间隔（秒），最小 30，默认 60
+	Expire       int    `json:"expire,omitempty"`        // 紧急优先级的最长提醒时间（秒），最大 10800，默认 600
+	Timeout      int    `json:"timeout,omitempty"`       // 请求超时时间（秒），默认 10
+}
+
+// PushoverClient Pushover 通知客户端
+type PushoverClient struct {
+	cfg        PushoverConfig
+	httpClient *http.Client
+}
+
+// NewPushoverClient 根据配置创建 Pushover 通知客户端
+// 参数: cfg - Pushover 配置
+// 返回: 初始化好的 PushoverClient 指针和可能的错误
+func NewPushoverClient(cfg PushoverConfig) (*PushoverClient, error) {
+	if cfg.Name == "" {
+		cfg.Name = "pushover"
+	}
+	if cfg.URL == "" {
+		cfg.URL = "https://api.pushover.net/1/messages.json"
+	}
+	if cfg.UserKey == "" || cfg.AppToken == "" {
+		return nil, fmt.Errorf("Pushover %s 的 user_key 和 app_token 不能为空", cfg.Name)
+	}
+	if cfg.Retry == 0 {
+		cfg.Retry = 60
+	}
+	if cfg.Expire == 0 {
+		cfg.Expire = 600
+	}
+	if cfg.Retry < 30 {
+		return nil, fmt.Errorf("Pushover %s 的 retry 不能小于 30 秒", cfg.Name)
+	}
+	if cfg.Expire > 10800 {
+		return nil, fmt.Errorf("Pushover %s 的 expire 不能大于 10800 秒", cfg.Name)
+	}
+	return &PushoverClient{cfg: cfg, httpClient: newHTTPClient(cfg.Timeout)}, nil
+}
+
+// Name 返回通知渠道名称
+func (pc *PushoverClient) Name() string {
+	return pc.cfg.Name
+}
+
+// pushoverPriority 将短信优先级映射为 Pushover 的 -2..2 优先级
+// -1 静默推送，0 普通，1 绕过免打扰，2 紧急（重复提醒直到确认）
+func (pc *PushoverClient) pushoverPriority(sms *types.SMS) int {
+	if pc.cfg.EmergencyOTP && sms.Code != "" {
+		return 2
+	}
+	switch {
+	case sms.Priority <= types.PriorityLow:
+		return -1
+	case sms.Priority == types.PriorityNormal:
+		return 0
+	case sms.Priority == types.PriorityHigh:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// SendSMS 将短信发送到 Pushover
+// 参数: sms - 包含短信信息的 SMS 结构体指针
+// 返回: 发送成功返回 nil，失败返回错误
+func (pc *PushoverClient) SendSMS(sms *types.SMS) error {
+	logger.Infof("开始发送 Pushover(%s) 通知 - 短信 ID: %s, 发送方: %s", pc.cfg.Name, sms.ID, logger.Phone(sms.Sender))
+
+	priority := pc.pushoverPriority(sms)
+	form := url.Values{}
+	form.Set("token", pc.cfg.AppToken)
+	form.Set("user", pc.cfg.UserKey)
+	form.Set("title", FormatTitle(sms))
+	form.Set("message", FormatBody(sms))
+	form.Set("priority", strconv.Itoa(priority))
+	if priority == 2 {
+		form.Set("retry", strconv.Itoa(pc.cfg.Retry))
+		form.Set("expire", strconv.Itoa(pc.cfg.Expire))
+	}
+	if pc.cfg.Device != "" {
+		form.Set("device", pc.cfg.Device)
+	}
+	if pc.cfg.Sound != "" {
+		form.Set("sound", pc.cfg.Sound)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, pc.cfg.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("创建 HTTP 请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// Pushover 成功时返回 {"status":1,...}
+	success := SuccessCondition{StatusMin: 200, StatusMax: 299, JSONPath: "status", JSONValue: "1"}
+	if err := doRequest(pc.httpClient, req, success); err != nil {
+		logger.Errorf("发送 Pushover(%s) 通知失败: %v", pc.cfg.Name, err)
+		return fmt.Errorf("发送 Pushover(%s) 通知失败: %v", pc.cfg.Name, err)
+	}
+
+	logger.Infof("Pushover(%s) 通知发送成功 (priority=%d)", pc.cfg.Name, priority)
+	return nil
+}
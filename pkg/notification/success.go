@@ -0,0 +1,87 @@
+// Package notification 提供通知服务功能，主要是 Bark 推送通知
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SuccessCondition 描述推送接口返回成功的判定条件
+// 状态码范围和 JSON 字段检查可以单独使用，也可以组合使用；都未配置时要求 HTTP 状态码为 2xx
+type SuccessCondition struct {
+	StatusMin int    `json:"status_min,omitempty"` // 允许的最小 HTTP 状态码（含）
+	StatusMax int    `json:"status_max,omitempty"` // 允许的最大 HTTP 状态码（含）
+	JSONPath  string `json:"json_path,omitempty"`  // 响应 JSON 中要检查的字段路径，使用点号分隔，如 "data.code"
+	JSONValue string `json:"json_value,omitempty"` // JSONPath 对应字段期望的值（按字符串比较）
+}
+
+// Check 根据 HTTP 状态码和响应体判断推送是否成功
+// 参数:
+//   - status: HTTP 状态码
+//   - body: 响应体
+//
+// 返回: 成功返回 nil，失败返回描述原因的错误
+func (sc SuccessCondition) Check(status int, body []byte) error {
+	min, max := sc.StatusMin, sc.StatusMax
+	if min == 0 && max == 0 && sc.JSONPath == "" {
+		min, max = 200, 299
+	}
+	if min != 0 && status < min {
+		return fmt.Errorf("HTTP 状态码 %d 小于 %d", status, min)
+	}
+	if max != 0 && status > max {
+		return fmt.Errorf("HTTP 状态码 %d 大于 %d", status, max)
+	}
+
+	if sc.JSONPath == "" {
+		return nil
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return fmt.Errorf("解析响应 JSON 失败: %v", err)
+	}
+	value, ok := lookupJSONPath(doc, sc.JSONPath)
+	if !ok {
+		return fmt.Errorf("响应中缺少字段 %s", sc.JSONPath)
+	}
+	if actual := jsonValueString(value); actual != sc.JSONValue {
+		return fmt.Errorf("响应字段 %s=%s，期望 %s", sc.JSONPath, actual, sc.JSONValue)
+	}
+	return nil
+}
+
+// lookupJSONPath 按点号分隔的路径在解析后的 JSON 中查找字段
+func lookupJSONPath(doc interface{}, path string) (interface{}, bool) {
+	current := doc
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = obj[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// jsonValueString 将 JSON 值转换为字符串，数字不带多余的小数位
+func jsonValueString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case nil:
+		return "null"
+	case float64:
+		if val == float64(int64(val)) {
+			return fmt.Sprintf("%d", int64(val))
+		}
+		return fmt.Sprintf("%v", val)
+	default:
+		data, _ := json.Marshal(val)
+		return string(data)
+	}
+}
@@ -0,0 +1,92 @@
+// Package notification 提供通知服务功能，主要是 Bark 推送通知
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"sim-sms-forward/pkg/logger"
+	"sim-sms-forward/pkg/types"
+)
+
+// TelegramConfig Telegram 机器人通知的配置
+type TelegramConfig struct {
+	Name    string   `json:"name"`              // 渠道名称，默认为 "telegram"
+	APIURL  string   `json:"api_url,omitempty"` // Bot API 地址，默认 https://api.telegram.org，可改为自建反向代理
+	Token   string   `json:"token"`             // 机器人令牌，如 123456:ABC-DEF
+	ChatIDs []string `json:"chat_ids"`          // 接收消息的会话ID列表
+	Silent  bool     `json:"silent,omitempty"`  // 是否静默发送（不响铃）
+	Timeout int      `json:"timeout,omitempty"` // 请求超时时间（秒），默认 10
+}
+
+// TelegramClient Telegram 机器人通知客户端
+type TelegramClient struct {
+	cfg        TelegramConfig
+	httpClient *http.Client
+}
+
+// telegramMessage sendMessage 接口的请求体
+type telegramMessage struct {
+	ChatID              string `json:"chat_id"`
+	Text                string `json:"text"`
+	DisableNotification bool   `json:"disable_notification,omitempty"`
+}
+
+// NewTelegramClient 根据配置创建 Telegram 通知客户端
+// 参数: cfg - Telegram 配置
+// 返回: 初始化好的 TelegramClient 指针和可能的错误
+func NewTelegramClient(cfg TelegramConfig) (*TelegramClient, error) {
+	if cfg.Name == "" {
+		cfg.Name = "telegram"
+	}
+	if cfg.APIURL == "" {
+		cfg.APIURL = "https://api.telegram.org"
+	}
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("Telegram %s 的 token 不能为空", cfg.Name)
+	}
+	if len(cfg.ChatIDs) == 0 {
+		return nil, fmt.Errorf("Telegram %s 的 chat_ids 不能为空", cfg.Name)
+	}
+	cfg.APIURL = strings.TrimRight(cfg.APIURL, "/")
+	return &TelegramClient{cfg: cfg, httpClient: newHTTPClient(cfg.Timeout)}, nil
+}
+
+// Name 返回通知渠道名称
+func (tc *TelegramClient) Name() string {
+	return tc.cfg.Name
+}
+
+// SendSMS 将短信发送到所有配置的 Telegram 会话
+// 参数: sms - 包含短信信息的 SMS 结构体指针
+// 返回: 全部发送成功返回 nil，任一会话失败返回错误
+func (tc *TelegramClient) SendSMS(sms *types.SMS) error {
+	logger.Infof("开始发送 Telegram(%s) 通知 - 短信 ID: %s, 发送方: %s", tc.cfg.Name, sms.ID, logger.Phone(sms.Sender))
+
+	text := FormatTitle(sms) + "\n\n" + FormatBody(sms)
+	endpoint := fmt.Sprintf("%s/bot%s/sendMessage", tc.cfg.APIURL, tc.cfg.Token)
+	silent := tc.cfg.Silent || sms.Priority <= types.PriorityLow
+
+	for _, chatID := range tc.cfg.ChatIDs {
+		jsonData, err := json.Marshal(telegramMessage{ChatID: chatID, Text: text, DisableNotification: silent})
+		if err != nil {
+			return fmt.Errorf("JSON序列化失败: %v", err)
+		}
+		req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(jsonData))
+		if err != nil {
+			return fmt.Errorf("创建 HTTP 请求失败: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+		if err := doRequest(tc.httpClient, req, SuccessCondition{JSONPath: "ok", JSONValue: "true"}); err != nil {
+			logger.Errorf("发送 Telegram(%s) 通知到会话 %s 失败: %v", tc.cfg.Name, chatID, err)
+			return fmt.Errorf("发送 Telegram(%s) 通知到会话 %s 失败: %v", tc.cfg.Name, chatID, err)
+		}
+	}
+
+	logger.Infof("Telegram(%s) 通知发送成功", tc.cfg.Name)
+	return nil
+}
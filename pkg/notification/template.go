@@ -0,0 +1,79 @@
+// Package notification 提供通知服务功能，主要是 Bark 推送通知
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/template"
+
+	"sim-sms-forward/pkg/types"
+)
+
+// templateFuncs 模板中可用的辅助函数
+var templateFuncs = template.FuncMap{
+	// json 将任意值序列化为 JSON 字面量，便于在 JSON 请求体模板中安全嵌入短信内容
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	},
+}
+
+// ParseTemplate 解析通知模板
+// 模板的数据对象为 *types.SMS，可以使用 {{.Sender}}、{{.Content}}、{{.Code}} 等字段
+// 解析后使用空短信试渲染一次，引用了不存在的字段时在加载配置时报错，而不是在转发时才失败
+// 参数:
+//   - name: 模板名称，用于错误提示
+//   - text: 模板文本
+//
+// 返回: 解析后的模板和可能的错误
+func ParseTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("解析模板 %s 失败: %v", name, err)
+	}
+	if err := tmpl.Execute(io.Discard, &types.SMS{}); err != nil {
+		return nil, fmt.Errorf("模板 %s 无效: %v", name, err)
+	}
+	return tmpl, nil
+}
+
+// RenderTemplate 使用短信数据渲染模板
+func RenderTemplate(tmpl *template.Template, sms *types.SMS) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, sms); err != nil {
+		return "", fmt.Errorf("渲染模板 %s 失败: %v", tmpl.Name(), err)
+	}
+	return buf.String(), nil
+}
+
+// ReplayMark 重新发送的归档短信在通知标题前加上的标记
+const ReplayMark = "[重发] "
+
+// FormatTitle 生成通知标题，路由规则设置了标题时使用规则渲染的结果
+// 重新发送的归档短信在标题前加上 "[重发]"
+func FormatTitle(sms *types.SMS) string {
+	title := sms.Title
+	if title == "" {
+		title = fmt.Sprintf("短信转发 %s", sms.DisplaySender())
+	}
+	if sms.Replay {
+		title = ReplayMark + title
+	}
+	return title
+}
+
+// FormatBody 生成通知正文，路由规则设置了正文时使用规则渲染的结果
+func FormatBody(sms *types.SMS) string {
+	if sms.Body != "" {
+		return sms.Body
+	}
+	if location := sms.Location.String(); location != "" {
+		return fmt.Sprintf("%s\n\n发信电话:%s\n归属地:%s\n时间:%s", sms.Content, sms.Sender, location, sms.Timestamp)
+	}
+	return fmt.Sprintf("%s\n\n发信电话:%s\n时间:%s", sms.Content, sms.Sender, sms.Timestamp)
+}
@@ -0,0 +1,30 @@
+package notification
+
+import (
+	"strings"
+	"testing"
+
+	"sim-sms-forward/pkg/types"
+)
+
+// TestParseTemplateUnknownField 引用不存在的字段在解析时报错，正常的模板在空短信上也能渲染
+func TestParseTemplateUnknownField(t *testing.T) {
+	if _, err := ParseTemplate("rule.title", "{{.Foo}} 来信"); err == nil || !strings.Contains(err.Error(), "Foo") {
+		t.Errorf("引用不存在的字段应返回错误，实际为 %v", err)
+	}
+	if _, err := ParseTemplate("rule.body", "{{if .Code}}验证码 {{.Code}}{{else}}{{.Content}}{{end}}"); err != nil {
+		t.Errorf("合法的模板返回错误: %v", err)
+	}
+
+	tmpl, err := ParseTemplate("rule.title", "{{.DisplaySender}} {{.Location}} {{json .Content}}")
+	if err != nil {
+		t.Fatalf("解析模板失败: %v", err)
+	}
+	got, err := RenderTemplate(tmpl, &types.SMS{Sender: "10086", Content: "余额", Location: types.NumberLocation{Province: "北京", City: "北京"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != `10086 北京 "余额"` {
+		t.Errorf("渲染结果为 %q", got)
+	}
+}
@@ -0,0 +1,244 @@
+// Package notification 提供通知服务功能，主要是 Bark 推送通知
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"sim-sms-forward/pkg/logger"
+	"sim-sms-forward/pkg/types"
+)
+
+// Webhook 请求体类型
+const (
+	WebhookContentJSON  = "json"  // 请求体为 JSON
+	WebhookContentForm  = "form"  // 请求体为 application/x-www-form-urlencoded
+	WebhookContentQuery = "query" // 字段拼接到 URL 查询参数中，无请求体
+)
+
+// WebhookConfig 通用 Webhook 通知的配置
+// URL、请求头的值、Body 和 Fields 的值都是 text/template 模板，数据对象为 *types.SMS
+type WebhookConfig struct {
+	Name        string            `json:"name"`                   // 渠道名称，默认为 "webhook"
+	Method      string            `json:"method,omitempty"`       // HTTP 方法，默认 POST
+	URL         string            `json:"url"`                    // 请求地址模板
+	Headers     map[string]string `json:"headers,omitempty"`      // 请求头，值为模板
+	ContentType string            `json:"content_type,omitempty"` // 请求体类型: json、form、query，默认 json
+	Body        string            `json:"body,omitempty"`         // JSON 请求体模板，仅 json 类型使用
+	Fields      map[string]string `json:"fields,omitempty"`       // 字段模板，用于 form/query，json 类型且 Body 为空时序列化为 JSON 对象
+	Success     SuccessCondition  `json:"success"`                // 成功判定条件
+	Timeout     int               `json:"timeout,omitempty"`      // 请求超时时间（秒），默认 10
+}
+
+// WebhookClient 通用 Webhook 通知客户端
+type WebhookClient struct {
+	name        string
+	method      string
+	contentType string
+	url         *template.Template
+	body        *template.Template
+	headers     map[string]*template.Template
+	fields      map[string]*template.Template
+	success     SuccessCondition
+	httpClient  *http.Client
+}
+
+// NewWebhookClient 根据配置创建 Webhook 通知客户端
+// 创建时会预先解析并试渲染所有模板，模板语法错误和不存在的字段会在这里返回
+// 参数: cfg - Webhook 配置
+// 返回: 初始化好的 WebhookClient 指针和可能的错误
+func NewWebhookClient(cfg WebhookConfig) (*WebhookClient, error) {
+	wc := &WebhookClient{
+		name:        cfg.Name,
+		method:      strings.ToUpper(cfg.Method),
+		contentType: strings.ToLower(cfg.ContentType),
+		headers:     make(map[string]*template.Template),
+		fields:      make(map[string]*template.Template),
+		success:     cfg.Success,
+	}
+	if wc.name == "" {
+		wc.name = "webhook"
+	}
+	if wc.method == "" {
+		wc.method = http.MethodPost
+	}
+	if wc.contentType == "" {
+		wc.contentType = WebhookContentJSON
+	}
+	switch wc.contentType {
+	case WebhookContentJSON, WebhookContentForm, WebhookContentQuery:
+	default:
+		return nil, fmt.Errorf("Webhook %s 不支持的 content_type: %s", wc.name, cfg.ContentType)
+	}
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("Webhook %s 的 url 不能为空", wc.name)
+	}
+
+	wc.httpClient = newHTTPClient(cfg.Timeout)
+
+	var err error
+	if wc.url, err = ParseTemplate(wc.name+".url", cfg.URL); err != nil {
+		return nil, err
+	}
+	if cfg.Body != "" {
+		if wc.contentType != WebhookContentJSON {
+			return nil, fmt.Errorf("Webhook %s 的 body 模板仅适用于 json 类型", wc.name)
+		}
+		if wc.body, err = ParseTemplate(wc.name+".body", cfg.Body); err != nil {
+			return nil, err
+		}
+	}
+	for key, text := range cfg.Headers {
+		if wc.headers[key], err = ParseTemplate(wc.name+".headers."+key, text); err != nil {
+			return nil, err
+		}
+	}
+	for key, text := range cfg.Fields {
+		if wc.fields[key], err = ParseTemplate(wc.name+".fields."+key, text); err != nil {
+			return nil, err
+		}
+	}
+
+	return wc, nil
+}
+
+// Name 返回通知渠道名称
+func (wc *WebhookClient) Name() string {
+	return wc.name
+}
+
+// SendSMS 按配置渲染请求并发送到 Webhook 地址
+// 参数: sms - 包含短信信息的 SMS 结构体指针
+// 返回: 发送成功返回 nil，失败返回错误
+func (wc *WebhookClient) SendSMS(sms *types.SMS) error {
+	logger.Infof("开始发送 Webhook(%s) 通知 - 短信 ID: %s, 发送方: %s", wc.name, sms.ID, logger.Phone(sms.Sender))
+
+	req, err := wc.buildRequest(sms)
+	if err != nil {
+		logger.Errorf("构建 Webhook(%s) 请求失败: %v", wc.name, err)
+		return err
+	}
+
+	if err := doRequest(wc.httpClient, req, wc.success); err != nil {
+		logger.Errorf("发送 Webhook(%s) 通知失败: %v", wc.name, err)
+		return fmt.Errorf("发送 Webhook(%s) 通知失败: %v", wc.name, err)
+	}
+
+	logger.Infof("Webhook(%s) 通知发送成功", wc.name)
+	return nil
+}
+
+// buildRequest 渲染 URL、请求头和请求体，构建 HTTP 请求
+func (wc *WebhookClient) buildRequest(sms *types.SMS) (*http.Request, error) {
+	rawURL, err := RenderTemplate(wc.url, sms)
+	if err != nil {
+		return nil, err
+	}
+	target, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, fmt.Errorf("Webhook URL 无效: %v", stripURL(err))
+	}
+
+	fields, err := wc.renderFields(sms)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.Reader
+	var contentType string
+	switch wc.contentType {
+	case WebhookContentJSON:
+		var data []byte
+		if wc.body != nil {
+			rendered, err := RenderTemplate(wc.body, sms)
+			if err != nil {
+				return nil, err
+			}
+			data = []byte(rendered)
+		} else {
+			payload := make(map[string]string, len(fields))
+			for key := range fields {
+				payload[key] = fields.Get(key)
+			}
+			if len(payload) == 0 {
+				payload = defaultWebhookPayload(sms)
+			}
+			if data, err = json.Marshal(payload); err != nil {
+				return nil, fmt.Errorf("JSON序列化失败: %v", err)
+			}
+		}
+		body = bytes.NewReader(data)
+		contentType = "application/json; charset=utf-8"
+	case WebhookContentForm:
+		body = strings.NewReader(fields.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	case WebhookContentQuery:
+		query := target.Query()
+		for key, values := range fields {
+			for _, v := range values {
+				query.Add(key, v)
+			}
+		}
+		target.RawQuery = query.Encode()
+	}
+
+	req, err := http.NewRequest(wc.method, target.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("创建 HTTP 请求失败: %v", stripURL(err))
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for key, tmpl := range wc.headers {
+		value, err := RenderTemplate(tmpl, sms)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(key, value)
+	}
+	return req, nil
+}
+
+// renderFields 渲染所有字段模板
+func (wc *WebhookClient) renderFields(sms *types.SMS) (url.Values, error) {
+	keys := make([]string, 0, len(wc.fields))
+	for key := range wc.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := url.Values{}
+	for _, key := range keys {
+		value, err := RenderTemplate(wc.fields[key], sms)
+		if err != nil {
+			return nil, err
+		}
+		values.Set(key, value)
+	}
+	return values, nil
+}
+
+// defaultWebhookPayload 未配置 body 和 fields 时使用的默认 JSON 请求体
+func defaultWebhookPayload(sms *types.SMS) map[string]string {
+	return map[string]string{
+		"title":       FormatTitle(sms),
+		"body":        FormatBody(sms),
+		"id":          sms.ID,
+		"sender":      sms.Sender,
+		"sender_name": sms.SenderName,
+		"location":    sms.Location.String(),
+		"timestamp":   sms.Timestamp,
+		"content":     sms.Content,
+		"code":        sms.Code,
+		"modem_id":    sms.ModemID,
+		"replay":      strconv.FormatBool(sms.Replay),
+	}
+}
@@ -0,0 +1,42 @@
+package notification
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sim-sms-forward/pkg/types"
+)
+
+// TestWebhookInvalidTemplate URL、请求头、请求体和字段模板引用不存在的字段时创建失败
+func TestWebhookInvalidTemplate(t *testing.T) {
+	for name, cfg := range map[string]WebhookConfig{
+		"url":    {URL: "https://example.com/{{.Foo}}"},
+		"body":   {URL: "https://example.com", Body: `{"text":{{json .Foo}}}`},
+		"header": {URL: "https://example.com", Headers: map[string]string{"X-Sender": "{{.Foo}}"}},
+		"field":  {URL: "https://example.com", ContentType: WebhookContentForm, Fields: map[string]string{"text": "{{.Foo}}"}},
+	} {
+		if _, err := NewWebhookClient(cfg); err == nil {
+			t.Errorf("%s 模板引用不存在的字段时应返回错误", name)
+		}
+	}
+}
+
+// TestWebhookErrorHidesURL 请求失败时错误信息中不包含 URL 中的令牌
+func TestWebhookErrorHidesURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Close()
+
+	wc, err := NewWebhookClient(WebhookConfig{URL: srv.URL + "/hook?token=secret-token-123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wc.SendSMS(&types.SMS{ID: "1", Sender: "10086", Content: "余额不足"})
+	if err == nil {
+		t.Fatal("服务器已关闭，发送应失败")
+	}
+	if strings.Contains(err.Error(), "secret-token-123") {
+		t.Errorf("错误信息泄露了令牌: %v", err)
+	}
+}
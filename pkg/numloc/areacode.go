@@ -0,0 +1,98 @@
+// Package numloc 提供离线的手机号码归属地和运营商查询
+package numloc
+
+// areaCodes 内置的固话区号，值为 "省份 城市"，直辖市只有省份
+var areaCodes = map[string]string{
+	// 直辖市和 3 位区号城市
+	"010": "北京",
+	"021": "上海",
+	"022": "天津",
+	"023": "重庆",
+	"020": "广东 广州",
+	"024": "辽宁 沈阳",
+	"025": "江苏 南京",
+	"027": "湖北 武汉",
+	"028": "四川 成都",
+	"029": "陕西 西安",
+
+	// 省会城市
+	"0311": "河北 石家庄",
+	"0351": "山西 太原",
+	"0471": "内蒙古 呼和浩特",
+	"0431": "吉林 长春",
+	"0451": "黑龙江 哈尔滨",
+	"0571": "浙江 杭州",
+	"0551": "安徽 合肥",
+	"0591": "福建 福州",
+	"0791": "江西 南昌",
+	"0531": "山东 济南",
+	"0371": "河南 郑州",
+	"0731": "湖南 长沙",
+	"0771": "广西 南宁",
+	"0898": "海南 海口",
+	"0851": "贵州 贵阳",
+	"0871": "云南 昆明",
+	"0891": "西藏 拉萨",
+	"0931": "甘肃 兰州",
+	"0971": "青海 西宁",
+	"0951": "宁夏 银川",
+	"0991": "新疆 乌鲁木齐",
+
+	// 其他主要城市
+	"0755": "广东 深圳",
+	"0756": "广东 珠海",
+	"0757": "广东 佛山",
+	"0769": "广东 东莞",
+	"0760": "广东 中山",
+	"0754": "广东 汕头",
+	"0750": "广东 江门",
+	"0752": "广东 惠州",
+	"0512": "江苏 苏州",
+	"0510": "江苏 无锡",
+	"0519": "江苏 常州",
+	"0513": "江苏 南通",
+	"0516": "江苏 徐州",
+	"0511": "江苏 镇江",
+	"0514": "江苏 扬州",
+	"0574": "浙江 宁波",
+	"0577": "浙江 温州",
+	"0573": "浙江 嘉兴",
+	"0575": "浙江 绍兴",
+	"0579": "浙江 金华",
+	"0576": "浙江 台州",
+	"0572": "浙江 湖州",
+	"0532": "山东 青岛",
+	"0535": "山东 烟台",
+	"0536": "山东 潍坊",
+	"0631": "山东 威海",
+	"0533": "山东 淄博",
+	"0592": "福建 厦门",
+	"0595": "福建 泉州",
+	"0411": "辽宁 大连",
+	"0412": "辽宁 鞍山",
+	"0315": "河北 唐山",
+	"0312": "河北 保定",
+	"0310": "河北 邯郸",
+	"0335": "河北 秦皇岛",
+	"0379": "河南 洛阳",
+	"0717": "湖北 宜昌",
+	"0710": "湖北 襄阳",
+	"0730": "湖南 岳阳",
+	"0734": "湖南 衡阳",
+	"0816": "四川 绵阳",
+	"0917": "陕西 宝鸡",
+	"0773": "广西 桂林",
+	"0772": "广西 柳州",
+	"0852": "贵州 遵义",
+	"0872": "云南 大理",
+	"0553": "安徽 芜湖",
+	"0552": "安徽 蚌埠",
+	"0792": "江西 九江",
+	"0797": "江西 赣州",
+	"0432": "吉林 吉林",
+	"0459": "黑龙江 大庆",
+	"0452": "黑龙江 齐齐哈尔",
+	"0472": "内蒙古 包头",
+	"0990": "新疆 克拉玛依",
+	"0998": "新疆 喀什",
+}
@@ -0,0 +1,247 @@
+// Package numloc 提供离线的手机号码归属地和运营商查询
+// 运营商按号段判断，归属地来自数据目录中的号段数据库（phone.dat），数据目录中没有时使用内置的数据库，
+// 固话按区号判断，全程不访问网络
+package numloc
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"sim-sms-forward/pkg/contacts"
+	"sim-sms-forward/pkg/logger"
+	"sim-sms-forward/pkg/types"
+)
+
+// Config 归属地查询配置
+type Config struct {
+	Disable bool   `json:"disable,omitempty"` // 是否关闭归属地查询
+	File    string `json:"file,omitempty"`    // 号段数据库路径，默认为数据目录下的 phone.dat
+}
+
+// builtinFS 编译时 data 目录下的文件，通过 make phonedata 下载 phone.dat
+//
+//go:embed data
+var builtinFS embed.FS
+
+// builtinDB 返回内置的号段数据库，编译时没有 data/phone.dat 时返回 nil
+var builtinDB = sync.OnceValue(func() *PhoneDB {
+	data, err := fs.ReadFile(builtinFS, "data/phone.dat")
+	if err != nil {
+		return nil
+	}
+	db, err := ParsePhoneDB(data, "内置号段数据库")
+	if err != nil {
+		logger.Errorf("%v", err)
+		return nil
+	}
+	return db
+})
+
+// Locator 归属地查询器
+// 号段数据库通过 numloc update 命令更新，运行中的服务会在下次查询时自动重新加载；
+// 数据目录中没有号段数据库时使用内置的数据库
+type Locator struct {
+	path string
+
+	mu      sync.Mutex
+	db      *PhoneDB
+	builtin bool // 当前使用的是否为内置数据库
+	modTime time.Time
+}
+
+// NewLocator 创建归属地查询器
+// 参数:
+//   - cfg: 归属地查询配置
+//   - dataDir: 数据目录，未配置 file 时号段数据库为 phone.dat
+//
+// 返回: 初始化好的 Locator 指针，数据库不存在且没有内置数据库时只能查询运营商和固话区号
+func NewLocator(cfg Config, dataDir string) *Locator {
+	path := cfg.File
+	if path == "" {
+		path = filepath.Join(dataDir, "phone.dat")
+	}
+	return &Locator{path: path}
+}
+
+// Path 返回号段数据库路径
+func (l *Locator) Path() string {
+	return l.path
+}
+
+// Source 返回当前使用的号段数据库，使用内置数据库时返回 "内置"，没有数据库时返回空字符串
+func (l *Locator) Source() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.reload(); err != nil || l.db == nil {
+		return ""
+	}
+	if l.builtin {
+		return "内置"
+	}
+	return l.path
+}
+
+// Version 返回当前号段数据库的版本，未加载数据库时返回空字符串
+func (l *Locator) Version() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.reload(); err != nil || l.db == nil {
+		return ""
+	}
+	return l.db.Version
+}
+
+// Lookup 查询号码的归属地和运营商
+// 参数: number - 原始号码，支持 +86、0086 等前缀
+// 返回: 归属地信息，短号码、服务号码等无法识别的号码返回空值
+func (l *Locator) Lookup(number string) types.NumberLocation {
+	n := contacts.Normalize(number)
+	switch {
+	case isDigits(n) && len(n) == 11 && n[0] == '1':
+		return l.lookupMobile(n)
+	case isDigits(n) && len(n) >= 10 && n[0] == '0':
+		return lookupLandline(n)
+	default:
+		return types.NumberLocation{}
+	}
+}
+
+// lookupMobile 查询手机号码，数据库中的运营商优先于号段规则
+func (l *Locator) lookupMobile(n string) types.NumberLocation {
+	loc := types.NumberLocation{Carrier: CarrierOf(n)}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.reload(); err != nil {
+		// 数据库损坏不影响短信转发，继续使用上次加载的数据
+		logger.Errorf("加载号段数据库失败: %v", err)
+	}
+	if l.db == nil {
+		return loc
+	}
+	if record, ok := l.db.Find(n); ok {
+		loc.Province = record.Province
+		loc.City = record.City
+		if record.Carrier != "" {
+			loc.Carrier = record.Carrier
+		}
+	}
+	return loc
+}
+
+// reload 数据库文件修改时间变化时重新加载，文件不存在时使用内置数据库，调用方需持有锁
+func (l *Locator) reload() error {
+	info, err := os.Stat(l.path)
+	if os.IsNotExist(err) {
+		l.db, l.builtin = builtinDB(), true
+		l.modTime = time.Time{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if l.db != nil && !l.builtin && info.ModTime().Equal(l.modTime) {
+		return nil
+	}
+	db, err := OpenPhoneDB(l.path)
+	if err != nil {
+		return err
+	}
+	l.db, l.builtin = db, false
+	l.modTime = info.ModTime()
+	return nil
+}
+
+// Update 校验新的号段数据库并替换当前数据库
+// 参数: src - 新数据库文件路径
+// 返回: 新数据库和可能的错误，校验失败时不会修改当前数据库
+func (l *Locator) Update(src string) (*PhoneDB, error) {
+	db, err := OpenPhoneDB(src)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return nil, fmt.Errorf("创建数据目录失败: %v", err)
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, db.data, 0644); err != nil {
+		return nil, fmt.Errorf("写入号段数据库失败: %v", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return nil, fmt.Errorf("写入号段数据库失败: %v", err)
+	}
+	return db, nil
+}
+
+// carrierPrefixes 号段与运营商的对应关系，先匹配 4 位号段再匹配 3 位号段
+var carrierPrefixes = map[string]string{
+	// 中国移动
+	"134": "移动", "135": "移动", "136": "移动", "137": "移动", "138": "移动", "139": "移动",
+	"147": "移动", "148": "移动", "150": "移动", "151": "移动", "152": "移动", "157": "移动",
+	"158": "移动", "159": "移动", "172": "移动", "178": "移动", "182": "移动", "183": "移动",
+	"184": "移动", "187": "移动", "188": "移动", "195": "移动", "197": "移动", "198": "移动",
+	// 中国联通
+	"130": "联通", "131": "联通", "132": "联通", "145": "联通", "146": "联通", "155": "联通",
+	"156": "联通", "166": "联通", "175": "联通", "176": "联通", "185": "联通", "186": "联通",
+	"196": "联通",
+	// 中国电信
+	"133": "电信", "149": "电信", "153": "电信", "173": "电信", "177": "电信", "180": "电信",
+	"181": "电信", "189": "电信", "190": "电信", "191": "电信", "193": "电信", "199": "电信",
+	// 中国广电
+	"192": "广电",
+	// 虚拟运营商
+	"162": "电信虚拟", "165": "移动虚拟", "167": "联通虚拟", "171": "联通虚拟",
+	"1700": "电信虚拟", "1701": "电信虚拟", "1702": "电信虚拟",
+	"1703": "移动虚拟", "1705": "移动虚拟", "1706": "移动虚拟",
+	"1704": "联通虚拟", "1707": "联通虚拟", "1708": "联通虚拟", "1709": "联通虚拟",
+}
+
+// CarrierOf 按号段判断手机号码的运营商
+// 参数: n - 规范化后的 11 位手机号码
+// 返回: 移动、联通、电信、广电或 X虚拟，未知号段返回空字符串
+func CarrierOf(n string) string {
+	if len(n) < 4 {
+		return ""
+	}
+	if carrier, ok := carrierPrefixes[n[:4]]; ok {
+		return carrier
+	}
+	return carrierPrefixes[n[:3]]
+}
+
+// lookupLandline 按区号查询固话号码的归属地
+func lookupLandline(n string) types.NumberLocation {
+	for _, size := range []int{3, 4} {
+		if len(n) <= size {
+			continue
+		}
+		if loc, ok := areaCodes[n[:size]]; ok {
+			parts := strings.SplitN(loc, " ", 2)
+			result := types.NumberLocation{Province: parts[0]}
+			if len(parts) == 2 {
+				result.City = parts[1]
+			}
+			return result
+		}
+	}
+	return types.NumberLocation{}
+}
+
+// isDigits 判断字符串是否全为数字
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
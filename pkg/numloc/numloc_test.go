@@ -0,0 +1,87 @@
+package numloc
+
+import (
+	"encoding/binary"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sim-sms-forward/pkg/types"
+)
+
+// TestBuiltinDB 内置的号段数据库可以加载并查到已知号段
+// 数据库由 make phonedata 下载，make test 和发布构建会先下载；直接 go test 且没有下载时跳过
+func TestBuiltinDB(t *testing.T) {
+	if _, err := fs.Stat(builtinFS, "data/phone.dat"); err != nil {
+		t.Skip("没有 data/phone.dat，先执行 make phonedata")
+	}
+	db := builtinDB()
+	if db == nil {
+		t.Fatal("内置号段数据库无法加载")
+	}
+	record, ok := db.Find("13800138000")
+	if !ok {
+		t.Fatal("内置号段数据库中没有 1380013 号段")
+	}
+	if record.Province != "北京" || record.City != "北京" || record.Carrier != "移动" {
+		t.Errorf("1380013 号段为 %+v，期望北京 北京 移动", record)
+	}
+
+	loc := NewLocator(Config{}, t.TempDir()).Lookup("+86 138-0013-8000")
+	if loc.String() != "北京 移动" {
+		t.Errorf("归属地为 %q，期望 北京 移动", loc.String())
+	}
+}
+
+// TestLocatorFile 数据目录中的号段数据库可以查到省份、城市和数据库中的运营商
+func TestLocatorFile(t *testing.T) {
+	dir := t.TempDir()
+	writePhoneDat(t, filepath.Join(dir, "phone.dat"), []phoneDatEntry{
+		{1300000, "山东|济南|250000|0531", 2},
+		{1340000, "广东|深圳|518000|0755", 1},
+		{1700000, "北京|北京|100000|010", 6},
+	})
+
+	l := NewLocator(Config{}, dir)
+	if l.Source() != filepath.Join(dir, "phone.dat") || l.Version() != "2410" {
+		t.Errorf("号段数据库为 %q，版本 %q", l.Source(), l.Version())
+	}
+	for number, want := range map[string]types.NumberLocation{
+		"13400001234":    {Province: "广东", City: "深圳", Carrier: "移动"},
+		"+8613000001234": {Province: "山东", City: "济南", Carrier: "联通"},
+		"17000001234":    {Province: "北京", City: "北京", Carrier: "移动虚拟"},
+		"13500001234":    {Carrier: "移动"},
+	} {
+		if got := l.Lookup(number); got != want {
+			t.Errorf("%s 归属地为 %+v，期望 %+v", number, got, want)
+		}
+	}
+}
+
+// phoneDatEntry 测试用号段数据库中的一个号段
+type phoneDatEntry struct {
+	prefix  uint32
+	record  string
+	carrier byte
+}
+
+// writePhoneDat 按 phone.dat 格式写入号段数据库，entries 需按号段升序排列
+func writePhoneDat(t *testing.T, path string, entries []phoneDatEntry) {
+	t.Helper()
+	data := []byte("2410\x00\x00\x00\x00")
+	offsets := make([]uint32, len(entries))
+	for i, e := range entries {
+		offsets[i] = uint32(len(data))
+		data = append(append(data, e.record...), 0)
+	}
+	binary.LittleEndian.PutUint32(data[4:8], uint32(len(data)))
+	for i, e := range entries {
+		data = binary.LittleEndian.AppendUint32(data, e.prefix)
+		data = binary.LittleEndian.AppendUint32(data, offsets[i])
+		data = append(data, e.carrier)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
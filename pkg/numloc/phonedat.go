@@ -0,0 +1,136 @@
+// Package numloc 提供离线的手机号码归属地和运营商查询
+package numloc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// phone.dat 文件结构（与常见的开源手机号段库格式兼容）:
+//   - 头部 8 字节: 4 字节版本号（如 "2402"）+ 4 字节小端整数，表示索引区的起始偏移
+//   - 记录区: 若干条 "省份|城市|邮编|区号\0"
+//   - 索引区: 按号段升序排列，每条 9 字节: 4 字节号段（手机号前 7 位）+ 4 字节记录偏移 + 1 字节运营商类型
+const (
+	phoneDatHeaderLen = 8
+	phoneDatIndexLen  = 9
+)
+
+// phoneDatCarriers 索引中的运营商类型
+var phoneDatCarriers = map[byte]string{
+	1: "移动",
+	2: "联通",
+	3: "电信",
+	4: "电信虚拟",
+	5: "联通虚拟",
+	6: "移动虚拟",
+	7: "广电",
+	8: "广电虚拟",
+}
+
+// PhoneRecord 号段数据库中的一条记录
+type PhoneRecord struct {
+	Province string // 省份
+	City     string // 城市
+	ZipCode  string // 邮编
+	AreaCode string // 区号
+	Carrier  string // 运营商
+}
+
+// PhoneDB 加载到内存中的号段数据库
+type PhoneDB struct {
+	Version     string // 数据库版本
+	Records     int    // 号段数量
+	data        []byte
+	indexOffset int
+}
+
+// OpenPhoneDB 读取并校验号段数据库
+// 参数: path - phone.dat 文件路径
+// 返回: 号段数据库和可能的错误
+func OpenPhoneDB(path string) (*PhoneDB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取号段数据库失败: %v", err)
+	}
+	return ParsePhoneDB(data, path)
+}
+
+// ParsePhoneDB 校验并加载内存中的号段数据库
+// 参数:
+//   - data: phone.dat 文件内容
+//   - path: 文件名，用于错误信息
+//
+// 返回: 号段数据库和可能的错误
+func ParsePhoneDB(data []byte, path string) (*PhoneDB, error) {
+	if len(data) < phoneDatHeaderLen {
+		return nil, fmt.Errorf("号段数据库 %s 格式无效: 文件过短", path)
+	}
+	indexOffset := int(binary.LittleEndian.Uint32(data[4:8]))
+	if indexOffset < phoneDatHeaderLen || indexOffset > len(data) || (len(data)-indexOffset)%phoneDatIndexLen != 0 {
+		return nil, fmt.Errorf("号段数据库 %s 格式无效: 索引区偏移 %d 不正确", path, indexOffset)
+	}
+	db := &PhoneDB{
+		Version:     strings.TrimRight(string(data[:4]), "\x00"),
+		Records:     (len(data) - indexOffset) / phoneDatIndexLen,
+		data:        data,
+		indexOffset: indexOffset,
+	}
+	if db.Records == 0 {
+		return nil, fmt.Errorf("号段数据库 %s 格式无效: 没有号段记录", path)
+	}
+	return db, nil
+}
+
+// Find 二分查找手机号码所在的号段
+// 参数: n - 规范化后的 11 位手机号码
+// 返回: 号段记录，未找到时第二个返回值为 false
+func (db *PhoneDB) Find(n string) (PhoneRecord, bool) {
+	if len(n) < 7 {
+		return PhoneRecord{}, false
+	}
+	prefix, err := strconv.ParseUint(n[:7], 10, 32)
+	if err != nil {
+		return PhoneRecord{}, false
+	}
+
+	left, right := 0, db.Records-1
+	for left <= right {
+		mid := (left + right) / 2
+		offset := db.indexOffset + mid*phoneDatIndexLen
+		current := uint64(binary.LittleEndian.Uint32(db.data[offset : offset+4]))
+		switch {
+		case current < prefix:
+			left = mid + 1
+		case current > prefix:
+			right = mid - 1
+		default:
+			recordOffset := int(binary.LittleEndian.Uint32(db.data[offset+4 : offset+8]))
+			record, ok := db.readRecord(recordOffset)
+			if !ok {
+				return PhoneRecord{}, false
+			}
+			record.Carrier = phoneDatCarriers[db.data[offset+8]]
+			return record, true
+		}
+	}
+	return PhoneRecord{}, false
+}
+
+// readRecord 读取记录区中以 \0 结尾的记录
+func (db *PhoneDB) readRecord(offset int) (PhoneRecord, bool) {
+	if offset < phoneDatHeaderLen || offset >= db.indexOffset {
+		return PhoneRecord{}, false
+	}
+	end := offset
+	for end < db.indexOffset && db.data[end] != 0 {
+		end++
+	}
+	parts := strings.Split(string(db.data[offset:end]), "|")
+	for len(parts) < 4 {
+		parts = append(parts, "")
+	}
+	return PhoneRecord{Province: parts[0], City: parts[1], ZipCode: parts[2], AreaCode: parts[3]}, true
+}
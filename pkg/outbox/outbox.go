@@ -0,0 +1,221 @@
+// Package outbox 提供持久化的待发送短信队列
+// 免打扰时段暂缓发送的短信和等待汇总的短信保存在这里，程序重启后不会丢失
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"sim-sms-forward/pkg/types"
+	"sim-sms-forward/pkg/vault"
+)
+
+// 队列条目的类型
+const (
+	KindHold   = "hold"   // 免打扰时段暂存
+	KindDigest = "digest" // 等待汇总推送
+)
+
+// Entry 队列中的一条待发送短信
+type Entry struct {
+	ID        int64      `json:"id"`         // 队列内唯一的序号
+	Channel   string     `json:"channel"`    // 通知渠道名称
+	Kind      string     `json:"kind"`       // 条目类型: hold、digest
+	ReleaseAt time.Time  `json:"release_at"` // 最早的发送时间，零值表示没有计划时间，只能通过 Release 发送
+	QueuedAt  time.Time  `json:"queued_at"`  // 入队时间
+	SMS       *types.SMS `json:"sms"`        // 短信快照
+}
+
+// Outbox 持久化的待发送队列，每次修改都会整体写回文件
+// 配置了加密时，整个队列作为一条记录加密保存
+type Outbox struct {
+	path string
+	key  *vault.Key
+
+	mu      sync.Mutex
+	entries []Entry
+	nextID  int64
+}
+
+// Open 打开队列文件，文件不存在时创建空队列
+// 参数:
+//   - path: 队列文件路径
+//   - key: 加密密钥，为 nil 时不加密
+//
+// 返回: 队列和可能的错误，队列无法用 key 解密时返回错误
+func Open(path string, key *vault.Key) (*Outbox, error) {
+	ob := &Outbox{path: path, key: key, nextID: 1}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ob, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取待发送队列失败: %v", err)
+	}
+	if data, err = vault.Decode(key, data); err != nil {
+		return nil, fmt.Errorf("待发送队列 %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, &ob.entries); err != nil {
+		return nil, fmt.Errorf("解析待发送队列 %s 失败: %v", path, err)
+	}
+	for i, e := range ob.entries {
+		if e.ID >= ob.nextID {
+			ob.nextID = e.ID + 1
+		}
+		if e.Kind == "" {
+			ob.entries[i].Kind = KindHold
+		}
+	}
+	return ob, nil
+}
+
+// Add 将短信加入队列并立即保存
+// 处理失败重试时同一条短信会再次加入，同一渠道和类型中已有这条短信时保留原条目，不重复入队
+// 参数:
+//   - channel: 通知渠道名称
+//   - kind: 条目类型: hold、digest
+//   - releaseAt: 最早的发送时间
+//   - sms: 短信，保存的是副本
+func (ob *Outbox) Add(channel, kind string, releaseAt time.Time, sms *types.SMS) error {
+	snapshot := *sms
+	key := smsKey(&snapshot)
+
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	for _, e := range ob.entries {
+		if e.Channel == channel && e.Kind == kind && smsKey(e.SMS) == key {
+			return nil
+		}
+	}
+	ob.entries = append(ob.entries, Entry{
+		ID:        ob.nextID,
+		Channel:   channel,
+		Kind:      kind,
+		ReleaseAt: releaseAt,
+		QueuedAt:  time.Now(),
+		SMS:       &snapshot,
+	})
+	ob.nextID++
+	if err := ob.save(); err != nil {
+		ob.entries = ob.entries[:len(ob.entries)-1]
+		return err
+	}
+	return nil
+}
+
+// smsKey 识别同一条短信，与归档去重使用相同的字段
+// 调制解调器会重新使用已删除短信的ID，因此同时比较发件人和时间戳
+func smsKey(sms *types.SMS) string {
+	return sms.ModemID + "\x00" + sms.ID + "\x00" + sms.Sender + "\x00" + sms.Timestamp
+}
+
+// Due 返回指定渠道和类型中已到发送时间的短信，按入队顺序排列
+// 返回的短信仍在队列中，发送成功后需要调用 Remove 删除
+func (ob *Outbox) Due(channel, kind string, now time.Time) []Entry {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	var due []Entry
+	for _, e := range ob.entries {
+		if e.Channel == channel && e.Kind == kind && !e.ReleaseAt.IsZero() && !e.ReleaseAt.After(now) {
+			due = append(due, e)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	return due
+}
+
+// Count 返回指定渠道和类型在队列中的短信数量
+func (ob *Outbox) Count(channel, kind string) int {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	count := 0
+	for _, e := range ob.entries {
+		if e.Channel == channel && e.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// Release 将指定渠道和类型的所有短信设为立即发送并保存，用于达到汇总条数时提前推送
+func (ob *Outbox) Release(channel, kind string, now time.Time) error {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	for i := range ob.entries {
+		e := &ob.entries[i]
+		if e.Channel == channel && e.Kind == kind && (e.ReleaseAt.IsZero() || e.ReleaseAt.After(now)) {
+			e.ReleaseAt = now
+		}
+	}
+	return ob.save()
+}
+
+// Pending 返回队列中所有待发送的短信
+func (ob *Outbox) Pending() []Entry {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	return append([]Entry(nil), ob.entries...)
+}
+
+// Remove 删除已发送的短信并保存
+// 参数: ids - 要删除的序号
+func (ob *Outbox) Remove(ids ...int64) error {
+	remove := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	kept := ob.entries[:0:0]
+	for _, e := range ob.entries {
+		if !remove[e.ID] {
+			kept = append(kept, e)
+		}
+	}
+	previous := ob.entries
+	ob.entries = kept
+	if err := ob.save(); err != nil {
+		ob.entries = previous
+		return err
+	}
+	return nil
+}
+
+// Rekey 用新密钥重新保存队列，newKey 为 nil 时保存为明文
+func (ob *Outbox) Rekey(newKey *vault.Key) error {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	previous := ob.key
+	ob.key = newKey
+	if err := ob.save(); err != nil {
+		ob.key = previous
+		return err
+	}
+	return nil
+}
+
+// save 先写入临时文件再重命名，调用方需持有锁
+func (ob *Outbox) save() error {
+	data, err := json.Marshal(ob.entries)
+	if err != nil {
+		return fmt.Errorf("序列化待发送队列失败: %v", err)
+	}
+	data = vault.Encode(ob.key, data)
+	if err := os.MkdirAll(filepath.Dir(ob.path), 0755); err != nil {
+		return fmt.Errorf("创建数据目录失败: %v", err)
+	}
+	tmp := ob.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("写入待发送队列失败: %v", err)
+	}
+	if err := os.Rename(tmp, ob.path); err != nil {
+		return fmt.Errorf("写入待发送队列失败: %v", err)
+	}
+	return nil
+}
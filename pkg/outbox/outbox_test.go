@@ -0,0 +1,54 @@
+package outbox
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sim-sms-forward/pkg/types"
+)
+
+// TestAddOnce 处理失败重试时同一条短信只入队一次，重新打开的队列也能识别
+func TestAddOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	ob, err := Open(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sms := &types.SMS{ID: "3", ModemID: "0", Sender: "10086", Timestamp: "2026-10-18T01:00:00+08:00", Content: "余额不足"}
+	releaseAt := time.Date(2026, 10, 18, 8, 0, 0, 0, time.Local)
+	for i := 0; i < 3; i++ {
+		if err := ob.Add("bark", KindHold, releaseAt.Add(time.Duration(i)*time.Minute), sms); err != nil {
+			t.Fatalf("入队失败: %v", err)
+		}
+	}
+	// 其他渠道、其他类型和重新使用同一ID的新短信仍然入队
+	if err := ob.Add("email", KindHold, releaseAt, sms); err != nil {
+		t.Fatal(err)
+	}
+	if err := ob.Add("bark", KindDigest, releaseAt, sms); err != nil {
+		t.Fatal(err)
+	}
+	next := *sms
+	next.Timestamp = "2026-10-18T02:00:00+08:00"
+	if err := ob.Add("bark", KindHold, releaseAt, &next); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Add("bark", KindHold, releaseAt, sms); err != nil {
+		t.Fatal(err)
+	}
+	if count := reopened.Count("bark", KindHold); count != 2 {
+		t.Errorf("bark 暂存队列有 %d 条短信，期望 2", count)
+	}
+	if due := reopened.Due("bark", KindHold, releaseAt); len(due) != 2 || !due[0].ReleaseAt.Equal(releaseAt) {
+		t.Errorf("重复入队不应修改原条目的发送时间: %+v", due)
+	}
+	if len(reopened.Pending()) != 4 {
+		t.Errorf("队列中有 %d 条短信，期望 4", len(reopened.Pending()))
+	}
+}
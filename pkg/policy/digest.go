@@ -0,0 +1,94 @@
+// Package policy 提供按通知渠道配置的发送策略，如免打扰时段
+package policy
+
+import (
+	"fmt"
+	"time"
+
+	"sim-sms-forward/pkg/rules"
+)
+
+// 汇总推送的周期
+const (
+	DigestHourly = "hourly" // 每小时整点推送
+	DigestDaily  = "daily"  // 每天在 at 指定的时间推送
+)
+
+// Digest 低优先级短信的汇总推送配置
+// 优先级为 low 的短信（可通过路由规则的 priority 设置）不会逐条推送，而是累积后按计划汇总为一条通知
+type Digest struct {
+	Schedule    string   `json:"schedule,omitempty"`     // 推送周期: hourly、daily，为空时只按条数推送
+	At          []string `json:"at,omitempty"`           // daily 周期的推送时间，格式 HH:MM，默认 08:00
+	MaxMessages int      `json:"max_messages,omitempty"` // 累积到这个条数时立即推送，0 表示不限
+	Timezone    string   `json:"timezone,omitempty"`     // 时区，如 Asia/Shanghai，默认使用系统时区
+}
+
+// compiledDigest 解析后的汇总推送配置
+type compiledDigest struct {
+	cfg      Digest
+	location *time.Location
+	times    []int // daily 周期的推送时间（当天的分钟数）
+}
+
+// compileDigest 解析汇总推送配置
+func compileDigest(cfg Digest) (*compiledDigest, error) {
+	if cfg.MaxMessages < 0 {
+		return nil, fmt.Errorf("digest.max_messages 不能小于0")
+	}
+	switch cfg.Schedule {
+	case "":
+		if cfg.MaxMessages == 0 {
+			return nil, fmt.Errorf("digest 需要配置 schedule 或 max_messages")
+		}
+	case DigestHourly, DigestDaily:
+	default:
+		return nil, fmt.Errorf("digest.schedule 无效: %s，可选 hourly、daily", cfg.Schedule)
+	}
+
+	d := &compiledDigest{cfg: cfg, location: time.Local}
+	if cfg.Timezone != "" {
+		location, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("digest.timezone 无效: %v", err)
+		}
+		d.location = location
+	}
+	if cfg.Schedule == DigestDaily {
+		at := cfg.At
+		if len(at) == 0 {
+			at = []string{"08:00"}
+		}
+		for _, value := range at {
+			minute, err := rules.ParseClock(value)
+			if err != nil {
+				return nil, fmt.Errorf("digest.at 无效: %s", value)
+			}
+			d.times = append(d.times, minute)
+		}
+	}
+	return d, nil
+}
+
+// next 返回 now 之后的下一个推送时间，只按条数推送时返回零值
+func (d *compiledDigest) next(now time.Time) time.Time {
+	local := now.In(d.location)
+	y, m, day := local.Date()
+	switch d.cfg.Schedule {
+	case DigestHourly:
+		return time.Date(y, m, day, local.Hour()+1, 0, 0, 0, d.location)
+	case DigestDaily:
+		var best time.Time
+		for _, minute := range d.times {
+			t := time.Date(y, m, day, minute/60, minute%60, 0, 0, d.location)
+			if !t.After(local) {
+				t = t.AddDate(0, 0, 1)
+			}
+			if best.IsZero() || t.Before(best) {
+				best = t
+			}
+		}
+		return best
+	default:
+		return time.Time{}
+	}
+}
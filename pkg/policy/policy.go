@@ -0,0 +1,233 @@
+// Package policy 提供按通知渠道配置的发送策略，如免打扰时段、汇总推送和内容脱敏
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"sim-sms-forward/pkg/logger"
+	"sim-sms-forward/pkg/notification"
+	"sim-sms-forward/pkg/outbox"
+	"sim-sms-forward/pkg/redact"
+	"sim-sms-forward/pkg/types"
+)
+
+// ChannelConfig 单个通知渠道的发送策略
+type ChannelConfig struct {
+	QuietHours *QuietHours     `json:"quiet_hours,omitempty"` // 免打扰时段
+	Digest     *Digest         `json:"digest,omitempty"`      // 低优先级短信汇总推送
+	Redact     *redact.Profile `json:"redact,omitempty"`      // 转发前对短信内容脱敏
+}
+
+// Validate 验证发送策略配置
+func (c ChannelConfig) Validate() error {
+	if c.QuietHours != nil {
+		if _, err := compileQuietHours(*c.QuietHours); err != nil {
+			return err
+		}
+	}
+	if c.Digest != nil {
+		if _, err := compileDigest(*c.Digest); err != nil {
+			return err
+		}
+	}
+	if c.Redact != nil {
+		if _, err := redact.Compile(*c.Redact); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Notifier 为通知渠道附加发送策略的包装器
+// 它实现了 notification.Notifier 接口，名称与被包装的渠道相同，路由规则可以照常引用
+type Notifier struct {
+	inner  notification.Notifier
+	quiet  *compiledQuietHours
+	digest *compiledDigest
+	redact *redact.Redactor
+	outbox *outbox.Outbox
+}
+
+// Wrap 按渠道名称为通知渠道附加发送策略，没有配置策略的渠道原样返回
+// 参数:
+//   - notifiers: 通知渠道列表
+//   - channels: 渠道名称到发送策略的映射
+//   - ob: 暂存短信的持久化队列
+//
+// 返回: 包装后的通知渠道列表和可能的配置错误
+func Wrap(notifiers []notification.Notifier, channels map[string]ChannelConfig, ob *outbox.Outbox) ([]notification.Notifier, error) {
+	wrapped := make([]notification.Notifier, 0, len(notifiers))
+	for _, n := range notifiers {
+		cfg, ok := channels[n.Name()]
+		if !ok || (cfg.QuietHours == nil && cfg.Digest == nil && cfg.Redact == nil) {
+			wrapped = append(wrapped, n)
+			continue
+		}
+		pn := &Notifier{inner: n, outbox: ob}
+		var err error
+		if cfg.QuietHours != nil {
+			if pn.quiet, err = compileQuietHours(*cfg.QuietHours); err != nil {
+				return nil, fmt.Errorf("channels.%s: %v", n.Name(), err)
+			}
+		}
+		if cfg.Digest != nil {
+			if pn.digest, err = compileDigest(*cfg.Digest); err != nil {
+				return nil, fmt.Errorf("channels.%s: %v", n.Name(), err)
+			}
+		}
+		if cfg.Redact != nil {
+			if pn.redact, err = redact.Compile(*cfg.Redact); err != nil {
+				return nil, fmt.Errorf("channels.%s: %v", n.Name(), err)
+			}
+		}
+		wrapped = append(wrapped, pn)
+	}
+	return wrapped, nil
+}
+
+// Name 返回被包装的通知渠道名称
+func (pn *Notifier) Name() string {
+	return pn.inner.Name()
+}
+
+// SendSMS 按发送策略发送短信
+// 配置了脱敏时，先对短信副本脱敏，汇总队列和暂存队列中保存的也是脱敏后的内容；
+// 配置了汇总推送时，低优先级短信加入汇总队列，由 Flush 按计划发送；
+// 免打扰时段内，lower 模式降为低优先级后发送，hold 模式暂存到队列，时段结束后由 Flush 汇总发送
+// 参数: sms - 包含短信信息的 SMS 结构体指针，不会被修改
+// 返回: 发送或加入队列成功返回 nil，失败返回错误
+func (pn *Notifier) SendSMS(sms *types.SMS) error {
+	now := time.Now()
+	if pn.redact != nil {
+		sms = pn.redact.SMS(sms)
+	}
+	if pn.digest != nil && sms.Priority <= types.PriorityLow {
+		return pn.queueDigest(sms, now)
+	}
+	if pn.quiet == nil || !pn.quiet.active(now) {
+		return pn.inner.SendSMS(sms)
+	}
+	if ok, reason := pn.quiet.bypass(sms); ok {
+		logger.Infof("%s 处于免打扰时段，短信 %s 因%s立即推送", pn.Name(), sms.ID, reason)
+		return pn.inner.SendSMS(sms)
+	}
+
+	if pn.quiet.cfg.Mode == QuietModeHold {
+		releaseAt := pn.quiet.end(now)
+		if err := pn.outbox.Add(pn.Name(), outbox.KindHold, releaseAt, sms); err != nil {
+			return err
+		}
+		logger.Infof("%s 处于免打扰时段，短信 %s 暂存到 %s 后汇总推送", pn.Name(), sms.ID, releaseAt.Format("01-02 15:04"))
+		return nil
+	}
+
+	// 其他渠道共用同一条短信，降低优先级时使用副本
+	quiet := *sms
+	if quiet.Priority > types.PriorityLow {
+		quiet.Priority = types.PriorityLow
+	}
+	logger.Infof("%s 处于免打扰时段，短信 %s 降为低优先级推送", pn.Name(), sms.ID)
+	return pn.inner.SendSMS(&quiet)
+}
+
+// SendNow 立即发送短信，只应用内容脱敏，不受免打扰和汇总推送限制
+// 用于重新发送归档短信等由用户主动触发的推送
+func (pn *Notifier) SendNow(sms *types.SMS) error {
+	if pn.redact != nil {
+		sms = pn.redact.SMS(sms)
+	}
+	return pn.inner.SendSMS(sms)
+}
+
+// queueDigest 将低优先级短信加入汇总队列，累积到 max_messages 条时设为立即推送
+func (pn *Notifier) queueDigest(sms *types.SMS, now time.Time) error {
+	releaseAt := pn.digest.next(now)
+	if err := pn.outbox.Add(pn.Name(), outbox.KindDigest, releaseAt, sms); err != nil {
+		return err
+	}
+	count := pn.outbox.Count(pn.Name(), outbox.KindDigest)
+	if max := pn.digest.cfg.MaxMessages; max > 0 && count >= max {
+		logger.Infof("%s 汇总队列已有 %d 条短信，立即推送", pn.Name(), count)
+		return pn.outbox.Release(pn.Name(), outbox.KindDigest, now)
+	}
+	if releaseAt.IsZero() {
+		logger.Infof("短信 %s 加入 %s 汇总队列（%d/%d）", sms.ID, pn.Name(), count, pn.digest.cfg.MaxMessages)
+	} else {
+		logger.Infof("短信 %s 加入 %s 汇总队列，将于 %s 推送", sms.ID, pn.Name(), releaseAt.Format("01-02 15:04"))
+	}
+	return nil
+}
+
+// Flush 将队列中已到发送时间的短信汇总为一条通知发送
+// 免打扰时段内暂缓发送汇总（hold 模式）或以低优先级发送（lower 模式）
+// 发送失败时短信保留在队列中，下次调用时重试
+// 参数: now - 当前时间
+func (pn *Notifier) Flush(now time.Time) error {
+	if pn.outbox == nil {
+		return nil
+	}
+	if err := pn.flush(outbox.KindHold, now, "免打扰期间收到 %d 条短信"); err != nil {
+		return err
+	}
+	return pn.flush(outbox.KindDigest, now, "短信汇总：%d 条低优先级短信")
+}
+
+// flush 发送一种类型中已到发送时间的短信
+func (pn *Notifier) flush(kind string, now time.Time, title string) error {
+	due := pn.outbox.Due(pn.Name(), kind, now)
+	if len(due) == 0 {
+		return nil
+	}
+
+	digest := buildDigest(due, title)
+	if kind == outbox.KindDigest && pn.quiet != nil && pn.quiet.active(now) {
+		if pn.quiet.cfg.Mode == QuietModeHold {
+			return nil
+		}
+		digest.Priority = types.PriorityLow
+	}
+	if err := pn.inner.SendSMS(digest); err != nil {
+		return fmt.Errorf("%s 发送短信汇总失败: %v", pn.Name(), err)
+	}
+	ids := make([]int64, 0, len(due))
+	for _, e := range due {
+		ids = append(ids, e.ID)
+	}
+	logger.Infof("%s 已发送 %d 条短信的汇总", pn.Name(), len(due))
+	return pn.outbox.Remove(ids...)
+}
+
+// buildDigest 将多条短信合并为一条汇总通知
+// 参数:
+//   - entries: 要汇总的短信
+//   - title: 标题格式，%d 为短信条数
+//
+// 返回: 汇总通知，标题和正文已填好，只有一条短信时返回该短信的副本
+func buildDigest(entries []outbox.Entry, title string) *types.SMS {
+	if len(entries) == 1 {
+		single := *entries[0].SMS
+		return &single
+	}
+
+	var body strings.Builder
+	for i, e := range entries {
+		if i > 0 {
+			body.WriteString("\n\n")
+		}
+		fmt.Fprintf(&body, "%d. %s（%s）\n%s", i+1, e.SMS.DisplaySender(), e.SMS.Timestamp, e.SMS.Content)
+	}
+	first := entries[0].SMS
+	return &types.SMS{
+		ID:        fmt.Sprintf("digest-%d", entries[0].ID),
+		Sender:    "digest",
+		Timestamp: time.Now().Format(time.RFC3339),
+		Content:   body.String(),
+		ModemID:   first.ModemID,
+		SIM:       first.SIM,
+		Priority:  types.PriorityNormal,
+		Title:     fmt.Sprintf(title, len(entries)),
+		Body:      body.String(),
+	}
+}
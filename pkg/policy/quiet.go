@@ -0,0 +1,132 @@
+// Package policy 提供按通知渠道配置的发送策略，如免打扰时段
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // 内置时区数据，很多嵌入式设备没有安装 zoneinfo
+
+	"sim-sms-forward/pkg/rules"
+	"sim-sms-forward/pkg/types"
+)
+
+// 免打扰时段内的处理方式
+const (
+	QuietModeLower = "lower" // 降为低优先级静默推送
+	QuietModeHold  = "hold"  // 暂存，免打扰时段结束后汇总推送
+)
+
+// QuietHours 免打扰时段配置
+type QuietHours struct {
+	Windows       []string `json:"windows"`                  // 时间段列表，格式 "HH:MM-HH:MM"，支持跨零点
+	Timezone      string   `json:"timezone,omitempty"`       // 时区，如 Asia/Shanghai，默认使用系统时区
+	Mode          string   `json:"mode,omitempty"`           // 处理方式: lower、hold，默认 lower
+	AllowSenders  []string `json:"allow_senders,omitempty"`  // 不受免打扰限制的发送方号码或通讯录名称，号码以 * 结尾表示前缀匹配
+	AllowKeywords []string `json:"allow_keywords,omitempty"` // 内容包含任意一个关键字时不受免打扰限制
+}
+
+// quietWindow 解析后的时间段（当天的分钟数）
+type quietWindow struct {
+	from, to int
+}
+
+// compiledQuietHours 解析后的免打扰时段
+type compiledQuietHours struct {
+	cfg      QuietHours
+	location *time.Location
+	windows  []quietWindow
+}
+
+// compileQuietHours 解析免打扰时段配置
+func compileQuietHours(cfg QuietHours) (*compiledQuietHours, error) {
+	if len(cfg.Windows) == 0 {
+		return nil, fmt.Errorf("quiet_hours.windows 不能为空")
+	}
+	switch cfg.Mode {
+	case "":
+		cfg.Mode = QuietModeLower
+	case QuietModeLower, QuietModeHold:
+	default:
+		return nil, fmt.Errorf("quiet_hours.mode 无效: %s，可选 lower、hold", cfg.Mode)
+	}
+
+	qh := &compiledQuietHours{cfg: cfg, location: time.Local}
+	if cfg.Timezone != "" {
+		location, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("quiet_hours.timezone 无效: %v", err)
+		}
+		qh.location = location
+	}
+	for _, w := range cfg.Windows {
+		from, to, err := rules.ParseTimeRange(w)
+		if err != nil {
+			return nil, fmt.Errorf("quiet_hours.windows: %v", err)
+		}
+		if from == to {
+			return nil, fmt.Errorf("quiet_hours.windows: 时间段 %s 的起点和终点不能相同", w)
+		}
+		qh.windows = append(qh.windows, quietWindow{from: from, to: to})
+	}
+	return qh, nil
+}
+
+// active 判断时间是否落在任意一个免打扰时段内
+func (qh *compiledQuietHours) active(now time.Time) bool {
+	local := now.In(qh.location)
+	for _, w := range qh.windows {
+		if rules.InTimeRange(w.from, w.to, local) {
+			return true
+		}
+	}
+	return false
+}
+
+// end 返回当前免打扰时段结束的时间，多个时间段首尾相接时返回最后一个的结束时间
+func (qh *compiledQuietHours) end(now time.Time) time.Time {
+	t := now.In(qh.location)
+	// 最多跨越所有时间段各一次，避免配置覆盖全天时无限循环
+	for i := 0; i <= len(qh.windows) && qh.active(t); i++ {
+		local := t.In(qh.location)
+		minute := local.Hour()*60 + local.Minute()
+		for _, w := range qh.windows {
+			if !rules.InTimeRange(w.from, w.to, local) {
+				continue
+			}
+			days := 0
+			if w.to <= minute {
+				days = 1
+			}
+			y, m, d := local.Date()
+			t = time.Date(y, m, d+days, w.to/60, w.to%60, 0, 0, qh.location)
+			break
+		}
+	}
+	return t
+}
+
+// bypass 判断短信是否不受免打扰限制
+// 验证码、紧急短信、白名单发送方和包含指定关键字的短信总是立即推送
+func (qh *compiledQuietHours) bypass(sms *types.SMS) (bool, string) {
+	if sms.Code != "" {
+		return true, "验证码"
+	}
+	if sms.Priority >= types.PriorityUrgent {
+		return true, "紧急短信"
+	}
+	for _, entry := range qh.cfg.AllowSenders {
+		if prefix, ok := strings.CutSuffix(entry, "*"); ok && strings.HasPrefix(sms.Sender, prefix) {
+			return true, "白名单 " + entry
+		}
+		if entry == sms.Sender || (sms.SenderName != "" && entry == sms.SenderName) {
+			return true, "白名单 " + entry
+		}
+	}
+	for _, keyword := range qh.cfg.AllowKeywords {
+		if keyword != "" && strings.Contains(sms.Content, keyword) {
+			return true, "关键字 " + keyword
+		}
+	}
+	return false, ""
+}
@@ -0,0 +1,20 @@
+package processor
+
+import (
+	"sim-sms-forward/pkg/types"
+)
+
+// SendHeartbeat 推送运行汇总到 heartbeat.notifiers 指定的通知渠道，未指定时推送到 MQTT 以外的所有渠道
+// 参数: sms - 运行汇总生成的通知
+// 返回: 有渠道推送失败时返回错误
+func (sp *SMSProcessor) SendHeartbeat(sms *types.SMS) error {
+	return sp.sendSystem(sms, sp.Config.Heartbeat.Notifiers)
+}
+
+// pendingCount 返回暂存队列中等待发送的短信数
+func (sp *SMSProcessor) pendingCount() int {
+	if sp.Outbox == nil {
+		return 0
+	}
+	return len(sp.Outbox.Pending())
+}
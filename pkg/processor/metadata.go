@@ -0,0 +1,46 @@
+// Package processor 提供短信处理的主要业务逻辑
+package processor
+
+import (
+	"regexp"
+
+	"sim-sms-forward/pkg/types"
+)
+
+// 验证码识别规则
+// otpAfterKeyword 匹配 "验证码：123456"、"code is 123456" 这类关键字在前的写法
+// otpBeforeKeyword 匹配 "123456是您的验证码" 这类数字在前的写法
+var (
+	otpAfterKeyword  = regexp.MustCompile(`(?i)(?:验证码|校验码|动态码|动态密码|确认码|激活码|验证代码|verification code|code|otp|pin)[^0-9a-z]{0,12}([0-9]{4,8})(?:[^0-9]|$)`)
+	otpBeforeKeyword = regexp.MustCompile(`(?:^|[^0-9])([0-9]{4,8})[^0-9]{0,8}(?:验证码|校验码|动态码|动态密码|确认码)`)
+)
+
+// ExtractMetadata 从短信中提取元数据，填充到 SMS 结构体中供路由规则和通知模板使用
+// 参数:
+//   - sms: 要填充的短信
+//   - modemID: 接收该短信的调制解调器ID
+func ExtractMetadata(sms *types.SMS, modemID string) {
+	sms.ModemID = modemID
+	sms.Code = extractOTP(sms.Content)
+	sms.Priority = derivePriority(sms)
+}
+
+// derivePriority 根据短信内容推导推送优先级
+// 含验证码的短信通常需要立即查看，提升为高优先级，其余为普通优先级
+func derivePriority(sms *types.SMS) types.Priority {
+	if sms.Code != "" {
+		return types.PriorityHigh
+	}
+	return types.PriorityNormal
+}
+
+// extractOTP 从短信内容中提取验证码，未识别到时返回空字符串
+func extractOTP(content string) string {
+	if match := otpAfterKeyword.FindStringSubmatch(content); len(match) >= 2 {
+		return match[1]
+	}
+	if match := otpBeforeKeyword.FindStringSubmatch(content); len(match) >= 2 {
+		return match[1]
+	}
+	return ""
+}
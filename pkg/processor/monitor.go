@@ -0,0 +1,103 @@
+package processor
+
+import (
+	"time"
+
+	"sim-sms-forward/pkg/events"
+	"sim-sms-forward/pkg/logger"
+	"sim-sms-forward/pkg/metrics"
+	"sim-sms-forward/pkg/types"
+)
+
+// modemWatchInterval 检查调制解调器状态变化的间隔
+const modemWatchInterval = 30 * time.Second
+
+// publish 发布事件，未启用事件流时忽略
+func (sp *SMSProcessor) publish(typ string, data interface{}) {
+	if sp.Events != nil {
+		sp.Events.Publish(typ, data)
+	}
+}
+
+// smsEvent 生成短信事件的内容
+func smsEvent(sms *types.SMS, archiveID int64) events.SMS {
+	return events.SMS{
+		SMSID:      sms.ID,
+		ArchiveID:  archiveID,
+		ModemID:    sms.ModemID,
+		Sender:     sms.Sender,
+		SenderName: sms.SenderName,
+		Location:   sms.Location,
+		Timestamp:  sms.Timestamp,
+		Content:    sms.Content,
+		Code:       sms.Code,
+		Priority:   sms.Priority.String(),
+		Spam:       sms.Spam,
+	}
+}
+
+// watchModem 定期检查调制解调器状态，更新监控指标和健康检查，状态或网络注册状态变化时发布 modem_state 事件，
+// 启用告警时在状态确认变化后推送告警
+// 启动后的第一次检查也会发布，订阅方可以据此得到当前状态
+func (sp *SMSProcessor) watchModem() {
+	ticker := time.NewTicker(modemWatchInterval)
+	defer ticker.Stop()
+	var last *events.Modem
+	for {
+		current := events.Modem{ModemID: sp.ModemManager.ID(), State: "unavailable"}
+		status, err := sp.ModemManager.GetStatus()
+		sp.Health.SetModem(status, err)
+		if sp.Alerts != nil {
+			if alert := sp.Alerts.Observe(status, err, time.Now()); alert != nil {
+				logger.Infof("调制解调器告警: %s", alert.Title)
+				if err := sp.sendSystem(alert, sp.Config.Alerts.Notifiers); err != nil {
+					logger.Errorf("推送调制解调器告警失败: %v", err)
+				}
+			}
+		}
+		if err != nil {
+			current.Error = err.Error()
+		} else {
+			current.State = status.State
+			current.Registration = status.RegistrationState
+			current.SignalQuality = status.SignalQuality
+			current.FailedReason = status.FailedReason
+			metrics.StoredSMS.Set(float64(status.StoredSMS), current.ModemID)
+		}
+		updateModemGauges(current)
+		if last == nil || last.State != current.State || last.Registration != current.Registration {
+			if last != nil {
+				current.Previous = last.State
+			}
+			sp.publish(events.TypeModemState, current)
+			last = &current
+		}
+		<-ticker.C
+	}
+}
+
+// updateModemGauges 更新调制解调器状态相关的监控指标
+func updateModemGauges(m events.Modem) {
+	metrics.SignalQuality.Set(float64(m.SignalQuality), m.ModemID)
+	metrics.ModemState.Reset()
+	metrics.ModemState.Set(1, m.ModemID, m.State)
+	metrics.RegistrationState.Reset()
+	if m.Registration != "" {
+		metrics.RegistrationState.Set(1, m.ModemID, m.Registration)
+	}
+}
+
+// collectOutbox 在输出监控指标前统计待发送队列的长度
+func (sp *SMSProcessor) collectOutbox() {
+	metrics.OutboxDepth.Reset()
+	if sp.Outbox == nil {
+		return
+	}
+	counts := make(map[[2]string]int)
+	for _, e := range sp.Outbox.Pending() {
+		counts[[2]string{e.Channel, e.Kind}]++
+	}
+	for key, count := range counts {
+		metrics.OutboxDepth.Set(float64(count), key[0], key[1])
+	}
+}
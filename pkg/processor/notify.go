@@ -0,0 +1,55 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"sim-sms-forward/pkg/logger"
+	"sim-sms-forward/pkg/notification"
+	"sim-sms-forward/pkg/policy"
+	"sim-sms-forward/pkg/types"
+)
+
+// sendSystem 推送程序自身生成的通知，如运行汇总和调制解调器告警
+// 不受免打扰时段和汇总推送的限制，某个渠道失败时继续推送其他渠道
+// 参数:
+//   - sms: 要推送的通知，标题和正文已填好
+//   - names: 推送的通知渠道，为空时推送到 MQTT 以外的所有渠道
+//
+// 返回: 有渠道推送失败时返回错误
+func (sp *SMSProcessor) sendSystem(sms *types.SMS, names []string) error {
+	var failures []string
+	for _, n := range sp.systemNotifiers(names) {
+		var err error
+		if pn, ok := n.(*policy.Notifier); ok {
+			err = pn.SendNow(sms)
+		} else {
+			err = n.SendSMS(sms)
+		}
+		if err != nil {
+			logger.Errorf("%s 推送「%s」失败: %v", n.Name(), sms.Title, err)
+			failures = append(failures, fmt.Sprintf("%s: %v", n.Name(), err))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// systemNotifiers 返回推送程序自身通知的渠道
+// 参数: names - 指定的通知渠道，为空时返回 MQTT 以外的所有渠道
+func (sp *SMSProcessor) systemNotifiers(names []string) []notification.Notifier {
+	selected := make(map[string]bool)
+	for _, name := range names {
+		selected[name] = true
+	}
+	var notifiers []notification.Notifier
+	for _, n := range sp.Notifiers {
+		if n.Name() == "mqtt" || (len(selected) > 0 && !selected[n.Name()]) {
+			continue
+		}
+		notifiers = append(notifiers, n)
+	}
+	return notifiers
+}
@@ -3,36 +3,329 @@ package processor
 
 import (
 	"fmt"
+	"path/filepath"
+	"time"
 
+	"sim-sms-forward/pkg/alerts"
+	"sim-sms-forward/pkg/archive"
 	"sim-sms-forward/pkg/config"
+	"sim-sms-forward/pkg/contacts"
+	"sim-sms-forward/pkg/events"
+	"sim-sms-forward/pkg/health"
+	"sim-sms-forward/pkg/heartbeat"
 	"sim-sms-forward/pkg/logger"
+	"sim-sms-forward/pkg/metrics"
 	"sim-sms-forward/pkg/modem"
+	"sim-sms-forward/pkg/mqtt"
 	"sim-sms-forward/pkg/notification"
+	"sim-sms-forward/pkg/numloc"
+	"sim-sms-forward/pkg/outbox"
+	"sim-sms-forward/pkg/policy"
+	"sim-sms-forward/pkg/recovery"
+	"sim-sms-forward/pkg/rules"
+	"sim-sms-forward/pkg/spam"
+	"sim-sms-forward/pkg/types"
+	"sim-sms-forward/pkg/vault"
+	"sim-sms-forward/pkg/web"
 )
 
 // SMSProcessor 短信处理器
 // 封装了调制解调器管理器和通知客户端，提供短信处理的核心功能
 type SMSProcessor struct {
-	Config       *config.Config             // 配置对象
-	ModemManager *modem.Manager             // 调制解调器管理器
-	BarkClient   *notification.BarkClient   // Bark 通知客户端
-	HismsgClient *notification.HismsgClient // hismsg 通知客户端
+	Config       *config.Config          // 配置对象
+	ModemManager *modem.Manager          // 调制解调器管理器
+	Notifiers    []notification.Notifier // 已启用的通知渠道，按配置顺序发送
+	MQTT         *mqtt.Bridge            // MQTT 桥接，未启用时为 nil
+	Rules        *rules.Engine           // 路由规则引擎
+	Spam         *spam.Filter            // 垃圾短信过滤器，未启用时为 nil
+	Contacts     *contacts.Book          // 通讯录，用于显示发送方名称
+	Locator      *numloc.Locator         // 号码归属地查询，未启用时为 nil
+	Outbox       *outbox.Outbox          // 暂存短信的持久化队列
+	Archive      *archive.Archive        // 短信归档，未启用时为 nil
+	Key          *vault.Key              // 归档和暂存队列的加密密钥，未配置加密时为 nil
+	Web          *web.Server             // 网页控制台，未启用时为 nil
+	Events       *events.Bus             // 事件流，随网页控制台启用，由 Start 打开，未启用时为 nil
+	Metrics      *metrics.Server         // 监控端点，未启用时为 nil
+	Health       *health.Monitor         // 健康检查，未启用时为 nil
+	Heartbeat    *heartbeat.Heartbeat    // 心跳，未配置时为 nil
+	Alerts       *alerts.Monitor         // 调制解调器状态告警，未启用时为 nil
+	Recovery     *recovery.Ladder        // 调制解调器自动恢复，未启用时为 nil
+	simICCID     string                  // 缓存的 SIM 卡 ICCID，用于规则匹配
+	simChecked   time.Time               // 上次获取 ICCID 的时间
 }
 
-// NewSMSProcessorWithConfig 创建并返回一个使用配置对象的新短信处理器实例
+// simCacheTTL 缓存 SIM 卡 ICCID 的时间，过期后重新获取，热插拔更换 SIM 卡后最多这么久就能匹配到新卡
+const simCacheTTL = 5 * time.Minute
+
+// NewSMSProcessorWithConfig 创建并返回一个使用配置对象的新短信处理器实例，用于命令行工具
+// 加密密钥或归档、暂存队列打开失败时只记录错误，归档和暂存队列不可用
 // 参数:
 //   - cfg: 配置对象指针
 //
 // 返回: 初始化好的 SMSProcessor 指针
 func NewSMSProcessorWithConfig(cfg *config.Config) *SMSProcessor {
-	return &SMSProcessor{
+	key, err := vault.Open(cfg.Encryption, cfg.DataDir)
+	if err != nil {
+		logger.Errorf("打开加密密钥失败: %v", err)
+		sp, _ := newSMSProcessor(cfg, nil, false)
+		return sp
+	}
+	sp, err := NewSMSProcessorWithKey(cfg, key)
+	if err != nil {
+		logger.Errorf("%v", err)
+		sp, _ = newSMSProcessor(cfg, nil, false)
+	}
+	return sp
+}
+
+// NewSMSProcessorWithKey 使用已经打开的加密密钥创建短信处理器
+// 参数:
+//   - cfg: 配置对象指针
+//   - key: 归档和暂存队列的加密密钥，未配置加密时为 nil
+//
+// 返回: 初始化好的 SMSProcessor 指针，归档或暂存队列打开失败（如数据已加密但未配置
+// encryption）时返回错误，避免在没有归档和发送策略的情况下继续运行
+func NewSMSProcessorWithKey(cfg *config.Config, key *vault.Key) (*SMSProcessor, error) {
+	return newSMSProcessor(cfg, key, true)
+}
+
+// newSMSProcessor 创建短信处理器，storage 为 false 时不打开归档和暂存队列
+func newSMSProcessor(cfg *config.Config, key *vault.Key, storage bool) (*SMSProcessor, error) {
+	notifiers, err := cfg.BuildNotifiers()
+	if err != nil {
+		// 配置在加载时已经验证过，这里只记录错误
+		logger.Errorf("创建通知渠道失败: %v", err)
+	}
+	sp := &SMSProcessor{
 		Config:       cfg,
 		ModemManager: modem.NewManager(cfg.ModemID),
-		BarkClient:   notification.NewBarkClient(cfg.BarkKey, cfg.BarkAPIURL),
-		HismsgClient: notification.NewHismsgClient(cfg.HismsgKey, cfg.HismsgAPIURL, cfg.DeviceID),
+		Notifiers:    notifiers,
+		Contacts:     contacts.NewBook(cfg.Contacts, cfg.DataDir),
+		Key:          key,
+	}
+	if cfg.MQTT.Enable {
+		sp.MQTT = mqtt.NewBridge(cfg.MQTT, cfg.DeviceID)
+		sp.Notifiers = append(sp.Notifiers, sp.MQTT)
+	}
+	if sp.Rules, err = rules.Compile(cfg.Rules); err != nil {
+		logger.Errorf("编译路由规则失败: %v", err)
+		sp.Rules = &rules.Engine{}
+	}
+	if len(cfg.Channels) > 0 && storage {
+		if err := sp.wrapPolicies(); err != nil {
+			return nil, err
+		}
+	}
+	if !cfg.Location.Disable {
+		sp.Locator = numloc.NewLocator(cfg.Location, cfg.DataDir)
+		if sp.Locator.Source() == "" {
+			logger.Infof("号段数据库 %s 不存在，程序也没有内置数据库，只能查询运营商和固话区号", sp.Locator.Path())
+		}
+	}
+	if !cfg.Archive.Disable && storage {
+		if sp.Archive, err = archive.Open(cfg.Archive, cfg.DataDir, key); err != nil {
+			return nil, fmt.Errorf("打开短信归档失败: %v", err)
+		}
+	}
+	if cfg.Web.Enable && storage {
+		sp.Web = web.NewServer(cfg.Web, cfg.DeviceID)
+	}
+	if cfg.Metrics.Enable && storage {
+		sp.Metrics = metrics.NewServer(cfg.Metrics)
+	}
+	if cfg.Health.Enable && storage {
+		sp.Health = health.NewMonitor(cfg.Health, cfg.GetSleepDuration())
+	}
+	if cfg.Heartbeat.Enabled() && storage {
+		sp.Heartbeat = heartbeat.New(cfg.Heartbeat, cfg.DeviceID)
+	}
+	if cfg.Alerts.Enable && storage {
+		sp.Alerts = alerts.NewMonitor(cfg.Alerts, cfg.DeviceID)
+	}
+	if cfg.Recovery.Enable && storage {
+		sp.Recovery = recovery.NewLadder(cfg.Recovery, cfg.DeviceID, sp.ModemManager)
+	}
+	if cfg.Spam.Enable {
+		sp.Spam = spam.NewFilter(cfg.Spam, cfg.DataDir, key)
+		if sp.MQTT != nil {
+			sp.MQTT.SetTrainFunc(sp.Spam.Train)
+		}
+	}
+	return sp, nil
+}
+
+// Route 根据路由规则决定短信要发送到哪些通知渠道，并将规则的优先级和模板覆盖应用到短信上
+// 参数:
+//   - sms: 已提取元数据的短信
+//   - now: 当前时间，用于规则的时间段匹配
+//
+// 返回:
+//   - 路由结果和每条规则的匹配过程
+//   - 要发送的通知渠道，短信被丢弃时为空
+//   - 模板渲染失败时返回错误
+func (sp *SMSProcessor) Route(sms *types.SMS, now time.Time) (*rules.Decision, []rules.Trace, []notification.Notifier, error) {
+	decision, traces := sp.Rules.Evaluate(sms, now)
+	if err := decision.Apply(sms); err != nil {
+		return decision, traces, nil, err
+	}
+	if decision.Drop {
+		return decision, traces, nil, nil
+	}
+	if len(decision.Notifiers) == 0 {
+		return decision, traces, sp.Notifiers, nil
+	}
+
+	selected := make(map[string]bool)
+	for _, name := range decision.Notifiers {
+		selected[name] = true
+	}
+	var notifiers []notification.Notifier
+	for _, n := range sp.Notifiers {
+		if selected[n.Name()] {
+			notifiers = append(notifiers, n)
+		}
+	}
+	return decision, traces, notifiers, nil
+}
+
+// wrapPolicies 为配置了发送策略的通知渠道附加策略
+// 返回: 暂存队列打开失败或发送策略无效时返回错误
+func (sp *SMSProcessor) wrapPolicies() error {
+	ob, err := outbox.Open(filepath.Join(sp.Config.DataDir, "outbox.json"), sp.Key)
+	if err != nil {
+		return fmt.Errorf("打开暂存队列失败: %v", err)
+	}
+	notifiers, err := policy.Wrap(sp.Notifiers, sp.Config.Channels, ob)
+	if err != nil {
+		return fmt.Errorf("创建发送策略失败: %v", err)
+	}
+	sp.Outbox = ob
+	sp.Notifiers = notifiers
+	return nil
+}
+
+// flushOutbox 定期发送暂存队列中已到发送时间的短信
+func (sp *SMSProcessor) flushOutbox() {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		for _, n := range sp.Notifiers {
+			if pn, ok := n.(*policy.Notifier); ok {
+				if err := pn.Flush(time.Now()); err != nil {
+					logger.Errorf("%v", err)
+				}
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// archive 保存归档记录，归档失败只记录日志，不影响短信的转发和删除
+func (sp *SMSProcessor) archive(record *archive.Record) {
+	if sp.Archive == nil {
+		return
+	}
+	if err := sp.Archive.Put(record); err != nil {
+		logger.Errorf("归档短信 %s 失败: %v", record.SMSID, err)
+	}
+}
+
+// LookupSender 查询发送方的通讯录名称和归属地
+func (sp *SMSProcessor) LookupSender(sms *types.SMS) {
+	sms.SenderName = sp.Contacts.Lookup(sms.Sender)
+	if sp.Locator != nil {
+		sms.Location = sp.Locator.Lookup(sms.Sender)
 	}
 }
 
+// currentSIM 返回当前 SIM 卡的 ICCID，成功获取后缓存 simCacheTTL
+// 检查调制解调器失败（如 SIM 卡被拔出）或调制解调器换了ID时清除缓存
+// 获取失败时返回空字符串，下次处理短信时重试
+func (sp *SMSProcessor) currentSIM() string {
+	if sp.simICCID != "" && time.Since(sp.simChecked) < simCacheTTL {
+		return sp.simICCID
+	}
+	info, err := sp.ModemManager.GetSIMInfo()
+	if err != nil {
+		logger.Errorf("获取 SIM 卡信息失败: %v", err)
+		sp.simICCID = ""
+		return ""
+	}
+	if sp.simICCID != "" && sp.simICCID != info.ICCID {
+		logger.Infof("SIM 卡已更换: %s", info.ICCID)
+	}
+	sp.simICCID = info.ICCID
+	sp.simChecked = time.Now()
+	return sp.simICCID
+}
+
+// modemChanged 在调制解调器换了ID后清除旧ID的监控指标和缓存的 SIM 卡信息
+func (sp *SMSProcessor) modemChanged() {
+	metrics.SignalQuality.Reset()
+	metrics.StoredSMS.Reset()
+	metrics.StorageUsed.Reset()
+	metrics.StorageCapacity.Reset()
+	sp.simICCID = ""
+}
+
+// Start 启动处理器的后台服务，如 MQTT 连接和状态发布
+// 应在开始循环处理短信之前调用一次。事件文件在这里打开，只有转发服务发布事件，
+// 命令行工具不调用 Start，不会和转发服务同时写入 events.jsonl 产生重复的事件序号
+// 返回: 事件文件打开失败（如数据已加密但未配置 encryption）时返回错误
+func (sp *SMSProcessor) Start() error {
+	if sp.Web != nil {
+		bus, err := events.Open(sp.Config.DataDir, sp.Key)
+		if err != nil {
+			return fmt.Errorf("打开事件文件失败: %v", err)
+		}
+		sp.Events = bus
+	}
+	if sp.MQTT != nil {
+		sp.MQTT.Start(sp.modemStatus, sp.ModemManager.SendSMS)
+	}
+	if sp.Outbox != nil {
+		go sp.flushOutbox()
+	}
+	if sp.Events != nil || sp.Metrics != nil || sp.Health != nil || sp.Alerts != nil {
+		go sp.watchModem()
+	}
+	if sp.Health != nil {
+		// 未配置独立地址时挂载在监控端点上，配置文件加载时已经验证过监控端点已启用
+		if sp.Config.Health.Listen == "" && sp.Metrics != nil {
+			sp.Health.Handle(sp.Metrics.Handle)
+		} else {
+			sp.Health.Start()
+		}
+	}
+	if sp.Config.Storage.Enable {
+		go sp.watchStorage()
+	}
+	if sp.Heartbeat != nil {
+		sp.Heartbeat.Start(sp.SendHeartbeat, sp.ModemManager.GetStatus, sp.pendingCount)
+	}
+	if sp.Metrics != nil {
+		metrics.StartTime.Set(float64(time.Now().Unix()))
+		metrics.OnCollect(sp.collectOutbox)
+		sp.Metrics.Start()
+	}
+	if sp.Web != nil {
+		channels := make([]string, 0, len(sp.Notifiers))
+		for _, n := range sp.Notifiers {
+			channels = append(channels, n.Name())
+		}
+		sp.Web.Start(web.Backend{
+			Status:   sp.ModemManager.GetStatus,
+			SIM:      sp.ModemManager.GetSIMInfo,
+			Send:     sp.ModemManager.SendSMS,
+			Retry:    sp.Retry,
+			Archive:  sp.Archive,
+			Events:   sp.Events,
+			Channels: channels,
+		})
+	}
+	return nil
+}
+
 // NewSMSProcessor 创建并返回一个新的短信处理器实例（兼容旧接口）
 // 参数:
 //   - modemID: 调制解调器的ID字符串
@@ -55,7 +348,7 @@ func NewSMSProcessor(modemID, barkKey string) *SMSProcessor {
 }
 
 // processSMS 处理单条短信的完整流程
-// 包括：提取短信信息、显示详情、发送 Bark 通知、删除短信
+// 包括：提取短信信息、显示详情、发送通知、删除短信
 // 参数: smsID - 要处理的短信ID
 // 返回: 处理成功返回 nil，失败返回错误
 func (sp *SMSProcessor) processSMS(smsID string) error {
@@ -70,36 +363,88 @@ func (sp *SMSProcessor) processSMS(smsID string) error {
 	// 在控制台和日志中显示短信详细信息
 	logger.Info("======================================")
 	logger.Infof("短信 ID: %s", sms.ID)
-	logger.Infof("发送方号码: %s", sms.Sender)
+	logger.Infof("发送方号码: %s", logger.Phone(sms.Sender))
 	logger.Infof("接收时间: %s", sms.Timestamp)
-	logger.Infof("短信内容: %s", sms.Content)
+	logger.Infof("短信内容: %s", logger.Content(sms.Content))
 	logger.Info("======================================")
 
-	// 通过 Bark 服务发送推送通知（根据配置开关决定是否发送）
-	if sp.Config.EnableBark {
-		if err := sp.BarkClient.SendSMS(sms); err != nil {
-			return fmt.Errorf("Bark通知异常: %v", err)
+	metrics.SMSReceived.Inc(sp.ModemManager.ID())
+	sp.Heartbeat.Received()
+
+	// 提取验证码等元数据，供路由规则和通知模板使用
+	ExtractMetadata(sms, sp.ModemManager.ID())
+	sms.SIM = sp.currentSIM()
+	sp.LookupSender(sms)
+
+	// 垃圾短信过滤，被过滤的短信先归档，归档失败时保留在调制解调器上等待下次处理
+	if sp.Spam != nil {
+		verdict := sp.Spam.Check(sms)
+		if verdict.Spam {
+			sms.Spam = true
+			logger.Infof("短信 %s 被判定为垃圾短信（%s）: %v", sms.ID, sp.Spam.Action(), verdict.Reasons)
+			if err := sp.Spam.Quarantine(sms, verdict); err != nil {
+				return err
+			}
 		}
-		logger.Info("Bark 通知发送成功")
-	} else {
-		//logger.Info("Bark 通知已禁用，跳过发送")
+	}
+	sp.publish(events.TypeSMSReceived, smsEvent(sms, 0))
+
+	// 根据路由规则选择通知渠道
+	decision, _, notifiers, err := sp.Route(sms, time.Now())
+	if err != nil {
+		return fmt.Errorf("应用路由规则失败: %v", err)
+	}
+	if len(decision.Matched) > 0 {
+		logger.Infof("短信 %s 命中路由规则: %v", sms.ID, decision.Matched)
+	}
+	if decision.Drop {
+		logger.Infof("短信 %s 被路由规则丢弃，不发送通知", sms.ID)
 	}
 
-	// 通过 Hismsg 服务发送推送通知（根据配置开关决定是否发送）
-	if sp.Config.EnableHismsg {
-		if err := sp.HismsgClient.SendSMS(sms); err != nil {
-			return fmt.Errorf("Hismsg通知异常: %v", err)
+	// 垃圾短信不打扰用户：静默推送时降为低优先级，否则不推送
+	if sms.Spam {
+		if sp.Spam.Action() == spam.ActionDrop {
+			notifiers = nil
 		}
-		logger.Info("Hismsg 通知发送成功")
-	} else {
-		//logger.Info("Hismsg 通知已禁用，跳过发送")
+		sms.Priority = types.PriorityLow
 	}
 
+	// 依次通过选中的通知渠道发送推送通知，发送失败时也归档，记录各渠道的投递结果
+	record := archive.NewRecord(sms, decision.Matched, decision.Drop)
+	forwarded := smsEvent(sms, 0)
+	for _, n := range notifiers {
+		err := n.SendSMS(sms)
+		record.AddDelivery(n.Name(), err)
+		sp.Health.Delivered(n.Name(), err)
+		if err != nil {
+			metrics.SMSFailed.Inc(n.Name(), sms.ModemID)
+			sp.Heartbeat.Failed()
+			sp.archive(record)
+			sp.publish(events.TypeDeliveryFailed, events.Delivery{
+				SMSID:     sms.ID,
+				ArchiveID: record.ID,
+				Sender:    sms.Sender,
+				Channel:   n.Name(),
+				Error:     err.Error(),
+			})
+			return fmt.Errorf("%s通知异常: %v", n.Name(), err)
+		}
+		forwarded.Channels = append(forwarded.Channels, n.Name())
+		metrics.SMSForwarded.Inc(n.Name(), sms.ModemID)
+		metrics.DeliveryLatency.Observe(time.Since(record.ReceivedAt).Seconds(), n.Name())
+		logger.Infof("%s 通知发送成功", n.Name())
+	}
+	sp.archive(record)
+	forwarded.ArchiveID = record.ID
+	forwarded.Dropped = len(notifiers) == 0
+	sp.publish(events.TypeSMSForwarded, forwarded)
+
 	// 从调制解调器中删除已处理的短信
 	if err := sp.ModemManager.DeleteSMS(sms.ID); err != nil {
 		return fmt.Errorf("删除短信失败: %v", err)
 	}
 	logger.Infof("短信 %s 已从调制解调器删除", sms.ID)
+	sp.publish(events.TypeSMSDeleted, events.SMS{SMSID: sms.ID, ArchiveID: record.ID, ModemID: sms.ModemID, Sender: sms.Sender})
 
 	logger.Infof("短信 %s 处理完成", smsID)
 	return nil
@@ -109,8 +454,33 @@ func (sp *SMSProcessor) processSMS(smsID string) error {
 // 这是主要的对外接口，封装了完整的短信处理流程
 // 包括：环境检查、获取短信列表、逐个处理短信
 // 返回: 处理成功返回 nil，失败返回错误
-func (sp *SMSProcessor) ProcessAllSMS() error {
-	logger.Infof("开始处理调制解调器 %s 上的所有短信", sp.ModemManager.ModemID)
+func (sp *SMSProcessor) ProcessAllSMS() (err error) {
+	failures := 0
+	defer func() {
+		if err == nil {
+			metrics.LastCycle.Set(float64(time.Now().Unix()))
+		} else {
+			// 调制解调器不可用时可能正在更换 SIM 卡，恢复后重新获取 ICCID
+			sp.simICCID = ""
+		}
+		// 有短信处理失败时不报告心跳正常，避免通知渠道持续失败时外部监控仍然显示正常
+		sp.Heartbeat.CycleDone(err == nil && failures == 0)
+		// 检查失败也算完成一轮，调制解调器的问题由就绪检查和告警报告
+		sp.Health.CycleDone()
+		if sp.Recovery != nil {
+			id := sp.ModemManager.ID()
+			notice := sp.Recovery.Observe(err, time.Now())
+			if sp.ModemManager.ID() != id {
+				sp.modemChanged()
+			}
+			if notice != nil {
+				if err := sp.sendSystem(notice, sp.Config.Recovery.Notifiers); err != nil {
+					logger.Errorf("推送自动恢复通知失败: %v", err)
+				}
+			}
+		}
+	}()
+	logger.Infof("开始处理调制解调器 %s 上的所有短信", sp.ModemManager.ID())
 
 	// 检查前置条件：mmcli 命令和调制解调器可用性
 	if err := sp.ModemManager.CheckMMCLI(); err != nil {
@@ -128,11 +498,11 @@ func (sp *SMSProcessor) ProcessAllSMS() error {
 	}
 	if len(smsIDs) == 0 {
 		// 如果没有短信，直接返回
-		logger.Infof("调制解调器 %s 上没有接收状态的短信", sp.ModemManager.ModemID)
+		logger.Infof("调制解调器 %s 上没有接收状态的短信", sp.ModemManager.ID())
 		return nil
 	}
 
-	logger.Infof("正在读取调制解调器 %s 上所有接收的短信（received状态）...", sp.ModemManager.ModemID)
+	logger.Infof("正在读取调制解调器 %s 上所有接收的短信（received状态）...", sp.ModemManager.ID())
 	logger.Info("--------------------------------------")
 
 	// 逐个处理每条短信，失败时记录错误但继续处理其他短信
@@ -140,12 +510,13 @@ func (sp *SMSProcessor) ProcessAllSMS() error {
 	for _, smsID := range smsIDs {
 		if err := sp.processSMS(smsID); err != nil {
 			logger.Errorf("处理短信 %s 失败: %v", smsID, err)
+			failures++
 			continue
 		}
 		successCount++
 	}
 
 	logger.Infof("调制解调器 %s 上短信处理完毕，成功处理 %d/%d 条短信",
-		sp.ModemManager.ModemID, successCount, len(smsIDs))
+		sp.ModemManager.ID(), successCount, len(smsIDs))
 	return nil
 }
@@ -0,0 +1,141 @@
+package processor
+
+import (
+	"fmt"
+
+	"sim-sms-forward/pkg/archive"
+	"sim-sms-forward/pkg/events"
+	"sim-sms-forward/pkg/notification"
+	"sim-sms-forward/pkg/policy"
+	"sim-sms-forward/pkg/spam"
+	"sim-sms-forward/pkg/types"
+)
+
+// ReplayResult 重新发送一条归档短信的结果
+type ReplayResult struct {
+	Record    *archive.Record // 归档记录
+	SMS       *types.SMS      // 按当前配置重新处理后的短信
+	Title     string          // 通知标题
+	Matched   []string        // 命中的路由规则
+	Notifiers []string        // 要发送的通知渠道
+	Skipped   string          // 不发送的原因，为空表示会发送
+	Errors    map[string]error
+}
+
+// Replay 按当前的路由规则和模板重新发送一条归档短信，通知中会标记为重发
+// MQTT 需要常驻连接，不支持重新发送；配置了发送策略的渠道只应用内容脱敏，不受免打扰和汇总推送限制
+// 参数:
+//   - record: 归档记录
+//   - only: 指定的通知渠道名称，为空时按路由规则选择；指定时忽略规则的渠道选择和丢弃
+//   - dryRun: 只预览，不发送也不更新归档
+//
+// 返回: 重新发送的结果，路由规则应用失败时返回错误
+func (sp *SMSProcessor) Replay(record *archive.Record, only []string, dryRun bool) (*ReplayResult, error) {
+	sms := record.SMS()
+	ExtractMetadata(sms, record.ModemID)
+	sp.LookupSender(sms)
+	sms.Replay = true
+
+	decision, _, notifiers, err := sp.Route(sms, record.ReceivedAt)
+	if err != nil {
+		return nil, fmt.Errorf("应用路由规则失败: %v", err)
+	}
+	result := &ReplayResult{
+		Record:  record,
+		SMS:     sms,
+		Title:   notification.FormatTitle(sms),
+		Matched: decision.Matched,
+		Errors:  make(map[string]error),
+	}
+
+	if len(only) > 0 {
+		notifiers = nil
+		for _, n := range sp.Notifiers {
+			for _, name := range only {
+				if n.Name() == name {
+					notifiers = append(notifiers, n)
+				}
+			}
+		}
+	} else if decision.Drop {
+		result.Skipped = "被路由规则丢弃"
+	} else if sms.Spam && sp.Spam != nil && sp.Spam.Action() == spam.ActionDrop {
+		result.Skipped = "垃圾短信不推送"
+		notifiers = nil
+	}
+	if sms.Spam {
+		sms.Priority = types.PriorityLow
+	}
+
+	var targets []notification.Notifier
+	for _, n := range notifiers {
+		if n.Name() == "mqtt" {
+			continue
+		}
+		targets = append(targets, n)
+		result.Notifiers = append(result.Notifiers, n.Name())
+	}
+	if result.Skipped == "" && len(targets) == 0 {
+		result.Skipped = "没有可以重新发送的通知渠道"
+	}
+	if dryRun || len(targets) == 0 {
+		return result, nil
+	}
+
+	for _, n := range targets {
+		if pn, ok := n.(*policy.Notifier); ok {
+			err = pn.SendNow(sms)
+		} else {
+			err = n.SendSMS(sms)
+		}
+		record.AddReplay(n.Name(), err)
+		sp.Health.Delivered(n.Name(), err)
+		if err != nil {
+			result.Errors[n.Name()] = err
+			sp.publish(events.TypeDeliveryFailed, events.Delivery{
+				SMSID:     sms.ID,
+				ArchiveID: record.ID,
+				Sender:    sms.Sender,
+				Channel:   n.Name(),
+				Error:     err.Error(),
+				Replay:    true,
+			})
+		}
+	}
+	if sp.Archive != nil {
+		if err := sp.Archive.Put(record); err != nil {
+			return result, fmt.Errorf("更新归档记录 %d 失败: %v", record.ID, err)
+		}
+	}
+	return result, nil
+}
+
+// Retry 将一条归档短信重新发送到指定的通知渠道，用于网页控制台的手动重试
+// 参数:
+//   - id: 归档序号
+//   - channel: 通知渠道名称
+//
+// 返回: 发送成功返回 nil，短信不存在或发送失败时返回错误
+func (sp *SMSProcessor) Retry(id int64, channel string) error {
+	if sp.Archive == nil {
+		return fmt.Errorf("短信归档未启用")
+	}
+	if channel == "mqtt" {
+		return fmt.Errorf("MQTT 不支持重新发送")
+	}
+	records, err := sp.Archive.Search(archive.Query{IDs: []int64{id}})
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("归档短信 #%d 不存在", id)
+	}
+	result, err := sp.Replay(records[0], []string{channel}, false)
+	if err != nil {
+		return err
+	}
+	if len(result.Notifiers) == 0 {
+		return fmt.Errorf("通知渠道 %s 未启用", channel)
+	}
+	return result.Errors[channel]
+}
@@ -0,0 +1,131 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"sim-sms-forward/pkg/logger"
+	"sim-sms-forward/pkg/metrics"
+	"sim-sms-forward/pkg/types"
+)
+
+// storageCheckInterval 检查短信存储的间隔
+const storageCheckInterval = 5 * time.Minute
+
+// storageRecoverMargin 告警后使用率降到阈值以下这个幅度才算恢复，避免在阈值附近反复告警
+const storageRecoverMargin = 10
+
+// watchStorage 定期检查短信存储，按配置清理指定状态的短信，使用率达到阈值时告警，降回阈值以下时推送恢复通知
+func (sp *SMSProcessor) watchStorage() {
+	cfg := sp.Config.Storage
+	threshold := cfg.Threshold
+	if threshold == 0 {
+		threshold = 80
+	}
+	ticker := time.NewTicker(storageCheckInterval)
+	defer ticker.Stop()
+	alerted, warnedUnknown := false, false
+	for {
+		if len(cfg.Purge) > 0 {
+			if _, err := sp.ModemManager.PurgeSMS(cfg.Purge); err != nil {
+				logger.Errorf("清理短信失败: %v", err)
+			}
+		}
+
+		status, err := sp.ModemManager.GetStorageStatus(cfg.Capacity)
+		if err != nil {
+			logger.Errorf("%v", err)
+			<-ticker.C
+			continue
+		}
+		metrics.StorageUsed.Set(float64(status.Used), sp.ModemManager.ID())
+		metrics.StorageCapacity.Set(float64(status.Total), sp.ModemManager.ID())
+
+		percent := status.Percent()
+		switch {
+		case percent < 0:
+			if !warnedUnknown {
+				logger.Errorf("无法读取短信存储容量，不检查存储使用率，可以在配置的 storage.capacity 中指定容量")
+				warnedUnknown = true
+			}
+		case !alerted && percent >= threshold:
+			alerted = true
+			sp.sendStorageAlert(status, fmt.Sprintf("短信存储即将用满（%d%%）", percent), types.PriorityHigh)
+		case alerted && percent < threshold-storageRecoverMargin:
+			alerted = false
+			sp.sendStorageAlert(status, fmt.Sprintf("短信存储空间已恢复（%d%%）", percent), types.PriorityNormal)
+		}
+		<-ticker.C
+	}
+}
+
+// modemStatus 获取调制解调器状态和短信存储的已用条数、容量，用于 MQTT 状态发布
+// 存储状态读取失败时只发布调制解调器状态
+func (sp *SMSProcessor) modemStatus() (*types.ModemStatus, error) {
+	status, err := sp.ModemManager.GetStatus()
+	if err != nil {
+		return nil, err
+	}
+	storage, err := sp.ModemManager.GetStorageStatus(sp.Config.Storage.Capacity)
+	if err != nil {
+		logger.Errorf("%v", err)
+		return status, nil
+	}
+	status.Storage = storage
+	return status, nil
+}
+
+// sendStorageAlert 推送短信存储告警
+func (sp *SMSProcessor) sendStorageAlert(status *types.StorageStatus, title string, priority types.Priority) {
+	now := time.Now()
+	body := FormatStorage(status)
+	if priority == types.PriorityHigh {
+		body += "\n存储用满后网络将无法投递新短信，请清理调制解调器上的短信"
+	}
+	logger.Infof("短信存储告警: %s", title)
+	alert := &types.SMS{
+		ID:        fmt.Sprintf("storage-%d", now.Unix()),
+		Sender:    "modem",
+		Timestamp: now.Format(time.RFC3339),
+		Content:   body,
+		ModemID:   sp.ModemManager.ID(),
+		Priority:  priority,
+		Title:     title,
+		Body:      body,
+	}
+	if err := sp.sendSystem(alert, sp.Config.Storage.Notifiers); err != nil {
+		logger.Errorf("推送短信存储告警失败: %v", err)
+	}
+}
+
+// FormatStorage 将短信存储的使用情况格式化为多行文本
+func FormatStorage(status *types.StorageStatus) string {
+	var b strings.Builder
+	if status.Total > 0 {
+		fmt.Fprintf(&b, "已用: %d/%d（%d%%）\n", status.Used, status.Total, status.Percent())
+	} else {
+		fmt.Fprintf(&b, "已用: %d（容量未知）\n", status.Used)
+	}
+	if status.DefaultStorage != "" {
+		fmt.Fprintf(&b, "默认存储: %s（支持 %s）\n", status.DefaultStorage, strings.Join(status.SupportedStorages, "、"))
+	}
+	// AT+CPMS? 分别报告读取、发送和接收使用的存储，通常是同一个存储
+	seen := make(map[string]bool)
+	for _, s := range status.Storages {
+		if !seen[s.Name] {
+			seen[s.Name] = true
+			fmt.Fprintf(&b, "存储 %s: %d/%d\n", s.Name, s.Used, s.Total)
+		}
+	}
+	var states []string
+	for _, state := range []string{"received", "receiving", "sent", "sending", "stored", "unknown"} {
+		if n := status.States[state]; n > 0 {
+			states = append(states, fmt.Sprintf("%s %d", state, n))
+		}
+	}
+	if len(states) > 0 {
+		fmt.Fprintf(&b, "短信: %s", strings.Join(states, "，"))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
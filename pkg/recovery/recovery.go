@@ -0,0 +1,252 @@
+// Package recovery 在调制解调器持续不可用时按顺序执行恢复措施
+// 恢复措施从轻到重依次为重新启用、重置、让 ModemManager 重新扫描和 USB 端口断电重连，
+// 每一步之后等待冷却时间，调制解调器仍不可用时才执行下一步
+package recovery
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"sim-sms-forward/pkg/logger"
+	"sim-sms-forward/pkg/metrics"
+	"sim-sms-forward/pkg/modem"
+	"sim-sms-forward/pkg/types"
+)
+
+// 恢复措施
+const (
+	StepEnable = "enable" // mmcli -m <ID> -e
+	StepReset  = "reset"  // mmcli -m <ID> -r
+	StepRescan = "rescan" // mmcli -S
+	StepUSB    = "usb"    // 通过 sysfs 的 authorized 文件让 USB 设备断开后重新连接
+)
+
+// DefaultSteps 未配置时使用的恢复措施，USB 断电重连需要指定设备，默认不启用
+var DefaultSteps = []string{StepEnable, StepReset, StepRescan}
+
+// descriptions 各恢复措施的说明
+var descriptions = map[string]string{
+	StepEnable: "重新启用调制解调器",
+	StepReset:  "重置调制解调器",
+	StepRescan: "重新扫描调制解调器",
+	StepUSB:    "USB 端口断电重连",
+}
+
+// usbOffDuration USB 设备断开后等待多久再重新连接
+const usbOffDuration = 3 * time.Second
+
+// Config 自动恢复配置
+type Config struct {
+	Enable    bool     `json:"enable"`               // 是否启用自动恢复
+	Failures  int      `json:"failures,omitempty"`   // 连续失败多少次检查后开始恢复，默认 3
+	Steps     []string `json:"steps,omitempty"`      // 按顺序执行的恢复措施，默认 enable、reset、rescan
+	Cooldown  int      `json:"cooldown,omitempty"`   // 每一步之后等待多久再执行下一步（秒），默认 120
+	USBDevice string   `json:"usb_device,omitempty"` // usb 措施使用的 sysfs 设备目录，如 /sys/bus/usb/devices/1-1
+	Notifiers []string `json:"notifiers,omitempty"`  // 接收恢复通知的通知渠道，为空时使用所有渠道（MQTT 除外）
+}
+
+// Validate 验证自动恢复配置，通知渠道是否存在由调用方检查
+func (c *Config) Validate() error {
+	if c.Failures < 0 {
+		return fmt.Errorf("recovery.failures 不能小于 0")
+	}
+	if c.Cooldown < 0 {
+		return fmt.Errorf("recovery.cooldown 不能小于 0")
+	}
+	for _, step := range c.Steps {
+		if _, ok := descriptions[step]; !ok {
+			return fmt.Errorf("recovery.steps 不支持的恢复措施: %s，可选 enable、reset、rescan、usb", step)
+		}
+		if step == StepUSB && c.USBDevice == "" {
+			return fmt.Errorf("recovery.steps 包含 usb 时需要配置 recovery.usb_device")
+		}
+	}
+	return nil
+}
+
+// Ladder 自动恢复的状态
+type Ladder struct {
+	cfg      Config
+	deviceID string
+	manager  *modem.Manager
+
+	failures  int       // 连续失败的检查次数
+	next      int       // 下一步恢复措施的序号
+	nextAt    time.Time // 下一步最早的执行时间
+	exhausted bool      // 所有恢复措施都已执行，等待人工处理
+	healthy   bool      // 上一轮检查是否成功，每次恢复正常后记录一次设备标识
+}
+
+// NewLadder 创建自动恢复
+// 参数:
+//   - cfg: 自动恢复配置
+//   - deviceID: 设备标识，显示在通知中
+//   - manager: 要恢复的调制解调器
+func NewLadder(cfg Config, deviceID string, manager *modem.Manager) *Ladder {
+	if cfg.Failures == 0 {
+		cfg.Failures = 3
+	}
+	if len(cfg.Steps) == 0 {
+		cfg.Steps = DefaultSteps
+	}
+	if cfg.Cooldown == 0 {
+		cfg.Cooldown = 120
+	}
+	if deviceID == "" {
+		deviceID = "sim-sms-forward"
+	}
+	return &Ladder{cfg: cfg, deviceID: deviceID, manager: manager}
+}
+
+// Observe 记录一轮检查的结果，需要时执行下一步恢复措施
+// 连续失败达到 failures 次后，先检查调制解调器是否换了ID，没有时在冷却时间已过后执行下一步；
+// 执行过恢复措施后检查成功时报告已恢复
+// 参数:
+//   - err: 本轮检查的错误，成功时为 nil
+//   - now: 检查时间
+//
+// 返回: 需要推送的通知，没有时为 nil
+func (l *Ladder) Observe(err error, now time.Time) *types.SMS {
+	if err == nil {
+		defer l.reset()
+		// 记录设备标识，ID 变化后据此重新找到调制解调器
+		if !l.healthy {
+			l.healthy = true
+			if idErr := l.manager.Identify(); idErr != nil {
+				logger.Errorf("%v", idErr)
+			}
+		}
+		if l.next == 0 {
+			return nil
+		}
+		step := l.cfg.Steps[l.next-1]
+		body := fmt.Sprintf("执行「%s」后调制解调器恢复正常", descriptions[step])
+		logger.Infof("%s", body)
+		return l.message("调制解调器已恢复", body, types.PriorityNormal, now)
+	}
+
+	l.healthy = false
+	l.failures++
+	if l.failures < l.cfg.Failures {
+		return nil
+	}
+	// 重置、重新扫描或重新插拔后调制解调器可能换了ID，换了ID时下一轮用新ID检查
+	if id, findErr := l.manager.Rediscover(); findErr != nil {
+		logger.Errorf("%v", findErr)
+	} else if id != "" {
+		return nil
+	}
+	if l.exhausted || now.Before(l.nextAt) {
+		return nil
+	}
+	if l.next >= len(l.cfg.Steps) {
+		l.exhausted = true
+		body := fmt.Sprintf("已依次尝试 %s，调制解调器仍不可用，需要人工处理\n%v", l.stepNames(), err)
+		logger.Errorf("自动恢复失败: %v", err)
+		return l.message("调制解调器自动恢复失败", body, types.PriorityUrgent, now)
+	}
+
+	step := l.cfg.Steps[l.next]
+	l.next++
+	l.nextAt = now.Add(time.Duration(l.cfg.Cooldown) * time.Second)
+	logger.Infof("调制解调器连续 %d 次检查失败，执行恢复措施: %s", l.failures, descriptions[step])
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "连续 %d 次检查失败: %v\n", l.failures, err)
+	fmt.Fprintf(&body, "第 %d/%d 步: %s（%s）\n", l.next, len(l.cfg.Steps), descriptions[step], l.command(step))
+	if runErr := l.run(step); runErr != nil {
+		logger.Errorf("恢复措施 %s 执行失败: %v", step, runErr)
+		metrics.RecoveryActions.Inc(step, "error")
+		fmt.Fprintf(&body, "执行失败: %v", runErr)
+	} else {
+		metrics.RecoveryActions.Inc(step, "ok")
+		if l.next < len(l.cfg.Steps) {
+			fmt.Fprintf(&body, "已执行，%d 秒后仍不可用时执行下一步", l.cfg.Cooldown)
+		} else {
+			fmt.Fprintf(&body, "已执行，这是最后一步")
+		}
+	}
+	return l.message("尝试恢复调制解调器: "+descriptions[step], body.String(), types.PriorityHigh, now)
+}
+
+// reset 调制解调器恢复后重新开始计数
+func (l *Ladder) reset() {
+	l.failures = 0
+	l.next = 0
+	l.nextAt = time.Time{}
+	l.exhausted = false
+}
+
+// run 执行一步恢复措施
+func (l *Ladder) run(step string) error {
+	switch step {
+	case StepEnable:
+		return l.manager.Enable()
+	case StepReset:
+		return l.manager.Reset()
+	case StepRescan:
+		return modem.ScanModems()
+	case StepUSB:
+		return PowerCycleUSB(l.cfg.USBDevice)
+	}
+	return fmt.Errorf("不支持的恢复措施: %s", step)
+}
+
+// command 返回恢复措施实际执行的命令，显示在通知中
+func (l *Ladder) command(step string) string {
+	switch step {
+	case StepEnable:
+		return fmt.Sprintf("mmcli -m %s -e", l.manager.ID())
+	case StepReset:
+		return fmt.Sprintf("mmcli -m %s -r", l.manager.ID())
+	case StepRescan:
+		return "mmcli -S"
+	case StepUSB:
+		return filepath.Join(l.cfg.USBDevice, "authorized")
+	}
+	return step
+}
+
+// stepNames 返回所有恢复措施的说明，用于通知
+func (l *Ladder) stepNames() string {
+	names := make([]string, 0, len(l.cfg.Steps))
+	for _, step := range l.cfg.Steps {
+		names = append(names, descriptions[step])
+	}
+	return strings.Join(names, "、")
+}
+
+// message 生成恢复通知
+func (l *Ladder) message(title, body string, priority types.Priority, now time.Time) *types.SMS {
+	body = fmt.Sprintf("设备: %s\n%s", l.deviceID, body)
+	return &types.SMS{
+		ID:        fmt.Sprintf("recovery-%d", now.Unix()),
+		Sender:    "modem",
+		Timestamp: now.Format(time.RFC3339),
+		Content:   body,
+		ModemID:   l.manager.ID(),
+		Priority:  priority,
+		Title:     title,
+		Body:      body,
+	}
+}
+
+// PowerCycleUSB 通过 sysfs 让 USB 设备断开后重新连接，相当于重新插拔
+// 向 <device>/authorized 写入 0 断开设备，等待几秒后写入 1 重新连接，需要 root 权限
+// 参数: device - sysfs 中的 USB 设备目录，如 /sys/bus/usb/devices/1-1
+// 返回: 写入失败时返回错误
+func PowerCycleUSB(device string) error {
+	path := filepath.Join(device, "authorized")
+	logger.Infof("USB 设备断电重连: %s", device)
+	if err := os.WriteFile(path, []byte("0\n"), 0644); err != nil {
+		return fmt.Errorf("断开 USB 设备失败: %v", err)
+	}
+	time.Sleep(usbOffDuration)
+	if err := os.WriteFile(path, []byte("1\n"), 0644); err != nil {
+		return fmt.Errorf("重新连接 USB 设备失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package recovery
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"sim-sms-forward/pkg/modem"
+)
+
+// fakeMMCLI 模拟 mmcli 的脚本，状态保存在脚本所在目录:
+//   - current: 调制解调器当前的ID，none 表示调制解调器不可用
+//   - fixby: 执行哪个恢复措施后调制解调器以ID 1 重新出现
+//   - calls: 每次调用的参数
+const fakeMMCLI = `#!/bin/sh
+D=$(dirname "$0")
+echo "$*" >> "$D/calls"
+cur=$(cat "$D/current")
+fix=$(cat "$D/fixby" 2>/dev/null)
+case "$*" in
+  *" -e") [ "$fix" = enable ] && echo 1 > "$D/current"; exit 0;;
+  *" -r") [ "$fix" = reset ] && echo 1 > "$D/current"; exit 0;;
+  "-S") [ "$fix" = rescan ] && echo 1 > "$D/current"; exit 0;;
+  "-L") [ "$cur" = none ] || echo "    /org/freedesktop/ModemManager1/Modem/$cur [Quectel] EC25"; exit 0;;
+  "-m $cur -K") echo "modem.generic.equipment-identifier : 860000000000001"; exit 0;;
+esac
+echo "error: couldn't find modem" >&2
+exit 1
+`
+
+// setup 把模拟的 mmcli 放到 PATH 最前面，返回状态目录
+func setup(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "mmcli"), []byte(fakeMMCLI), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write(t, dir, "current", "0")
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dir
+}
+
+func write(t *testing.T, dir, name, value string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(value+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func calls(t *testing.T, dir string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "calls"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+var errDown = errors.New("错误: 未找到ID为 0 的调制解调器")
+
+// TestLadderRediscover 重置后调制解调器换了ID，按设备标识找到新ID后报告已恢复
+func TestLadderRediscover(t *testing.T) {
+	dir := setup(t)
+	manager := modem.NewManager("0")
+	ladder := NewLadder(Config{Enable: true, Failures: 2, Cooldown: 60}, "test", manager)
+	now := time.Now()
+
+	// 调制解调器可用时记录设备标识
+	if notice := ladder.Observe(nil, now); notice != nil {
+		t.Fatalf("没有执行恢复措施时不应通知: %s", notice.Title)
+	}
+
+	write(t, dir, "current", "none")
+	write(t, dir, "fixby", "reset")
+	if notice := ladder.Observe(errDown, now); notice != nil {
+		t.Fatalf("未达到失败次数时不应执行恢复措施: %s", notice.Title)
+	}
+	notice := ladder.Observe(errDown, now)
+	if notice == nil || !strings.Contains(notice.Title, "重新启用") {
+		t.Fatalf("第一步应为重新启用，实际为 %v", notice)
+	}
+	if notice := ladder.Observe(errDown, now.Add(30*time.Second)); notice != nil {
+		t.Fatalf("冷却时间内不应执行下一步: %s", notice.Title)
+	}
+	notice = ladder.Observe(errDown, now.Add(61*time.Second))
+	if notice == nil || !strings.Contains(notice.Title, "重置") {
+		t.Fatalf("第二步应为重置，实际为 %v", notice)
+	}
+
+	// 重置后调制解调器以ID 1 重新出现，下一轮检查仍使用旧ID而失败
+	if notice := ladder.Observe(errDown, now.Add(64*time.Second)); notice != nil {
+		t.Fatalf("找到新ID时不应执行下一步: %s", notice.Title)
+	}
+	if id := manager.ID(); id != "1" {
+		t.Fatalf("调制解调器ID为 %s，期望 1", id)
+	}
+	notice = ladder.Observe(nil, now.Add(67*time.Second))
+	if notice == nil || notice.Title != "调制解调器已恢复" {
+		t.Fatalf("恢复后应通知已恢复，实际为 %v", notice)
+	}
+
+	want := []string{"-m 0 -K", "-L", "-m 0 -e", "-L", "-L", "-m 0 -r", "-L", "-m 1 -K"}
+	if got := calls(t, dir); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("mmcli 调用为 %q，期望 %q", got, want)
+	}
+}
+
+// TestLadderExhausted 所有恢复措施都执行后只通知一次
+func TestLadderExhausted(t *testing.T) {
+	dir := setup(t)
+	write(t, dir, "current", "none")
+	manager := modem.NewManager("0")
+	ladder := NewLadder(Config{Enable: true, Failures: 1, Steps: []string{StepEnable, StepRescan}, Cooldown: 10}, "test", manager)
+	now := time.Now()
+
+	var titles []string
+	for i := 0; i < 6; i++ {
+		if notice := ladder.Observe(errDown, now.Add(time.Duration(i*11)*time.Second)); notice != nil {
+			titles = append(titles, notice.Title)
+		}
+	}
+	want := []string{"尝试恢复调制解调器: 重新启用调制解调器", "尝试恢复调制解调器: 重新扫描调制解调器", "调制解调器自动恢复失败"}
+	if strings.Join(titles, "|") != strings.Join(want, "|") {
+		t.Errorf("通知为 %q，期望 %q", titles, want)
+	}
+	if notice := ladder.Observe(nil, now.Add(time.Minute)); notice == nil || notice.Title != "调制解调器已恢复" {
+		t.Errorf("恢复后应通知已恢复，实际为 %v", notice)
+	}
+}
+
+// TestRediscoverSingleModem 没有记录设备标识时，只有一个调制解调器就采用它
+func TestRediscoverSingleModem(t *testing.T) {
+	dir := setup(t)
+	write(t, dir, "current", "3")
+	manager := modem.NewManager("0")
+	if id, err := manager.Rediscover(); err != nil || id != "3" {
+		t.Fatalf("Rediscover() = %q, %v，期望 3", id, err)
+	}
+
+	auto := modem.NewManager("any")
+	if id, err := auto.Rediscover(); err != nil || id != "" {
+		t.Errorf("ID 为 any 时不应重新查找，Rediscover() = %q, %v", id, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := (&Config{Steps: []string{"reboot"}}).Validate(); err == nil {
+		t.Error("不支持的恢复措施应返回错误")
+	}
+	if err := (&Config{Steps: []string{StepUSB}}).Validate(); err == nil {
+		t.Error("使用 usb 措施而没有配置 usb_device 时应返回错误")
+	}
+	if err := (&Config{Steps: []string{StepEnable, StepUSB}, USBDevice: "/sys/bus/usb/devices/1-1"}).Validate(); err != nil {
+		t.Errorf("有效配置返回错误: %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+// Package redact 提供短信内容脱敏，在转发到不完全可信的通知渠道前隐藏卡号、身份证号、金额等敏感信息
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"sim-sms-forward/pkg/types"
+)
+
+// Mask 替换敏感内容使用的字符
+const Mask = "*"
+
+// Profile 脱敏配置
+type Profile struct {
+	Cards    bool     `json:"cards,omitempty"`    // 银行卡号和账号（12~19位数字），只保留后4位
+	IDCards  bool     `json:"id_cards,omitempty"` // 18位身份证号，只保留前3位和后4位
+	Amounts  bool     `json:"amounts,omitempty"`  // 金额和余额，如 ¥1,234.56、5000.00元
+	Patterns []string `json:"patterns,omitempty"` // 自定义正则表达式，有分组时只替换分组内容，否则替换整个匹配
+}
+
+var (
+	// numberRunRegex 匹配每4位用空格或短横线分隔的卡号，或一段连续数字（身份证号可以以 X 结尾，国际格式的电话号码以 + 开头），是否脱敏在替换时判断
+	numberRunRegex = regexp.MustCompile(`\d{4}(?:[ -]\d{4}){2,3}(?:[ -]\d{1,4})?|\+?\d+[Xx]?`)
+	// idCardRegex 18位身份证号
+	idCardRegex = regexp.MustCompile(`^[1-9]\d{5}(?:18|19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]$`)
+	// amountRegex 匹配带货币符号或单位的金额
+	amountRegex = regexp.MustCompile(`(?:[¥￥]|RMB|CNY|人民币)\s*\d[\d,]*(?:\.\d+)?|\d[\d,]*(?:\.\d+)?\s*(?:元|块)`)
+	// numberRegex 金额中的数字部分
+	numberRegex = regexp.MustCompile(`\d[\d,]*(?:\.\d+)?`)
+)
+
+// Redactor 编译后的脱敏配置，可以并发使用
+type Redactor struct {
+	profile  Profile
+	patterns []*regexp.Regexp
+}
+
+// Compile 编译脱敏配置
+// 参数: profile - 脱敏配置
+// 返回: 脱敏器和可能的正则表达式错误
+func Compile(profile Profile) (*Redactor, error) {
+	r := &Redactor{profile: profile}
+	for _, pattern := range profile.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redact.patterns: 正则表达式 %q 无效: %v", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	if !profile.Cards && !profile.IDCards && !profile.Amounts && len(r.patterns) == 0 {
+		return nil, fmt.Errorf("redact 至少需要启用 cards、id_cards、amounts 或配置 patterns 之一")
+	}
+	return r, nil
+}
+
+// String 对文本脱敏
+// 先处理自定义正则和金额，再处理身份证号和卡号
+func (r *Redactor) String(s string) string {
+	for _, re := range r.patterns {
+		s = maskPattern(re, s)
+	}
+	if r.profile.Amounts {
+		s = amountRegex.ReplaceAllStringFunc(s, func(match string) string {
+			return numberRegex.ReplaceAllString(match, Mask+Mask+Mask)
+		})
+	}
+	if r.profile.Cards || r.profile.IDCards {
+		s = numberRunRegex.ReplaceAllStringFunc(s, r.maskNumber)
+	}
+	return s
+}
+
+// SMS 返回脱敏后的短信副本，正文、规则渲染的标题和正文都会脱敏，原短信不会被修改
+func (r *Redactor) SMS(sms *types.SMS) *types.SMS {
+	redacted := *sms
+	redacted.Content = r.String(sms.Content)
+	redacted.Title = r.String(sms.Title)
+	redacted.Body = r.String(sms.Body)
+	return &redacted
+}
+
+// maskNumber 对一段数字脱敏: 身份证号保留前3位和后4位，12~19位的卡号保留后4位，其他数字原样返回
+// +86 开头的手机号有13位，以 + 开头的号码都不是卡号
+func (r *Redactor) maskNumber(match string) string {
+	if strings.HasPrefix(match, "+") {
+		return match
+	}
+	if r.profile.IDCards && idCardRegex.MatchString(match) {
+		return match[:3] + strings.Repeat(Mask, len(match)-7) + match[len(match)-4:]
+	}
+	if !r.profile.Cards {
+		return match
+	}
+	digits := 0
+	for _, c := range match {
+		switch {
+		case c >= '0' && c <= '9':
+			digits++
+		case c == 'X' || c == 'x':
+			return match
+		}
+	}
+	if digits < 12 || digits > 19 {
+		return match
+	}
+
+	var b strings.Builder
+	seen := 0
+	for _, c := range match {
+		if c >= '0' && c <= '9' {
+			seen++
+			if seen <= digits-4 {
+				b.WriteString(Mask)
+				continue
+			}
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// maskPattern 替换自定义正则的匹配内容，正则有分组时只替换分组内容
+func maskPattern(re *regexp.Regexp, s string) string {
+	if re.NumSubexp() == 0 {
+		return re.ReplaceAllString(s, Mask+Mask+Mask)
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringSubmatchIndex(s, -1) {
+		for i := 2; i < len(loc); i += 2 {
+			start, end := loc[i], loc[i+1]
+			// 未参与匹配或嵌套在已替换分组中的分组跳过
+			if start < 0 || start < last {
+				continue
+			}
+			b.WriteString(s[last:start])
+			b.WriteString(Mask + Mask + Mask)
+			last = end
+		}
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
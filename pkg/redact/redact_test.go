@@ -0,0 +1,109 @@
+package redact
+
+import (
+	"testing"
+
+	"sim-sms-forward/pkg/types"
+)
+
+// TestString 卡号、身份证号和金额按规则脱敏，手机号、日期和验证码不受影响
+func TestString(t *testing.T) {
+	all := Profile{Cards: true, IDCards: true, Amounts: true}
+	tests := []struct {
+		name    string
+		profile Profile
+		in      string
+		want    string
+	}{
+		{"12位账号", all, "账号123456789012入账", "账号********9012入账"},
+		{"16位卡号", all, "卡号6222021234567890", "卡号************7890"},
+		{"19位卡号", all, "您尾号的卡6222021234567890123支出", "您尾号的卡***************0123支出"},
+		{"空格分组的卡号", all, "卡号 6222 0212 3456 7890 已绑定", "卡号 **** **** **** 7890 已绑定"},
+		{"短横线分组的19位卡号", all, "6222-0212-3456-7890-123", "****-****-****-***0-123"},
+		{"11位数字不是卡号", all, "账号12345678901", "账号12345678901"},
+		{"20位数字不是卡号", all, "流水号12345678901234567890", "流水号12345678901234567890"},
+		{"身份证号", all, "身份证11010519491231002X已认证", "身份证110***********002X已认证"},
+		{"小写x结尾的身份证号", all, "11010519491231002x", "110***********002x"},
+		{"全数字身份证号", all, "110105194912310021", "110***********0021"},
+		{"未启用 id_cards 时带X的身份证号不按卡号处理", Profile{Cards: true}, "11010519491231002X", "11010519491231002X"},
+		{"人民币符号", all, "消费¥1,234.56", "消费¥***"},
+		{"全角人民币符号", all, "消费￥88", "消费￥***"},
+		{"元", all, "余额5000.00元", "余额***元"},
+		{"前缀和单位", all, "人民币1,234.56元", "人民币***元"},
+		{"多个金额", all, "支出12.30元，余额5000.00元", "支出***元，余额***元"},
+		{"未启用 amounts", Profile{Cards: true}, "余额5000.00元", "余额5000.00元"},
+		{"未启用 cards", Profile{Amounts: true}, "卡号6222021234567890", "卡号6222021234567890"},
+		{"手机号", all, "请致电13800138000", "请致电13800138000"},
+		{"带空格的手机号", all, "请致电 138 0013 8000", "请致电 138 0013 8000"},
+		{"带区号的手机号", all, "+8613800138000", "+8613800138000"},
+		{"日期", all, "2026-10-18 09:30 到账", "2026-10-18 09:30 到账"},
+		{"连续数字的日期", all, "账单日20261018", "账单日20261018"},
+		{"中文日期", all, "2026年10月18日", "2026年10月18日"},
+		{"验证码", all, "验证码 123456，5分钟内有效", "验证码 123456，5分钟内有效"},
+	}
+	for _, tt := range tests {
+		r, err := Compile(tt.profile)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := r.String(tt.in); got != tt.want {
+			t.Errorf("%s: %q 脱敏为 %q，期望 %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestPatterns 自定义正则有分组时只替换分组内容，否则替换整个匹配
+func TestPatterns(t *testing.T) {
+	tests := []struct {
+		pattern string
+		in      string
+		want    string
+	}{
+		{`机密`, "这是机密文件", "这是***文件"},
+		{`订单号(\d+)`, "订单号12345已发货，订单号678已签收", "订单号***已发货，订单号***已签收"},
+		{`收件人:(\S+) 电话:(\d+)`, "收件人:张三 电话:13800138000", "收件人:*** 电话:***"},
+		{`卡号((\d{4})\d+)`, "卡号62220212", "卡号***"},
+		{`取件码(?:是)?(\d+)?`, "取件码是，取件码1234", "取件码是，取件码***"},
+	}
+	for _, tt := range tests {
+		r, err := Compile(Profile{Patterns: []string{tt.pattern}})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.pattern, err)
+		}
+		if got := r.String(tt.in); got != tt.want {
+			t.Errorf("%s: %q 脱敏为 %q，期望 %q", tt.pattern, tt.in, got, tt.want)
+		}
+	}
+
+	// 自定义正则先于内置规则执行
+	r, err := Compile(Profile{Cards: true, Patterns: []string{`尾号(\d{4})`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.String("尾号1234的卡6222021234567890"); got != "尾号***的卡************7890" {
+		t.Errorf("脱敏结果为 %q", got)
+	}
+
+	if _, err := Compile(Profile{Patterns: []string{`(`}}); err == nil {
+		t.Error("无效的正则表达式应返回错误")
+	}
+	if _, err := Compile(Profile{}); err == nil {
+		t.Error("没有启用任何规则时应返回错误")
+	}
+}
+
+// TestSMS 正文、标题和规则正文都脱敏，验证码和原短信不受影响
+func TestSMS(t *testing.T) {
+	r, err := Compile(Profile{Cards: true, Amounts: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sms := &types.SMS{Content: "卡6222021234567890支出12.30元，验证码 123456", Code: "123456", Title: "支出12.30元", Body: "卡6222021234567890"}
+	got := r.SMS(sms)
+	if got.Content != "卡************7890支出***元，验证码 123456" || got.Title != "支出***元" || got.Body != "卡************7890" {
+		t.Errorf("脱敏后的短信为 %+v", got)
+	}
+	if got.Code != "123456" || sms.Content != "卡6222021234567890支出12.30元，验证码 123456" {
+		t.Errorf("验证码或原短信被修改: %+v %+v", got, sms)
+	}
+}
@@ -0,0 +1,302 @@
+// Package rules 提供短信路由规则引擎
+// 规则按配置顺序依次匹配，命中的规则可以指定通知渠道、覆盖模板和优先级、丢弃短信或停止匹配后续规则
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"sim-sms-forward/pkg/notification"
+	"sim-sms-forward/pkg/types"
+)
+
+// Rule 单条路由规则的配置
+type Rule struct {
+	Name   string `json:"name"`   // 规则名称，用于日志和 rules test 输出
+	Match  Match  `json:"match"`  // 匹配条件，所有已配置的条件都满足时规则命中
+	Action Action `json:"action"` // 命中后执行的动作
+}
+
+// Match 规则的匹配条件，未配置的条件视为满足
+type Match struct {
+	Sender       string   `json:"sender,omitempty"`        // 发送方号码完全相等
+	SenderPrefix string   `json:"sender_prefix,omitempty"` // 发送方号码前缀，如 "106"
+	SenderRegex  string   `json:"sender_regex,omitempty"`  // 发送方号码正则表达式
+	ContentRegex string   `json:"content_regex,omitempty"` // 短信内容正则表达式
+	Keywords     []string `json:"keywords,omitempty"`      // 短信内容包含任意一个关键字
+	ModemID      string   `json:"modem,omitempty"`         // 调制解调器ID
+	SIM          string   `json:"sim,omitempty"`           // SIM 卡 ICCID，支持前缀匹配
+	Time         string   `json:"time,omitempty"`          // 时间段，格式 "HH:MM-HH:MM"，支持跨零点如 "22:00-07:00"
+	HasOTP       *bool    `json:"has_otp,omitempty"`       // 是否识别到验证码
+}
+
+// Action 规则命中后执行的动作
+type Action struct {
+	Notifiers []string `json:"notifiers,omitempty"` // 只发送到这些通知渠道（按名称），多条规则命中时取并集
+	Title     string   `json:"title,omitempty"`     // 覆盖通知标题的模板
+	Body      string   `json:"body,omitempty"`      // 覆盖通知正文的模板
+	Priority  string   `json:"priority,omitempty"`  // 覆盖优先级: low、normal、high、urgent
+	Drop      bool     `json:"drop,omitempty"`      // 丢弃短信，不发送任何通知（仍从调制解调器删除）
+	Stop      bool     `json:"stop,omitempty"`      // 停止匹配后续规则
+}
+
+// Decision 所有命中规则合并后的路由结果
+type Decision struct {
+	Notifiers []string           // 指定的通知渠道，为空表示发送到全部渠道
+	Title     *template.Template // 标题模板，为 nil 表示使用默认标题
+	Body      *template.Template // 正文模板，为 nil 表示使用默认正文
+	Priority  *types.Priority    // 覆盖后的优先级，为 nil 表示不覆盖
+	Drop      bool               // 是否丢弃
+	Matched   []string           // 命中的规则名称
+}
+
+// Trace 单条规则的匹配过程，用于 rules test 输出
+type Trace struct {
+	Rule    string // 规则名称
+	Matched bool   // 是否命中
+	Reason  string // 未命中时为第一个不满足的条件，命中时为空
+	Stopped bool   // 是否因该规则的 stop 停止了后续匹配
+}
+
+// compiledRule 预编译后的规则
+type compiledRule struct {
+	rule         Rule
+	senderRegex  *regexp.Regexp
+	contentRegex *regexp.Regexp
+	timeFrom     int // 时间段起点（当天的分钟数），-1 表示未配置
+	timeTo       int // 时间段终点（当天的分钟数）
+	title        *template.Template
+	body         *template.Template
+	priority     *types.Priority
+}
+
+// Engine 路由规则引擎
+type Engine struct {
+	rules []compiledRule
+}
+
+// Compile 预编译规则中的正则表达式、时间段和模板
+// 参数: rules - 按优先顺序排列的规则列表
+// 返回: 规则引擎和可能的错误，错误信息中包含出错规则的序号和名称
+func Compile(rules []Rule) (*Engine, error) {
+	engine := &Engine{}
+	for i, rule := range rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		cr, err := compileRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("rules[%d] (%s): %v", i, rule.Name, err)
+		}
+		engine.rules = append(engine.rules, cr)
+	}
+	return engine, nil
+}
+
+// compileRule 编译单条规则
+func compileRule(rule Rule) (compiledRule, error) {
+	cr := compiledRule{rule: rule, timeFrom: -1}
+	var err error
+
+	if rule.Match.SenderRegex != "" {
+		if cr.senderRegex, err = regexp.Compile(rule.Match.SenderRegex); err != nil {
+			return cr, fmt.Errorf("sender_regex 无效: %v", err)
+		}
+	}
+	if rule.Match.ContentRegex != "" {
+		if cr.contentRegex, err = regexp.Compile(rule.Match.ContentRegex); err != nil {
+			return cr, fmt.Errorf("content_regex 无效: %v", err)
+		}
+	}
+	if rule.Match.Time != "" {
+		if cr.timeFrom, cr.timeTo, err = ParseTimeRange(rule.Match.Time); err != nil {
+			return cr, err
+		}
+	}
+	if rule.Action.Title != "" {
+		if cr.title, err = notification.ParseTemplate(rule.Name+".title", rule.Action.Title); err != nil {
+			return cr, err
+		}
+	}
+	if rule.Action.Body != "" {
+		if cr.body, err = notification.ParseTemplate(rule.Name+".body", rule.Action.Body); err != nil {
+			return cr, err
+		}
+	}
+	if rule.Action.Priority != "" {
+		p, err := types.ParsePriority(rule.Action.Priority)
+		if err != nil {
+			return cr, err
+		}
+		cr.priority = &p
+	}
+	return cr, nil
+}
+
+// ParseTimeRange 解析 "HH:MM-HH:MM" 格式的时间段
+// 返回: 起点和终点（当天的分钟数）以及可能的错误
+func ParseTimeRange(value string) (int, int, error) {
+	parts := strings.Split(value, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("时间段 %s 格式无效，应为 HH:MM-HH:MM", value)
+	}
+	from, err := ParseClock(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("时间段 %s 起点无效: %v", value, err)
+	}
+	to, err := ParseClock(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("时间段 %s 终点无效: %v", value, err)
+	}
+	return from, to, nil
+}
+
+// ParseClock 解析 "HH:MM" 为当天的分钟数
+func ParseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// InTimeRange 判断时间是否落在时间段内，终点早于起点时表示跨零点
+// 参数:
+//   - from, to: 起点和终点（当天的分钟数），起点包含、终点不包含
+//   - now: 要判断的时间
+func InTimeRange(from, to int, now time.Time) bool {
+	minute := now.Hour()*60 + now.Minute()
+	if from <= to {
+		return minute >= from && minute < to
+	}
+	return minute >= from || minute < to
+}
+
+// NotifierNames 返回规则中引用的所有通知渠道名称，用于配置校验
+func (e *Engine) NotifierNames() []string {
+	var names []string
+	for _, cr := range e.rules {
+		names = append(names, cr.rule.Action.Notifiers...)
+	}
+	return names
+}
+
+// Evaluate 对短信依次匹配所有规则，合并命中规则的动作
+// 参数:
+//   - sms: 已提取元数据的短信
+//   - now: 当前时间，用于时间段匹配
+//
+// 返回: 合并后的路由结果和每条规则的匹配过程
+func (e *Engine) Evaluate(sms *types.SMS, now time.Time) (*Decision, []Trace) {
+	decision := &Decision{}
+	var traces []Trace
+	seen := make(map[string]bool)
+
+	for _, cr := range e.rules {
+		reason := cr.mismatch(sms, now)
+		trace := Trace{Rule: cr.rule.Name, Matched: reason == "", Reason: reason}
+		if reason != "" {
+			traces = append(traces, trace)
+			continue
+		}
+
+		decision.Matched = append(decision.Matched, cr.rule.Name)
+		for _, name := range cr.rule.Action.Notifiers {
+			if !seen[name] {
+				seen[name] = true
+				decision.Notifiers = append(decision.Notifiers, name)
+			}
+		}
+		if cr.title != nil {
+			decision.Title = cr.title
+		}
+		if cr.body != nil {
+			decision.Body = cr.body
+		}
+		if cr.priority != nil {
+			decision.Priority = cr.priority
+		}
+		if cr.rule.Action.Drop {
+			decision.Drop = true
+		}
+
+		trace.Stopped = cr.rule.Action.Stop
+		traces = append(traces, trace)
+		if cr.rule.Action.Stop {
+			break
+		}
+	}
+	return decision, traces
+}
+
+// Apply 将路由结果中的优先级和模板应用到短信上
+// 参数: sms - 要修改的短信
+// 返回: 模板渲染失败时返回错误
+func (d *Decision) Apply(sms *types.SMS) error {
+	if d.Priority != nil {
+		sms.Priority = *d.Priority
+	}
+	if d.Title != nil {
+		title, err := notification.RenderTemplate(d.Title, sms)
+		if err != nil {
+			return err
+		}
+		sms.Title = title
+	}
+	if d.Body != nil {
+		body, err := notification.RenderTemplate(d.Body, sms)
+		if err != nil {
+			return err
+		}
+		sms.Body = body
+	}
+	return nil
+}
+
+// mismatch 检查规则的匹配条件，返回第一个不满足的条件描述，全部满足时返回空字符串
+func (cr *compiledRule) mismatch(sms *types.SMS, now time.Time) string {
+	m := cr.rule.Match
+	if m.Sender != "" && sms.Sender != m.Sender {
+		return fmt.Sprintf("发送方 %s 不等于 %s", sms.Sender, m.Sender)
+	}
+	if m.SenderPrefix != "" && !strings.HasPrefix(sms.Sender, m.SenderPrefix) {
+		return fmt.Sprintf("发送方 %s 不以 %s 开头", sms.Sender, m.SenderPrefix)
+	}
+	if cr.senderRegex != nil && !cr.senderRegex.MatchString(sms.Sender) {
+		return fmt.Sprintf("发送方 %s 不匹配 %s", sms.Sender, m.SenderRegex)
+	}
+	if cr.contentRegex != nil && !cr.contentRegex.MatchString(sms.Content) {
+		return fmt.Sprintf("内容不匹配 %s", m.ContentRegex)
+	}
+	if len(m.Keywords) > 0 {
+		found := false
+		for _, keyword := range m.Keywords {
+			if strings.Contains(sms.Content, keyword) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Sprintf("内容不包含关键字 %s", strings.Join(m.Keywords, "/"))
+		}
+	}
+	if m.ModemID != "" && sms.ModemID != m.ModemID {
+		return fmt.Sprintf("调制解调器 %s 不等于 %s", sms.ModemID, m.ModemID)
+	}
+	if m.SIM != "" && !strings.HasPrefix(sms.SIM, m.SIM) {
+		return fmt.Sprintf("SIM 卡 %s 不匹配 %s", sms.SIM, m.SIM)
+	}
+	if cr.timeFrom >= 0 && !InTimeRange(cr.timeFrom, cr.timeTo, now) {
+		return fmt.Sprintf("时间 %s 不在 %s 内", now.Format("15:04"), m.Time)
+	}
+	if m.HasOTP != nil && (sms.Code != "") != *m.HasOTP {
+		if *m.HasOTP {
+			return "未识别到验证码"
+		}
+		return "识别到了验证码"
+	}
+	return ""
+}
@@ -0,0 +1,284 @@
+// Package spam 提供垃圾短信和营销短信过滤功能
+package spam
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"sim-sms-forward/pkg/vault"
+)
+
+// Model 朴素贝叶斯分类器的模型，以 JSON 格式保存在数据目录中
+// 每条训练短信的词只计一次，使用拉普拉斯平滑
+type Model struct {
+	SpamDocs   int            `json:"spam_docs"`   // 标记为垃圾短信的训练样本数
+	HamDocs    int            `json:"ham_docs"`    // 标记为正常短信的训练样本数
+	SpamTokens map[string]int `json:"spam_tokens"` // 词在垃圾短信中出现的次数
+	HamTokens  map[string]int `json:"ham_tokens"`  // 词在正常短信中出现的次数
+	SpamTotal  int            `json:"spam_total"`  // 垃圾短信的总词数
+	HamTotal   int            `json:"ham_total"`   // 正常短信的总词数
+}
+
+// newModel 创建空模型
+func newModel() *Model {
+	return &Model{
+		SpamTokens: make(map[string]int),
+		HamTokens:  make(map[string]int),
+	}
+}
+
+// clone 复制模型，训练时修改副本，正在分类的读取方仍使用旧模型
+func (m *Model) clone() *Model {
+	c := *m
+	c.SpamTokens = make(map[string]int, len(m.SpamTokens))
+	for t, n := range m.SpamTokens {
+		c.SpamTokens[t] = n
+	}
+	c.HamTokens = make(map[string]int, len(m.HamTokens))
+	for t, n := range m.HamTokens {
+		c.HamTokens[t] = n
+	}
+	return &c
+}
+
+// Train 用一条短信训练模型
+// 参数:
+//   - content: 短信内容
+//   - spam: true 表示垃圾短信，false 表示正常短信
+func (m *Model) Train(content string, spam bool) {
+	tokens := Tokenize(content)
+	if spam {
+		m.SpamDocs++
+		m.SpamTotal += len(tokens)
+		for _, t := range tokens {
+			m.SpamTokens[t]++
+		}
+	} else {
+		m.HamDocs++
+		m.HamTotal += len(tokens)
+		for _, t := range tokens {
+			m.HamTokens[t]++
+		}
+	}
+}
+
+// Ready 判断两类样本是否都达到最少数量，样本太少时分类结果没有参考价值
+func (m *Model) Ready(minDocs int) bool {
+	return m.SpamDocs >= minDocs && m.HamDocs >= minDocs
+}
+
+// SpamProbability 计算短信是垃圾短信的概率
+// 返回: 0 到 1 之间的概率，模型为空时返回 0.5
+func (m *Model) SpamProbability(content string) float64 {
+	if m.SpamDocs == 0 || m.HamDocs == 0 {
+		return 0.5
+	}
+
+	vocabulary := len(m.SpamTokens)
+	for t := range m.HamTokens {
+		if _, ok := m.SpamTokens[t]; !ok {
+			vocabulary++
+		}
+	}
+
+	total := float64(m.SpamDocs + m.HamDocs)
+	logSpam := math.Log(float64(m.SpamDocs) / total)
+	logHam := math.Log(float64(m.HamDocs) / total)
+	for _, t := range Tokenize(content) {
+		logSpam += math.Log(float64(m.SpamTokens[t]+1) / float64(m.SpamTotal+vocabulary))
+		logHam += math.Log(float64(m.HamTokens[t]+1) / float64(m.HamTotal+vocabulary))
+	}
+	return 1 / (1 + math.Exp(logHam-logSpam))
+}
+
+// urlPattern 匹配短信中的网址
+var urlPattern = regexp.MustCompile(`(?i)(?:https?://)?(?:[a-z0-9-]+\.)+[a-z]{2,}(?:/[^\s\p{Han}]*)?`)
+
+// Tokenize 将短信内容切分为去重后的词
+// 中文按相邻两个字切分（单字时保留单字），英文按单词切分，数字统一为 <num>，网址统一为 <url>
+func Tokenize(content string) []string {
+	content = urlPattern.ReplaceAllString(strings.ToLower(content), " <url> ")
+
+	seen := make(map[string]bool)
+	var tokens []string
+	add := func(t string) {
+		if !seen[t] {
+			seen[t] = true
+			tokens = append(tokens, t)
+		}
+	}
+
+	var han []rune
+	var word []rune
+	flushHan := func() {
+		if len(han) == 1 {
+			add(string(han))
+		}
+		for i := 0; i+1 < len(han); i++ {
+			add(string(han[i : i+2]))
+		}
+		han = han[:0]
+	}
+	flushWord := func() {
+		if len(word) == 0 {
+			return
+		}
+		w := string(word)
+		if strings.Trim(w, "0123456789") == "" {
+			w = "<num>"
+		}
+		add(w)
+		word = word[:0]
+	}
+
+	for _, r := range content {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '<' || r == '>'):
+			flushHan()
+			word = append(word, r)
+		default:
+			flushHan()
+			flushWord()
+		}
+	}
+	flushHan()
+	flushWord()
+	return tokens
+}
+
+// ModelStore 管理保存在文件中的模型
+// 训练命令和转发服务可能是不同的进程，读取时会检查文件修改时间并自动重新加载
+// 模型的词频来自短信内容，配置了加密时整个模型作为一条记录加密保存
+// Model 返回的模型不会再被修改，训练和重新加载都生成新的模型再替换，分类时不需要持有锁
+type ModelStore struct {
+	path    string
+	key     *vault.Key
+	mu      sync.Mutex
+	model   *Model
+	modTime time.Time
+}
+
+// NewModelStore 创建模型存储
+// 参数:
+//   - path: 模型文件路径，文件不存在时使用空模型
+//   - key: 加密密钥，为 nil 时不加密
+func NewModelStore(path string, key *vault.Key) *ModelStore {
+	return &ModelStore{path: path, key: key, model: newModel()}
+}
+
+// Model 返回最新的模型，文件在其他进程中被更新时重新加载
+func (s *ModelStore) Model() (*Model, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s.model, nil
+}
+
+// Train 用一条短信训练模型并立即保存
+// 参数:
+//   - content: 短信内容
+//   - spam: true 表示垃圾短信，false 表示正常短信
+func (s *ModelStore) Train(content string, spam bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return err
+	}
+	model := s.model.clone()
+	model.Train(content, spam)
+	if err := s.save(model); err != nil {
+		return err
+	}
+	s.model = model
+	return nil
+}
+
+// Rekey 用新密钥重新保存模型，newKey 为 nil 时保存为明文，模型文件不存在时只切换密钥
+func (s *ModelStore) Rekey(newKey *vault.Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return err
+	}
+	previous := s.key
+	s.key = newKey
+	if _, err := os.Stat(s.path); os.IsNotExist(err) {
+		return nil
+	}
+	if err := s.save(s.model); err != nil {
+		s.key = previous
+		return err
+	}
+	return nil
+}
+
+// reload 文件修改时间变化时重新读取模型，调用方需持有锁
+func (s *ModelStore) reload() error {
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取垃圾短信模型失败: %v", err)
+	}
+	if info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("读取垃圾短信模型失败: %v", err)
+	}
+	if data, err = vault.Decode(s.key, data); err != nil {
+		return fmt.Errorf("垃圾短信模型 %s: %v", s.path, err)
+	}
+	model := newModel()
+	if err := json.Unmarshal(data, model); err != nil {
+		return fmt.Errorf("解析垃圾短信模型失败: %v", err)
+	}
+	if model.SpamTokens == nil {
+		model.SpamTokens = make(map[string]int)
+	}
+	if model.HamTokens == nil {
+		model.HamTokens = make(map[string]int)
+	}
+	s.model = model
+	s.modTime = info.ModTime()
+	return nil
+}
+
+// save 先写入临时文件再重命名，避免其他进程读到写了一半的模型，调用方需持有锁
+func (s *ModelStore) save(model *Model) error {
+	data, err := json.Marshal(model)
+	if err != nil {
+		return fmt.Errorf("序列化垃圾短信模型失败: %v", err)
+	}
+	data = vault.Encode(s.key, data)
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("创建数据目录失败: %v", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("写入垃圾短信模型失败: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("写入垃圾短信模型失败: %v", err)
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package spam
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"sim-sms-forward/pkg/types"
+	"sim-sms-forward/pkg/vault"
+)
+
+// TestTrainWhileChecking MQTT 标记短信训练模型的同时处理循环在分类，需要用 -race 运行
+func TestTrainWhileChecking(t *testing.T) {
+	filter := NewFilter(Config{Enable: true, MinTraining: 1, DisableHeuristics: true}, t.TempDir(), nil)
+	if err := filter.Train("会员日全场低至五折，点击领取红包", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := filter.Train("您的快递已到小区门口驿站", false); err != nil {
+		t.Fatal(err)
+	}
+	before, err := filter.Model()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			if err := filter.Train(fmt.Sprintf("限时秒杀第 %d 场，新词%c%c", i, 'A'+rune(i%26), 'a'+rune(i%26)), i%2 == 0); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			filter.Check(&types.SMS{Sender: "10086", Content: "会员日限时秒杀，快递到了"})
+		}
+	}()
+	wg.Wait()
+
+	// 已经取得的模型不会被之后的训练修改
+	if before.SpamDocs != 1 || before.HamDocs != 1 {
+		t.Errorf("训练修改了之前返回的模型: 垃圾 %d，正常 %d", before.SpamDocs, before.HamDocs)
+	}
+	after, err := filter.Model()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.SpamDocs != 51 || after.HamDocs != 51 {
+		t.Errorf("训练后样本数为 垃圾 %d，正常 %d，期望各 51", after.SpamDocs, after.HamDocs)
+	}
+}
+
+// TestModelEncrypted 配置了加密时模型文件中没有明文词频，重新加密为明文后仍然可以读取
+func TestModelEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	key, err := vault.New(vault.Config{Passphrase: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := NewFilter(Config{Enable: true}, dir, key)
+	if err := filter.Train("会员日全场低至五折", true); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "spam_model.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !vault.IsSealed(data) || bytes.Contains(data, []byte("会员")) {
+		t.Errorf("模型应加密保存: %s", data)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("模型文件权限应为 0600: %v %v", info.Mode(), err)
+	}
+	if _, err := NewFilter(Config{Enable: true}, dir, nil).Model(); err == nil {
+		t.Error("没有密钥时读取加密的模型应返回错误")
+	}
+
+	if err := NewFilter(Config{Enable: true}, dir, key).Rekey(nil); err != nil {
+		t.Fatalf("解密模型失败: %v", err)
+	}
+	model, err := NewFilter(Config{Enable: true}, dir, nil).Model()
+	if err != nil || model.SpamDocs != 1 {
+		t.Errorf("解密后的模型不正确: %+v, %v", model, err)
+	}
+}
@@ -0,0 +1,397 @@
+// Package spam 提供垃圾短信和营销短信过滤功能
+package spam
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"sim-sms-forward/pkg/types"
+	"sim-sms-forward/pkg/vault"
+)
+
+// 过滤后的处理方式
+const (
+	ActionSilent = "silent" // 以低优先级静默推送
+	ActionDrop   = "drop"   // 不推送
+)
+
+// Config 垃圾短信过滤的配置
+type Config struct {
+	Enable            bool     `json:"enable"`                       // 是否启用过滤
+	Action            string   `json:"action,omitempty"`             // 判定为垃圾短信后的处理方式: silent、drop，默认 silent
+	Blocklist         []string `json:"blocklist,omitempty"`          // 发送方黑名单，以 * 结尾表示前缀匹配，如 "10690*"
+	BlockKeywords     []string `json:"block_keywords,omitempty"`     // 内容包含任意一个关键字即判定为垃圾短信
+	Allowlist         []string `json:"allowlist,omitempty"`          // 发送方白名单，优先于其他所有规则
+	DisableHeuristics bool     `json:"disable_heuristics,omitempty"` // 是否关闭内置的营销短信特征识别
+	Threshold         float64  `json:"threshold,omitempty"`          // 贝叶斯分类的垃圾短信概率阈值，默认 0.9
+	MinTraining       int      `json:"min_training,omitempty"`       // 两类训练样本都达到该数量后才启用贝叶斯分类，默认 5
+}
+
+// Validate 验证垃圾短信过滤配置
+func (c *Config) Validate() error {
+	switch c.Action {
+	case "", ActionSilent, ActionDrop:
+	default:
+		return fmt.Errorf("spam.action 无效: %s，可选 silent、drop", c.Action)
+	}
+	if c.Threshold < 0 || c.Threshold >= 1 {
+		return fmt.Errorf("spam.threshold 必须在 0 到 1 之间")
+	}
+	return nil
+}
+
+// Verdict 过滤结果
+type Verdict struct {
+	Spam        bool     `json:"spam"`        // 是否为垃圾短信
+	Probability float64  `json:"probability"` // 贝叶斯分类给出的垃圾短信概率，未启用时为 -1
+	Reasons     []string `json:"reasons"`     // 判定依据
+}
+
+// 营销短信特征
+var (
+	// unsubscribeKeywords 运营商要求营销短信附带的退订说明
+	unsubscribeKeywords = []string{"退订", "回T", "回TD", "回N", "拒收请回复R", "拒收回R", "回复TD"}
+
+	// shortenerDomains 营销短信常用的短链接域名
+	shortenerDomains = []string{"t.cn", "dwz.cn", "url.cn", "suo.im", "3.cn", "u.jd.com", "c.tb.cn", "m.tb.cn", "bit.ly", "tinyurl.com", "s.click.taobao.com", "dwz.win", "sourl.cn", "jd.cn.hn"}
+
+	// marketingKeywords 常见的促销用语
+	marketingKeywords = []string{"优惠", "促销", "特惠", "限时", "领取", "红包", "抽奖", "福利", "会员日", "低至", "折起", "满减", "秒杀", "大促", "爆款", "免费领"}
+
+	// marketingSenderPrefixes 营销短信常用的 106 短信通道号码
+	marketingSenderPrefixes = []string{"106", "+86106", "86106"}
+)
+
+// Filter 垃圾短信过滤器
+// 依次检查白名单、黑名单、营销短信特征和贝叶斯分类
+type Filter struct {
+	cfg        Config
+	models     *ModelStore
+	quarantine string
+	key        *vault.Key // 被过滤短信归档的加密密钥，未配置加密时为 nil
+	mu         sync.Mutex
+
+	// archived 已归档短信的标识，第一次归档时从文件读取，为 nil 时还没有读取
+	// 短信投递失败后留在调制解调器上，下一轮会再次被判定为垃圾短信，已归档的不再重复追加
+	archived map[string]bool
+}
+
+// NewFilter 创建垃圾短信过滤器
+// 参数:
+//   - cfg: 过滤配置
+//   - dataDir: 数据目录，模型保存为 spam_model.json，被过滤的短信归档到 spam.jsonl
+//   - key: 模型和被过滤短信归档的加密密钥，未配置加密时为 nil
+//
+// 返回: 初始化好的 Filter 指针
+func NewFilter(cfg Config, dataDir string, key *vault.Key) *Filter {
+	if cfg.Action == "" {
+		cfg.Action = ActionSilent
+	}
+	if cfg.Threshold == 0 {
+		cfg.Threshold = 0.9
+	}
+	if cfg.MinTraining <= 0 {
+		cfg.MinTraining = 5
+	}
+	return &Filter{
+		cfg:        cfg,
+		models:     NewModelStore(filepath.Join(dataDir, "spam_model.json"), key),
+		quarantine: filepath.Join(dataDir, "spam.jsonl"),
+		key:        key,
+	}
+}
+
+// Action 返回判定为垃圾短信后的处理方式
+func (f *Filter) Action() string {
+	return f.cfg.Action
+}
+
+// Train 将短信标记为垃圾短信或正常短信，用于训练贝叶斯分类器
+func (f *Filter) Train(content string, spam bool) error {
+	if strings.TrimSpace(content) == "" {
+		return fmt.Errorf("要标记的短信内容不能为空")
+	}
+	return f.models.Train(content, spam)
+}
+
+// Model 返回当前的贝叶斯模型
+func (f *Filter) Model() (*Model, error) {
+	return f.models.Model()
+}
+
+// Check 判断短信是否为垃圾短信
+// 含验证码的短信只检查黑名单，不会被特征识别和贝叶斯分类误伤
+// 参数: sms - 已提取元数据的短信
+// 返回: 过滤结果
+func (f *Filter) Check(sms *types.SMS) Verdict {
+	verdict := Verdict{Probability: -1}
+
+	if matchSender(f.cfg.Allowlist, sms.Sender) {
+		verdict.Reasons = append(verdict.Reasons, "发送方在白名单中")
+		return verdict
+	}
+	if matchSender(f.cfg.Blocklist, sms.Sender) {
+		verdict.Spam = true
+		verdict.Reasons = append(verdict.Reasons, "发送方在黑名单中")
+		return verdict
+	}
+	if keyword := containsAny(sms.Content, f.cfg.BlockKeywords); keyword != "" {
+		verdict.Spam = true
+		verdict.Reasons = append(verdict.Reasons, "包含屏蔽关键字 "+keyword)
+		return verdict
+	}
+	if sms.Code != "" {
+		verdict.Reasons = append(verdict.Reasons, "包含验证码")
+		return verdict
+	}
+
+	score := 0
+	if !f.cfg.DisableHeuristics {
+		var reasons []string
+		score, reasons = heuristicScore(sms)
+		verdict.Reasons = append(verdict.Reasons, reasons...)
+	}
+
+	if model, err := f.models.Model(); err == nil && model.Ready(f.cfg.MinTraining) {
+		verdict.Probability = model.SpamProbability(sms.Content)
+		switch {
+		case verdict.Probability >= f.cfg.Threshold:
+			verdict.Spam = true
+			verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("贝叶斯分类垃圾短信概率 %.3f", verdict.Probability))
+			return verdict
+		case verdict.Probability <= 1-f.cfg.Threshold:
+			// 用户标记过的同类短信是正常短信，覆盖特征识别的结果
+			verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("贝叶斯分类垃圾短信概率 %.3f，判定为正常短信", verdict.Probability))
+			return verdict
+		}
+	}
+
+	verdict.Spam = score >= 2
+	return verdict
+}
+
+// heuristicScore 根据营销短信特征打分，达到 2 分判定为垃圾短信
+// 退订说明计 2 分，短链接、106 通道号码和促销用语各计 1 分
+func heuristicScore(sms *types.SMS) (int, []string) {
+	score := 0
+	var reasons []string
+	if keyword := containsAny(sms.Content, unsubscribeKeywords); keyword != "" {
+		score += 2
+		reasons = append(reasons, "包含退订说明 "+keyword)
+	}
+	lower := strings.ToLower(sms.Content)
+	for _, domain := range shortenerDomains {
+		if strings.Contains(lower, domain+"/") {
+			score++
+			reasons = append(reasons, "包含短链接 "+domain)
+			break
+		}
+	}
+	for _, prefix := range marketingSenderPrefixes {
+		if strings.HasPrefix(sms.Sender, prefix) {
+			score++
+			reasons = append(reasons, "发送方为 106 短信通道")
+			break
+		}
+	}
+	if keyword := containsAny(sms.Content, marketingKeywords); keyword != "" {
+		score++
+		reasons = append(reasons, "包含促销用语 "+keyword)
+	}
+	return score, reasons
+}
+
+// matchSender 判断发送方是否在号码列表中，以 * 结尾的条目按前缀匹配
+func matchSender(list []string, sender string) bool {
+	for _, entry := range list {
+		if prefix, ok := strings.CutSuffix(entry, "*"); ok {
+			if strings.HasPrefix(sender, prefix) {
+				return true
+			}
+		} else if sender == entry {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAny 返回内容中包含的第一个关键字，都不包含时返回空字符串
+func containsAny(content string, keywords []string) string {
+	for _, keyword := range keywords {
+		if keyword != "" && strings.Contains(content, keyword) {
+			return keyword
+		}
+	}
+	return ""
+}
+
+// QuarantineRecord 归档的垃圾短信
+type QuarantineRecord struct {
+	FilteredAt string               `json:"filtered_at"` // 过滤时间
+	ID         string               `json:"id"`          // 短信ID
+	Sender     string               `json:"sender"`      // 发送方号码
+	Location   types.NumberLocation `json:"location"`    // 发送方归属地和运营商
+	Timestamp  string               `json:"timestamp"`   // 短信接收时间
+	Content    string               `json:"content"`     // 短信内容
+	ModemID    string               `json:"modem_id"`    // 调制解调器ID
+	Action     string               `json:"action"`      // 处理方式
+	Verdict    Verdict              `json:"verdict"`     // 过滤结果
+}
+
+// recordKey 返回归档记录的标识，调制解调器ID、短信ID和接收时间都相同时视为同一条短信
+func recordKey(modemID, id, timestamp string) string {
+	return modemID + "\x00" + id + "\x00" + timestamp
+}
+
+// Quarantine 将被过滤的短信追加到归档文件，配置了加密时每条记录单独加密
+// 同一条短信重试处理时只归档一次
+// 参数:
+//   - sms: 被过滤的短信
+//   - verdict: 过滤结果
+func (f *Filter) Quarantine(sms *types.SMS, verdict Verdict) error {
+	data, err := json.Marshal(QuarantineRecord{
+		FilteredAt: time.Now().Format(time.RFC3339),
+		ID:         sms.ID,
+		Sender:     sms.Sender,
+		Location:   sms.Location,
+		Timestamp:  sms.Timestamp,
+		Content:    sms.Content,
+		ModemID:    sms.ModemID,
+		Action:     f.cfg.Action,
+		Verdict:    verdict,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化垃圾短信记录失败: %v", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.archived == nil {
+		archived := make(map[string]bool)
+		err := f.scanLines(func(line []byte) error {
+			var record QuarantineRecord
+			if err := json.Unmarshal(line, &record); err == nil {
+				archived[recordKey(record.ModemID, record.ID, record.Timestamp)] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		f.archived = archived
+	}
+	key := recordKey(sms.ModemID, sms.ID, sms.Timestamp)
+	if f.archived[key] {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(f.quarantine), 0755); err != nil {
+		return fmt.Errorf("创建数据目录失败: %v", err)
+	}
+	file, err := os.OpenFile(f.quarantine, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("打开垃圾短信归档失败: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(vault.Encode(f.key, data), '\n')); err != nil {
+		return fmt.Errorf("写入垃圾短信归档失败: %v", err)
+	}
+	f.archived[key] = true
+	return nil
+}
+
+// ReadQuarantine 读取归档的垃圾短信，按时间顺序返回，旧版本重复归档的同一条短信只返回第一次
+// 返回: 归档的垃圾短信，遇到无法解密的记录时返回错误
+func (f *Filter) ReadQuarantine() ([]QuarantineRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var records []QuarantineRecord
+	seen := make(map[string]bool)
+	err := f.scanLines(func(line []byte) error {
+		var record QuarantineRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil
+		}
+		if key := recordKey(record.ModemID, record.ID, record.Timestamp); !seen[key] {
+			seen[key] = true
+			records = append(records, record)
+		}
+		return nil
+	})
+	return records, err
+}
+
+// Rekey 用新密钥重新加密模型和被过滤短信的归档，先写入临时文件再替换，newKey 为 nil 时解密为明文
+// 调用期间不能有其他进程写入归档或训练模型
+func (f *Filter) Rekey(newKey *vault.Key) error {
+	if err := f.models.Rekey(newKey); err != nil {
+		return fmt.Errorf("重新加密垃圾短信模型失败: %v", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := os.Stat(f.quarantine); os.IsNotExist(err) {
+		f.key = newKey
+		return nil
+	}
+
+	var buf bytes.Buffer
+	err := f.scanLines(func(line []byte) error {
+		buf.Write(vault.Encode(newKey, line))
+		return buf.WriteByte('\n')
+	})
+	if err != nil {
+		return fmt.Errorf("重新加密垃圾短信归档失败: %v", err)
+	}
+	tmp := f.quarantine + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("重新加密垃圾短信归档失败: %v", err)
+	}
+	if err := os.Rename(tmp, f.quarantine); err != nil {
+		return fmt.Errorf("替换垃圾短信归档失败: %v", err)
+	}
+	f.key = newKey
+	return nil
+}
+
+// Path 返回被过滤短信归档的路径
+func (f *Filter) Path() string {
+	return f.quarantine
+}
+
+// scanLines 按写入顺序读取被过滤短信归档中解密后的 JSON，调用方需持有锁
+func (f *Filter) scanLines(fn func(line []byte) error) error {
+	file, err := os.Open(f.quarantine)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("打开垃圾短信归档失败: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		data, err := vault.Decode(f.key, scanner.Bytes())
+		if err != nil {
+			return fmt.Errorf("垃圾短信归档 %s 第 %d 行: %v", f.quarantine, line, err)
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取垃圾短信归档失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package spam
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"sim-sms-forward/pkg/types"
+	"sim-sms-forward/pkg/vault"
+)
+
+// TestQuarantineOnce 同一条短信重试处理时只归档一次，新的过滤器实例也能识别已归档的短信
+func TestQuarantineOnce(t *testing.T) {
+	dir := t.TempDir()
+	key, err := vault.New(vault.Config{Passphrase: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sms := &types.SMS{ID: "3", ModemID: "0", Sender: "10690000", Timestamp: "2026-10-18T09:00:00+08:00", Content: "限时优惠，回T退订"}
+	verdict := Verdict{Spam: true, Reasons: []string{"退订"}}
+
+	filter := NewFilter(Config{Enable: true}, dir, key)
+	for i := 0; i < 3; i++ {
+		if err := filter.Quarantine(sms, verdict); err != nil {
+			t.Fatalf("归档失败: %v", err)
+		}
+	}
+	// 调制解调器重新使用了同一个短信ID
+	next := *sms
+	next.Timestamp = "2026-10-18T10:00:00+08:00"
+	if err := filter.Quarantine(&next, verdict); err != nil {
+		t.Fatalf("归档失败: %v", err)
+	}
+
+	reopened := NewFilter(Config{Enable: true}, dir, key)
+	if err := reopened.Quarantine(sms, verdict); err != nil {
+		t.Fatalf("归档失败: %v", err)
+	}
+	data, err := os.ReadFile(reopened.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != 2 {
+		t.Errorf("归档了 %d 条记录，期望 2", lines)
+	}
+	if bytes.Contains(data, []byte("10690000")) {
+		t.Error("配置了加密时归档中不应出现明文")
+	}
+
+	records, err := reopened.ReadQuarantine()
+	if err != nil {
+		t.Fatalf("读取归档失败: %v", err)
+	}
+	if len(records) != 2 || records[0].Timestamp != sms.Timestamp || records[1].Timestamp != next.Timestamp {
+		t.Errorf("读取的归档不正确: %+v", records)
+	}
+}
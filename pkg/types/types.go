@@ -1,13 +1,103 @@
 // Package types 定义了短信转发系统中使用的数据结构
 package types
 
+import (
+	"fmt"
+	"strings"
+)
+
+// Priority 表示短信的推送优先级，由处理器根据短信内容推导
+// 各通知渠道再把它映射为自己的优先级模型
+type Priority int
+
+const (
+	PriorityLow    Priority = -1 // 低优先级，如营销、通知类短信
+	PriorityNormal Priority = 0  // 普通优先级（默认）
+	PriorityHigh   Priority = 1  // 高优先级，如验证码
+	PriorityUrgent Priority = 2  // 紧急，需要立即处理
+)
+
+// String 返回优先级的名称
+func (p Priority) String() string {
+	switch {
+	case p <= PriorityLow:
+		return "low"
+	case p == PriorityNormal:
+		return "normal"
+	case p == PriorityHigh:
+		return "high"
+	default:
+		return "urgent"
+	}
+}
+
+// ParsePriority 将优先级名称解析为 Priority
+// 参数: name - 优先级名称: low、normal、high、urgent
+// 返回: 对应的优先级和可能的错误
+func ParsePriority(name string) (Priority, error) {
+	switch name {
+	case "low":
+		return PriorityLow, nil
+	case "normal":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	case "urgent":
+		return PriorityUrgent, nil
+	default:
+		return PriorityNormal, fmt.Errorf("未知的优先级 %s，可选 low、normal、high、urgent", name)
+	}
+}
+
 // SMS 结构体表示一条短信的完整信息
-// 包含短信的ID、发送方号码、接收时间戳和短信内容
+// 包含短信的ID、发送方号码、接收时间戳和短信内容，以及处理器提取出的元数据
 type SMS struct {
 	ID        string // 短信在系统中的唯一标识符
 	Sender    string // 发送方的电话号码
 	Timestamp string // 短信接收的时间戳
 	Content   string // 短信的文本内容
+
+	// 以下字段由处理器在转发前填充，可在通知模板中使用
+	SenderName string         // 通讯录中发送方的名称，未找到时为空
+	ModemID    string         // 接收该短信的调制解调器ID
+	SIM        string         // 接收该短信的 SIM 卡 ICCID，获取失败时为空
+	Code       string         // 从短信内容中提取的验证码，未识别到时为空
+	Location   NumberLocation // 发送方号码的归属地和运营商，无法识别时为空
+	Priority   Priority       // 推送优先级
+	Spam       bool           // 是否被判定为垃圾短信
+	Replay     bool           // 是否为从归档重新发送的短信
+
+	// 以下字段由路由规则设置，非空时替代默认的通知标题和正文
+	Title string // 渲染后的通知标题
+	Body  string // 渲染后的通知正文
+}
+
+// DisplaySender 返回用于显示的发送方，通讯录中有名称时返回名称，否则返回号码
+// 可在通知模板中使用 {{.DisplaySender}}
+func (s *SMS) DisplaySender() string {
+	if s.SenderName != "" {
+		return s.SenderName
+	}
+	return s.Sender
+}
+
+// NumberLocation 表示电话号码的归属地和运营商
+type NumberLocation struct {
+	Province string `json:"province,omitempty"` // 省份，直辖市为市名
+	City     string `json:"city,omitempty"`     // 城市
+	Carrier  string `json:"carrier,omitempty"`  // 运营商: 移动、联通、电信、广电，虚拟运营商为 X虚拟
+}
+
+// String 返回 "省份 城市 运营商" 格式的归属地，省份和城市相同时只显示一次
+// 可在通知模板中使用 {{.Location}}
+func (l NumberLocation) String() string {
+	var parts []string
+	for _, p := range []string{l.Province, l.City, l.Carrier} {
+		if p != "" && (len(parts) == 0 || parts[len(parts)-1] != p) {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, " ")
 }
 
 // BarkRequest 表示发送到 Bark API 的请求数据结构
@@ -16,6 +106,11 @@ type BarkRequest struct {
 	Body     string `json:"body"`               // 通知的主体内容
 	Title    string `json:"title"`              // 通知的标题
 	Subtitle string `json:"subtitle,omitempty"` // 可选的副标题
+	Group    string `json:"group,omitempty"`    // 可选的消息分组
+	Level    string `json:"level,omitempty"`    // 可选的中断级别: active、timeSensitive、passive、critical
+	Sound    string `json:"sound,omitempty"`    // 可选的提示音
+	Icon     string `json:"icon,omitempty"`     // 可选的通知图标 URL
+	Copy     string `json:"copy,omitempty"`     // 可选的复制内容，如验证码
 }
 
 // BarkResponse 表示 Bark API 返回的响应数据结构
@@ -39,3 +134,56 @@ type HismsgResponse struct {
 	Code int         `json:"code"` // 响应状态码，200表示成功
 	Data interface{} `json:"data"` // 响应的附加数据
 }
+
+// ModemStatus 表示调制解调器和 SIM 卡的运行状态
+// 由 mmcli -m <ID> -K 的输出解析得到
+type ModemStatus struct {
+	ModemID           string `json:"modem_id"`                // 调制解调器ID
+	State             string `json:"state"`                   // 调制解调器状态，如 registered、connected、disabled、failed
+	FailedReason      string `json:"failed_reason,omitempty"` // 状态为 failed 时的原因，如 sim-missing
+	SignalQuality     int    `json:"signal_quality"`          // 信号质量（0-100）
+	AccessTech        string `json:"access_tech,omitempty"`   // 接入技术，如 lte
+	RegistrationState string `json:"registration"`            // 网络注册状态，如 home、roaming、searching、idle
+	OperatorName      string `json:"operator,omitempty"`      // 运营商名称
+	OperatorCode      string `json:"operator_code,omitempty"` // 运营商代码（MCC+MNC）
+	IMEI              string `json:"imei,omitempty"`          // 设备 IMEI
+	SIMPath           string `json:"sim,omitempty"`           // SIM 卡对象路径，未插卡时为空
+	StoredSMS         int    `json:"stored_sms"`              // 调制解调器上保存的短信总数（所有状态）
+
+	Storage *StorageStatus `json:"storage,omitempty"` // 短信存储的已用条数和容量，只在 MQTT 状态中填充，读取失败时为空
+}
+
+// StorageStatus 表示调制解调器短信存储的使用情况
+// 支持的存储和默认存储由 mmcli --messaging-status 得到，已用和容量优先通过 AT+CPMS? 读取
+type StorageStatus struct {
+	SupportedStorages []string       `json:"supported_storages,omitempty"` // 支持的存储，如 sm（SIM 卡）、me（调制解调器）
+	DefaultStorage    string         `json:"default_storage,omitempty"`    // 接收短信的默认存储
+	Storages          []StorageUsage `json:"storages,omitempty"`           // AT+CPMS? 报告的各存储用量，读取失败时为空
+	Used              int            `json:"used"`                         // 接收短信的存储中已用的条数
+	Total             int            `json:"total"`                        // 接收短信的存储的容量，未知时为 0
+	States            map[string]int `json:"states"`                       // 调制解调器上各状态的短信数，如 received、sent、stored
+}
+
+// Percent 返回存储使用的百分比，容量未知时返回 -1
+func (s *StorageStatus) Percent() int {
+	if s.Total <= 0 {
+		return -1
+	}
+	return s.Used * 100 / s.Total
+}
+
+// StorageUsage 表示一个短信存储的用量
+type StorageUsage struct {
+	Name  string `json:"name"`  // 存储名称，如 SM、ME
+	Used  int    `json:"used"`  // 已用条数
+	Total int    `json:"total"` // 容量
+}
+
+// SIMInfo 表示 SIM 卡的识别信息
+// 由 mmcli -i <SIM路径> -K 的输出解析得到
+type SIMInfo struct {
+	ICCID        string `json:"iccid"`                 // SIM 卡 ICCID
+	IMSI         string `json:"imsi,omitempty"`        // 国际移动用户识别码
+	OperatorName string `json:"operator,omitempty"`    // SIM 卡所属运营商
+	OwnNumbers   string `json:"own_numbers,omitempty"` // 本机号码（部分 SIM 卡不提供）
+}
@@ -0,0 +1,442 @@
+// Package vault 提供本地存储的静态加密
+// 密钥由口令或密钥文件通过 PBKDF2-SHA256 派生，每条记录使用 AES-256-GCM 单独加密，
+// 派生参数和用于校验密钥的密文保存在数据目录的 keyring.json 中
+package vault
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Config 加密配置，passphrase、passphrase_env 和 key_file 只能配置一个，都不配置时不加密
+type Config struct {
+	Passphrase    string `json:"passphrase,omitempty"`     // 口令
+	PassphraseEnv string `json:"passphrase_env,omitempty"` // 从环境变量读取口令，避免口令写在配置文件中
+	KeyFile       string `json:"key_file,omitempty"`       // 密钥文件，使用文件的全部内容派生密钥
+}
+
+// Enabled 是否配置了加密
+func (c Config) Enabled() bool {
+	return c.Passphrase != "" || c.PassphraseEnv != "" || c.KeyFile != ""
+}
+
+// Validate 验证加密配置
+func (c Config) Validate() error {
+	count := 0
+	for _, value := range []string{c.Passphrase, c.PassphraseEnv, c.KeyFile} {
+		if value != "" {
+			count++
+		}
+	}
+	if count > 1 {
+		return fmt.Errorf("encryption 的 passphrase、passphrase_env 和 key_file 只能配置一个")
+	}
+	return nil
+}
+
+// secret 读取用于派生密钥的口令或密钥文件内容
+func (c Config) secret() ([]byte, error) {
+	switch {
+	case c.Passphrase != "":
+		return []byte(c.Passphrase), nil
+	case c.PassphraseEnv != "":
+		value := os.Getenv(c.PassphraseEnv)
+		if value == "" {
+			return nil, fmt.Errorf("环境变量 %s 未设置，无法读取加密口令", c.PassphraseEnv)
+		}
+		return []byte(value), nil
+	default:
+		data, err := os.ReadFile(c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取密钥文件失败: %v", err)
+		}
+		if len(data) == 0 {
+			return nil, fmt.Errorf("密钥文件 %s 为空", c.KeyFile)
+		}
+		return data, nil
+	}
+}
+
+const (
+	// sealedPrefix 加密记录的前缀，格式为 enc1:<密钥ID>:<base64(nonce+密文)>
+	sealedPrefix = "enc1:"
+	// defaultIterations PBKDF2 的迭代次数
+	defaultIterations = 200000
+	// checkPlaintext 校验密钥时加密的明文
+	checkPlaintext = "sim-sms-forward"
+)
+
+// keyring keyring.json 的内容
+type keyring struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       string `json:"salt"`  // base64 编码的盐
+	Check      string `json:"check"` // 用当前密钥加密的校验文本
+}
+
+// Key 派生出的加密密钥
+type Key struct {
+	id   string
+	aead cipher.AEAD
+	ring keyring
+
+	// fallback 更换密钥期间同时接受的另一个密钥，部分记录已经用它重新加密
+	fallback *Key
+}
+
+// KeyringPath 返回数据目录中 keyring.json 的路径
+func KeyringPath(dataDir string) string {
+	return filepath.Join(dataDir, "keyring.json")
+}
+
+// pendingPath 返回更换密钥期间新密钥的 keyring 路径
+// rekey 在改写任何数据之前先保存新密钥的盐，中途失败时可以用同样的新口令继续，完成后替换 keyring.json
+func pendingPath(dataDir string) string {
+	return KeyringPath(dataDir) + ".next"
+}
+
+// Open 按配置打开加密密钥
+// 数据目录中没有 keyring.json 时生成新的盐并保存；已有时用它校验密钥，口令或密钥文件不正确时返回错误
+// 参数:
+//   - cfg: 加密配置
+//   - dataDir: 数据目录
+//
+// 返回: 密钥和可能的错误，未配置加密时返回 nil, nil
+func Open(cfg Config, dataDir string) (*Key, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	if _, err := os.Stat(pendingPath(dataDir)); err == nil {
+		return nil, fmt.Errorf("上次的 rekey 没有完成，部分数据已使用新密钥加密，请使用相同的参数重新运行 rekey")
+	}
+	return openCurrent(cfg, dataDir)
+}
+
+// openCurrent 按配置打开 keyring.json 中的密钥，不检查是否有未完成的 rekey
+func openCurrent(cfg Config, dataDir string) (*Key, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	secret, err := cfg.secret()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := openRing(secret, KeyringPath(dataDir))
+	if os.IsNotExist(err) {
+		key, err := newKey(secret)
+		if err != nil {
+			return nil, err
+		}
+		if err := key.Save(dataDir); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	if err == errWrongSecret {
+		return nil, fmt.Errorf("加密口令或密钥文件不正确，无法打开 %s 中的加密数据", dataDir)
+	}
+	return key, err
+}
+
+// errWrongSecret 口令或密钥文件与 keyring 中的校验密文不匹配
+var errWrongSecret = fmt.Errorf("加密口令或密钥文件不正确")
+
+// openRing 读取 keyring 文件并用 secret 派生密钥
+// 返回: 密钥和可能的错误，文件不存在时返回 os.IsNotExist 可以识别的错误，口令不正确时返回 errWrongSecret
+func openRing(secret []byte, path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %v", filepath.Base(path), err)
+	}
+
+	var ring keyring
+	if err := json.Unmarshal(data, &ring); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %v", filepath.Base(path), err)
+	}
+	salt, err := base64.StdEncoding.DecodeString(ring.Salt)
+	if err != nil || ring.KDF != "pbkdf2-sha256" || ring.Iterations <= 0 {
+		return nil, fmt.Errorf("%s 格式不正确", filepath.Base(path))
+	}
+	key, err := deriveKey(secret, salt, ring.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	key.ring = ring
+	plaintext, err := key.Open([]byte(ring.Check))
+	if err != nil || string(plaintext) != checkPlaintext {
+		return nil, errWrongSecret
+	}
+	return key, nil
+}
+
+// BeginRekey 开始或继续更换密钥
+// 新密钥的 keyring 在改写数据之前保存为 keyring.json.next，上次 rekey 中途失败时用同样的新口令重新运行会继续使用它
+// 参数:
+//   - current: 当前的加密配置，未配置加密时旧数据为明文
+//   - next: 新的加密配置，未配置时表示解密为明文
+//   - dataDir: 数据目录
+//
+// 返回:
+//   - 读取数据用的密钥，同时接受旧密钥和新密钥加密的记录，两者都没有时为 nil
+//   - 新密钥，解密为明文时为 nil
+//   - 当前口令不正确或与上次未完成的 rekey 使用的新口令不同时返回错误
+func BeginRekey(current, next Config, dataDir string) (*Key, *Key, error) {
+	oldKey, err := openCurrent(current, dataDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, statErr := os.Stat(pendingPath(dataDir))
+	pending := statErr == nil
+
+	var nextKey *Key
+	if next.Enabled() {
+		if err := next.Validate(); err != nil {
+			return nil, nil, err
+		}
+		secret, err := next.secret()
+		if err != nil {
+			return nil, nil, err
+		}
+		nextKey, err = openRing(secret, pendingPath(dataDir))
+		switch {
+		case os.IsNotExist(err):
+			if nextKey, err = newKey(secret); err != nil {
+				return nil, nil, err
+			}
+			if err := nextKey.save(pendingPath(dataDir)); err != nil {
+				return nil, nil, err
+			}
+		case err == errWrongSecret:
+			return nil, nil, fmt.Errorf("上次的 rekey 没有完成，部分数据已使用当时的新密钥加密，请使用上次的新口令或密钥文件重新运行")
+		case err != nil:
+			return nil, nil, err
+		}
+	} else if pending {
+		return nil, nil, fmt.Errorf("上次的 rekey 没有完成，部分数据已使用当时的新密钥加密，请先使用上次的新口令或密钥文件重新运行 rekey，再解密")
+	}
+
+	readKey := oldKey
+	switch {
+	case readKey == nil:
+		readKey = nextKey
+	case nextKey != nil:
+		withNext := *oldKey
+		withNext.fallback = nextKey
+		readKey = &withNext
+	}
+	return readKey, nextKey, nil
+}
+
+// CommitRekey 在所有数据都重新加密后切换到新密钥，用 keyring.json.next 替换 keyring.json
+// 参数: newKey - BeginRekey 返回的新密钥，为 nil 时表示已解密为明文，删除 keyring.json
+func CommitRekey(dataDir string, newKey *Key) error {
+	if newKey == nil {
+		if err := os.Remove(KeyringPath(dataDir)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("删除 keyring.json 失败: %v", err)
+		}
+		return nil
+	}
+	if err := os.Rename(pendingPath(dataDir), KeyringPath(dataDir)); err != nil {
+		return fmt.Errorf("写入 keyring.json 失败: %v", err)
+	}
+	return nil
+}
+
+// New 用新的口令或密钥文件生成密钥，不写入 keyring.json，用于 rekey
+// 参数: cfg - 新的加密配置
+// 返回: 新密钥和可能的错误
+func New(cfg Config) (*Key, error) {
+	if !cfg.Enabled() {
+		return nil, fmt.Errorf("未指定新的口令或密钥文件")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	secret, err := cfg.secret()
+	if err != nil {
+		return nil, err
+	}
+	return newKey(secret)
+}
+
+// newKey 生成随机盐并派生密钥
+func newKey(secret []byte) (*Key, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("生成随机盐失败: %v", err)
+	}
+	key, err := deriveKey(secret, salt, defaultIterations)
+	if err != nil {
+		return nil, err
+	}
+	key.ring = keyring{
+		Version:    1,
+		KDF:        "pbkdf2-sha256",
+		Iterations: defaultIterations,
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		Check:      string(key.Seal([]byte(checkPlaintext))),
+	}
+	return key, nil
+}
+
+// deriveKey 用 PBKDF2-SHA256 派生 AES-256 密钥，密钥ID为密钥摘要的前8个十六进制字符
+func deriveKey(secret, salt []byte, iterations int) (*Key, error) {
+	raw := pbkdf2SHA256(secret, salt, iterations, 32)
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, fmt.Errorf("创建加密器失败: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("创建加密器失败: %v", err)
+	}
+	sum := sha256.Sum256(raw)
+	return &Key{id: hex.EncodeToString(sum[:4]), aead: aead}, nil
+}
+
+// ID 返回密钥ID，用于识别记录是用哪个密钥加密的
+func (k *Key) ID() string {
+	return k.id
+}
+
+// Save 将密钥的派生参数和校验密文保存到数据目录的 keyring.json
+func (k *Key) Save(dataDir string) error {
+	return k.save(KeyringPath(dataDir))
+}
+
+// save 先写入临时文件再重命名，保存密钥的派生参数和校验密文
+func (k *Key) save(path string) error {
+	name := filepath.Base(path)
+	data, err := json.MarshalIndent(k.ring, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化 %s 失败: %v", name, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建数据目录失败: %v", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("写入 %s 失败: %v", name, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("写入 %s 失败: %v", name, err)
+	}
+	return nil
+}
+
+// Seal 加密一条记录，返回不含换行的文本，可以直接作为 JSON 行文件的一行
+// 密钥ID作为附加数据参与认证
+func (k *Key) Seal(plaintext []byte) []byte {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		// crypto/rand 在支持的平台上不会失败
+		panic(fmt.Sprintf("生成随机数失败: %v", err))
+	}
+	sealed := k.aead.Seal(nonce, nonce, plaintext, []byte(k.id))
+	return []byte(sealedPrefix + k.id + ":" + base64.StdEncoding.EncodeToString(sealed))
+}
+
+// Open 解密 Seal 加密的记录
+// 参数: data - 加密后的文本
+// 返回: 明文，记录由其他密钥加密或被篡改时返回错误；更换密钥期间也接受新密钥加密的记录
+func (k *Key) Open(data []byte) ([]byte, error) {
+	rest, ok := bytes.CutPrefix(bytes.TrimSpace(data), []byte(sealedPrefix))
+	if !ok {
+		return nil, fmt.Errorf("数据未加密")
+	}
+	id, encoded, ok := strings.Cut(string(rest), ":")
+	if !ok {
+		return nil, fmt.Errorf("加密数据格式不正确")
+	}
+	if id != k.id && k.fallback != nil && id == k.fallback.id {
+		return k.fallback.Open(data)
+	}
+	if id != k.id {
+		return nil, fmt.Errorf("数据由其他密钥（%s）加密，当前密钥为 %s", id, k.id)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < k.aead.NonceSize() {
+		return nil, fmt.Errorf("加密数据格式不正确")
+	}
+	nonce, ciphertext := sealed[:k.aead.NonceSize()], sealed[k.aead.NonceSize():]
+	plaintext, err := k.aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("解密失败，数据可能已损坏: %v", err)
+	}
+	return plaintext, nil
+}
+
+// IsSealed 判断数据是否为加密记录
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte(sealedPrefix))
+}
+
+// Decode 读取一条可能加密的记录
+// 参数:
+//   - key: 密钥，未配置加密时为 nil
+//   - data: 一条记录
+//
+// 返回: 明文。未加密的记录原样返回，便于从明文存储迁移；遇到加密记录但没有配置密钥时返回错误
+func Decode(key *Key, data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		return data, nil
+	}
+	if key == nil {
+		return nil, fmt.Errorf("数据已加密，需要在配置文件的 encryption 中配置口令或密钥文件")
+	}
+	return key.Open(data)
+}
+
+// Encode 加密一条记录，未配置加密时原样返回
+func Encode(key *Key, data []byte) []byte {
+	if key == nil {
+		return data
+	}
+	return key.Seal(data)
+}
+
+// pbkdf2SHA256 实现 RFC 8018 中的 PBKDF2，伪随机函数为 HMAC-SHA256
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	var counter [4]byte
+	derived := make([]byte, 0, blocks*hashLen)
+	u := make([]byte, hashLen)
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(counter[:], uint32(block))
+		prf.Write(counter[:])
+		t := prf.Sum(nil)
+		copy(u, t)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		derived = append(derived, t...)
+	}
+	return derived[:keyLen]
+}
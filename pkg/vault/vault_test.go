@@ -0,0 +1,174 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// TestRekeyResume rekey 中途失败后，已用新密钥加密的记录仍能读取，用同样的新口令可以继续完成
+func TestRekeyResume(t *testing.T) {
+	dir := t.TempDir()
+	current := Config{Passphrase: "旧口令"}
+	next := Config{Passphrase: "新口令"}
+
+	oldKey, err := Open(current, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := [][]byte{Encode(oldKey, []byte("1")), Encode(oldKey, []byte("2"))}
+
+	readKey, newKey, err := BeginRekey(current, next, dir)
+	if err != nil {
+		t.Fatalf("BeginRekey 失败: %v", err)
+	}
+	// 只改写了第一条记录后进程退出
+	plain, err := Decode(readKey, records[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	records[0] = Encode(newKey, plain)
+
+	if _, err := Open(current, dir); err == nil || !strings.Contains(err.Error(), "rekey 没有完成") {
+		t.Errorf("rekey 未完成时打开密钥应返回错误，实际为 %v", err)
+	}
+	if _, _, err := BeginRekey(current, Config{Passphrase: "另一个口令"}, dir); err == nil {
+		t.Error("使用不同的新口令继续 rekey 应返回错误")
+	}
+	if _, _, err := BeginRekey(current, Config{}, dir); err == nil {
+		t.Error("rekey 未完成时解密为明文应返回错误")
+	}
+
+	readKey, resumed, err := BeginRekey(current, next, dir)
+	if err != nil {
+		t.Fatalf("继续 rekey 失败: %v", err)
+	}
+	if resumed.ID() != newKey.ID() {
+		t.Fatalf("继续 rekey 时应使用上次的新密钥 %s，实际为 %s", newKey.ID(), resumed.ID())
+	}
+	for i, record := range records {
+		plain, err := Decode(readKey, record)
+		if err != nil {
+			t.Fatalf("第 %d 条记录无法读取: %v", i+1, err)
+		}
+		records[i] = Encode(resumed, plain)
+	}
+	if err := CommitRekey(dir, resumed); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(current, dir); err == nil {
+		t.Error("完成后旧口令不应再能打开")
+	}
+	key, err := Open(next, dir)
+	if err != nil {
+		t.Fatalf("完成后用新口令打开失败: %v", err)
+	}
+	for i, record := range records {
+		if plain, err := Decode(key, record); err != nil || string(plain) != string(rune('1'+i)) {
+			t.Errorf("第 %d 条记录为 %q, %v", i+1, plain, err)
+		}
+	}
+}
+
+// TestRekeyFromPlaintext 首次启用加密时中途失败，明文和已加密的记录都能读取
+func TestRekeyFromPlaintext(t *testing.T) {
+	dir := t.TempDir()
+	next := Config{Passphrase: "新口令"}
+	_, newKey, err := BeginRekey(Config{}, next, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed := Encode(newKey, []byte("1"))
+
+	readKey, _, err := BeginRekey(Config{}, next, dir)
+	if err != nil {
+		t.Fatalf("继续 rekey 失败: %v", err)
+	}
+	for _, record := range [][]byte{sealed, []byte("2")} {
+		if _, err := Decode(readKey, record); err != nil {
+			t.Errorf("记录 %q 无法读取: %v", record, err)
+		}
+	}
+}
+
+// TestPBKDF2SHA256 使用公开的 PBKDF2-HMAC-SHA256 测试向量（RFC 7914 第 11 节及 RFC 6070 对应的 SHA-256 版本）
+func TestPBKDF2SHA256(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		iterations     int
+		want           string
+	}{
+		{"password", "salt", 1, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{"password", "salt", 2, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{"password", "salt", 4096, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, "348c89dbcbd32b2f32d814b8116e84cf2b17347ebc1800181c"},
+		{"pass\x00word", "sa\x00lt", 4096, "89b69d0516f829893c696226650a8687"},
+		// 输出长度超过一个 HMAC 块
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+	}
+	for _, tt := range tests {
+		want, _ := hex.DecodeString(tt.want)
+		got := pbkdf2SHA256([]byte(tt.password), []byte(tt.salt), tt.iterations, len(want))
+		if !bytes.Equal(got, want) {
+			t.Errorf("PBKDF2(%q, %q, %d) = %x，期望 %s", tt.password, tt.salt, tt.iterations, got, tt.want)
+		}
+	}
+}
+
+// TestSealOpen 加密记录可以解密，使用其他密钥、改写密钥ID或篡改密文时解密失败
+func TestSealOpen(t *testing.T) {
+	key, err := New(Config{Passphrase: "口令"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := New(Config{Passphrase: "另一个口令"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte(`{"sender":"95588","content":"验证码 123456"}`)
+
+	sealed := key.Seal(plaintext)
+	if bytes.Contains(sealed, []byte("95588")) || bytes.ContainsAny(sealed, "\r\n") {
+		t.Fatalf("加密记录包含明文或换行: %s", sealed)
+	}
+	if again := key.Seal(plaintext); bytes.Equal(again, sealed) {
+		t.Error("两次加密的结果相同，nonce 没有随机生成")
+	}
+	if got, err := key.Open(sealed); err != nil || !bytes.Equal(got, plaintext) {
+		t.Fatalf("解密结果为 %q, %v", got, err)
+	}
+
+	if _, err := other.Open(sealed); err == nil {
+		t.Error("使用其他密钥解密应失败")
+	}
+	// 把密钥ID改成另一个密钥的ID，密钥ID参与认证，解密仍然失败
+	relabeled := bytes.Replace(sealed, []byte(key.ID()), []byte(other.ID()), 1)
+	if _, err := other.Open(relabeled); err == nil {
+		t.Error("改写密钥ID后解密应失败")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(string(sealed[len(sealedPrefix+key.ID()+":"):]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw[len(raw)-1] ^= 1
+	tampered := []byte(sealedPrefix + key.ID() + ":" + base64.StdEncoding.EncodeToString(raw))
+	if _, err := key.Open(tampered); err == nil {
+		t.Error("篡改密文后解密应失败")
+	}
+	for _, bad := range []string{sealedPrefix + key.ID(), sealedPrefix + key.ID() + ":!!!", sealedPrefix + key.ID() + ":AAAA"} {
+		if _, err := key.Open([]byte(bad)); err == nil {
+			t.Errorf("格式不正确的记录 %q 解密应失败", bad)
+		}
+	}
+
+	if _, err := Decode(nil, sealed); err == nil {
+		t.Error("没有密钥时读取加密记录应返回错误")
+	}
+	if got, err := Decode(key, []byte("明文记录")); err != nil || string(got) != "明文记录" {
+		t.Errorf("明文记录应原样返回，实际为 %q, %v", got, err)
+	}
+}
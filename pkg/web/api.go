@@ -0,0 +1,243 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"sim-sms-forward/pkg/archive"
+	"sim-sms-forward/pkg/contacts"
+	"sim-sms-forward/pkg/logger"
+	"sim-sms-forward/pkg/types"
+)
+
+// statusResponse /api/status 的响应
+type statusResponse struct {
+	DeviceID  string             `json:"device_id"`
+	Modem     *types.ModemStatus `json:"modem,omitempty"`
+	ModemErr  string             `json:"modem_error,omitempty"`
+	SIM       *types.SIMInfo     `json:"sim,omitempty"`
+	SIMErr    string             `json:"sim_error,omitempty"`
+	Channels  []string           `json:"channels"`
+	AllowSend bool               `json:"allow_send"`
+	Archive   bool               `json:"archive"`
+	Events    bool               `json:"events"`
+}
+
+// conversation 按发送方分组的会话
+type conversation struct {
+	Sender     string          `json:"sender"`
+	SenderName string          `json:"sender_name,omitempty"`
+	Count      int             `json:"count"`
+	Failed     int             `json:"failed"` // 最近一次投递失败的短信数
+	Last       *archive.Record `json:"last"`
+}
+
+// handleLogin 用密码或访问令牌登录，成功后设置会话 Cookie
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "请求格式不正确")
+		return
+	}
+	if !s.checkSecret(req.Password) {
+		// 延迟响应，降低暴力猜测密码的速度
+		time.Sleep(time.Second)
+		logger.Errorf("网页控制台登录失败，来源: %s", r.RemoteAddr)
+		writeError(w, http.StatusUnauthorized, "密码不正确")
+		return
+	}
+	id, err := s.newSession()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(sessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	logger.Infof("网页控制台登录成功，来源: %s", r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+// handleLogout 注销当前会话
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		s.mu.Lock()
+		delete(s.sessions, cookie.Value)
+		s.mu.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+// handleStatus 返回调制解调器状态、SIM 卡信息和控制台支持的功能
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	resp := statusResponse{
+		DeviceID:  s.deviceID,
+		Channels:  s.backend.Channels,
+		AllowSend: s.backend.Send != nil,
+		Archive:   s.backend.Archive != nil,
+		Events:    s.backend.Events != nil,
+	}
+	if s.backend.Status != nil {
+		status, err := s.backend.Status()
+		if err != nil {
+			resp.ModemErr = err.Error()
+		}
+		resp.Modem = status
+	}
+	if s.backend.SIM != nil {
+		sim, err := s.backend.SIM()
+		if err != nil {
+			resp.SIMErr = err.Error()
+		}
+		resp.SIM = sim
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleConversations 按发送方分组返回会话列表，按最后一条短信的时间从新到旧排列
+// 查询参数: q - 搜索词，只返回有匹配短信的会话
+func (s *Server) handleConversations(w http.ResponseWriter, r *http.Request) {
+	if s.backend.Archive == nil {
+		writeError(w, http.StatusServiceUnavailable, "短信归档未启用")
+		return
+	}
+	records, err := s.backend.Archive.Search(archive.Query{Text: r.URL.Query().Get("q")})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// 记录已按接收时间从新到旧排列，每组的第一条即为最后一条短信
+	groups := make(map[string]*conversation)
+	var list []*conversation
+	for _, record := range records {
+		key := contacts.Normalize(record.Sender)
+		c, ok := groups[key]
+		if !ok {
+			c = &conversation{Sender: record.Sender, SenderName: record.SenderName, Last: record}
+			groups[key] = c
+			list = append(list, c)
+		}
+		c.Count++
+		if failed(record) {
+			c.Failed++
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Last.ReceivedAt.After(list[j].Last.ReceivedAt)
+	})
+	if list == nil {
+		list = []*conversation{}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// handleMessages 搜索归档的短信，按接收时间从新到旧返回
+// 查询参数:
+//   - sender: 发送方号码或通讯录名称
+//   - q: 搜索词
+//   - limit: 最多返回的条数，默认 50
+func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
+	if s.backend.Archive == nil {
+		writeError(w, http.StatusServiceUnavailable, "短信归档未启用")
+		return
+	}
+	params := r.URL.Query()
+	q := archive.Query{Sender: params.Get("sender"), Text: params.Get("q"), Limit: 50}
+	if limit, err := strconv.Atoi(params.Get("limit")); err == nil && limit > 0 {
+		q.Limit = limit
+	}
+	records, err := s.backend.Archive.Search(q)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, records)
+}
+
+// handleRetry 将归档短信重新发送到指定的通知渠道
+func (s *Server) handleRetry(w http.ResponseWriter, r *http.Request) {
+	if s.backend.Retry == nil {
+		writeError(w, http.StatusServiceUnavailable, "不支持重新发送")
+		return
+	}
+	var req struct {
+		ID      int64  `json:"id"`
+		Channel string `json:"channel"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID <= 0 || req.Channel == "" {
+		writeError(w, http.StatusBadRequest, "请求格式不正确")
+		return
+	}
+	logger.Infof("网页控制台请求重新发送归档短信 #%d 到 %s", req.ID, req.Channel)
+	if err := s.backend.Retry(req.ID, req.Channel); err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+// handleSend 通过调制解调器发送短信
+func (s *Server) handleSend(w http.ResponseWriter, r *http.Request) {
+	if s.backend.Send == nil {
+		writeError(w, http.StatusForbidden, "未开启网页发送短信，请在配置文件的 web 中设置 allow_send")
+		return
+	}
+	var req struct {
+		Number string `json:"number"`
+		Text   string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "请求格式不正确")
+		return
+	}
+	req.Number = strings.TrimSpace(req.Number)
+	logger.Infof("网页控制台请求发送短信到 %s", logger.Phone(req.Number))
+	if err := s.backend.Send(req.Number, req.Text); err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+// failed 判断短信是否有通知渠道最近一次投递失败
+func failed(r *archive.Record) bool {
+	latest := make(map[string]bool)
+	for _, d := range r.Deliveries {
+		latest[d.Channel] = d.OK
+	}
+	for _, ok := range latest {
+		if !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// writeJSON 以 JSON 格式写入响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Errorf("写入网页控制台响应失败: %v", err)
+	}
+}
+
+// writeError 以 JSON 格式写入错误响应
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,237 @@
+'use strict';
+
+const $ = (id) => document.getElementById(id);
+let status = null;
+let currentSender = null;
+
+// api 调用接口，未登录时显示登录页
+async function api(path, body) {
+  const options = body === undefined ? {} : {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(body),
+  };
+  const resp = await fetch(path, options);
+  const data = await resp.json().catch(() => ({}));
+  if (resp.status === 401 && path !== 'api/login') {
+    showLogin();
+    throw new Error(data.error || '未登录');
+  }
+  if (!resp.ok) {
+    throw new Error(data.error || resp.statusText);
+  }
+  return data;
+}
+
+// el 创建元素，children 可以是字符串或元素
+function el(tag, className, ...children) {
+  const node = document.createElement(tag);
+  if (className) node.className = className;
+  for (const child of children) {
+    if (child !== null && child !== undefined) node.append(child);
+  }
+  return node;
+}
+
+function formatTime(value) {
+  const d = new Date(value);
+  const pad = (n) => String(n).padStart(2, '0');
+  return `${d.getFullYear()}-${pad(d.getMonth() + 1)}-${pad(d.getDate())} ${pad(d.getHours())}:${pad(d.getMinutes())}`;
+}
+
+function displaySender(r) {
+  return r.sender_name ? `${r.sender_name}（${r.sender}）` : r.sender;
+}
+
+function showLogin() {
+  disconnectEvents();
+  $('app').hidden = true;
+  $('login').hidden = false;
+  $('password').focus();
+}
+
+async function showApp() {
+  $('login').hidden = true;
+  $('app').hidden = false;
+  await loadStatus();
+  await Promise.all([loadConversations(), loadFeed()]);
+  connectEvents();
+}
+
+// connectEvents 订阅事件流，收到短信或投递结果变化时立即刷新，断线后浏览器会自动重连
+let stream = null;
+function connectEvents() {
+  if (!status.events || stream) return;
+  stream = new EventSource('api/events?types=sms_forwarded,delivery_failed,modem_state');
+  stream.addEventListener('sms_forwarded', refresh);
+  stream.addEventListener('delivery_failed', refresh);
+  stream.addEventListener('modem_state', () => loadStatus().catch(() => {}));
+}
+
+function disconnectEvents() {
+  if (stream) stream.close();
+  stream = null;
+}
+
+// loadStatus 显示调制解调器和 SIM 卡状态
+async function loadStatus() {
+  status = await api('api/status');
+  $('title').textContent = `短信转发 · ${status.device_id}`;
+  $('compose-tab').hidden = !status.allow_send;
+
+  const items = [];
+  const add = (label, value, bad) => {
+    const item = el('div', 'item', el('b', null, label), String(value || '-'));
+    if (bad) item.classList.add('bad');
+    items.push(item);
+  };
+  const m = status.modem;
+  if (m) {
+    add('状态', m.failed_reason ? `${m.state}（${m.failed_reason}）` : m.state, m.state === 'failed');
+    add('信号', `${m.signal_quality}%${m.access_tech ? ' ' + m.access_tech : ''}`, m.signal_quality < 20);
+    add('运营商', m.operator);
+    add('注册', m.registration);
+    add('存储短信', m.stored_sms);
+  } else {
+    add('调制解调器', status.modem_error || '不可用', true);
+  }
+  if (status.sim) {
+    add('ICCID', status.sim.iccid);
+    add('本机号码', status.sim.own_numbers);
+  } else {
+    add('SIM 卡', status.sim_error || '不可用', true);
+  }
+  $('status').replaceChildren(...items);
+}
+
+// loadConversations 按发送方分组显示会话
+async function loadConversations() {
+  if (!status.archive) {
+    $('conversations').replaceChildren(el('li', 'hint', '短信归档未启用'));
+    return;
+  }
+  const q = encodeURIComponent($('search').value.trim());
+  const list = await api(`api/conversations?q=${q}`);
+  $('conversations').replaceChildren(...list.map((c) => {
+    const name = el('div', 'name', c.sender_name || c.sender, el('span', 'hint', formatTime(c.last.received_at)));
+    const badges = el('div', null, el('span', 'badge', `${c.count} 条`));
+    if (c.failed > 0) badges.append(el('span', 'badge failed', `${c.failed} 条投递失败`));
+    const li = el('li', c.sender === currentSender ? 'active' : null, name, el('div', 'preview', c.last.content), badges);
+    li.onclick = () => {
+      currentSender = c.sender;
+      for (const node of $('conversations').children) node.classList.remove('active');
+      li.classList.add('active');
+      loadThread();
+    };
+    return li;
+  }));
+  if (list.length === 0) {
+    $('conversations').replaceChildren(el('li', 'hint', '没有短信'));
+  }
+}
+
+// loadThread 显示当前会话的短信
+async function loadThread() {
+  if (!currentSender) return;
+  const q = encodeURIComponent($('search').value.trim());
+  const records = await api(`api/messages?sender=${encodeURIComponent(currentSender)}&q=${q}&limit=200`);
+  $('thread-title').textContent = records.length ? displaySender(records[0]) : currentSender;
+  $('thread').replaceChildren(...records.map(renderMessage));
+}
+
+// loadFeed 显示最新收到的短信
+async function loadFeed() {
+  if (!status.archive) return;
+  const records = await api('api/messages?limit=30');
+  $('feed').replaceChildren(...records.map(renderMessage));
+}
+
+// renderMessage 显示一条短信，以及各通知渠道最近一次的投递结果
+function renderMessage(r) {
+  const meta = el('div', 'meta', `#${r.id} · ${formatTime(r.received_at)} · ${displaySender(r)}`);
+  for (const tag of r.tags || []) meta.append(' ', el('span', 'badge tag', tag));
+
+  const latest = new Map();
+  for (const d of r.deliveries || []) latest.set(d.channel, d);
+  const deliveries = el('div', 'deliveries');
+  for (const [channel, d] of latest) {
+    const badge = el('span', `badge ${d.ok ? 'ok' : 'failed'}`, `${channel}${d.replay ? '（重发）' : ''} ${d.ok ? '成功' : '失败'}`);
+    if (!d.ok) {
+      badge.title = d.error || '';
+      if (channel !== 'mqtt') badge.append(retryButton(r.id, channel));
+    }
+    deliveries.append(badge);
+  }
+  return el('li', null, meta, el('div', 'content', r.content), deliveries);
+}
+
+function retryButton(id, channel) {
+  const button = el('button', null, '重试');
+  button.onclick = async () => {
+    button.disabled = true;
+    try {
+      await api('api/retry', { id, channel });
+    } catch (e) {
+      alert(`重新发送失败: ${e.message}`);
+    }
+    refresh();
+  };
+  return button;
+}
+
+function refresh() {
+  loadConversations().catch(() => {});
+  loadThread().catch(() => {});
+  loadFeed().catch(() => {});
+}
+
+$('login-form').onsubmit = async (e) => {
+  e.preventDefault();
+  $('login-error').textContent = '';
+  try {
+    await api('api/login', { password: $('password').value });
+    $('password').value = '';
+    await showApp();
+  } catch (err) {
+    $('login-error').textContent = err.message;
+  }
+};
+
+$('logout').onclick = async () => {
+  await api('api/logout', {}).catch(() => {});
+  showLogin();
+};
+
+for (const button of document.querySelectorAll('nav button[data-tab]')) {
+  button.onclick = () => {
+    for (const b of document.querySelectorAll('nav button[data-tab]')) b.classList.toggle('active', b === button);
+    for (const tab of document.querySelectorAll('.tab')) tab.hidden = tab.id !== `tab-${button.dataset.tab}`;
+  };
+}
+
+let searchTimer;
+$('search').oninput = () => {
+  clearTimeout(searchTimer);
+  searchTimer = setTimeout(() => { loadConversations(); loadThread(); }, 300);
+};
+
+$('compose-form').onsubmit = async (e) => {
+  e.preventDefault();
+  const button = e.target.querySelector('button');
+  button.disabled = true;
+  $('compose-result').textContent = '发送中…';
+  try {
+    await api('api/send', { number: $('compose-number').value, text: $('compose-text').value });
+    $('compose-result').textContent = '已发送';
+    $('compose-text').value = '';
+  } catch (err) {
+    $('compose-result').textContent = `发送失败: ${err.message}`;
+  }
+  button.disabled = false;
+};
+
+// 没有事件流时定期刷新
+setInterval(() => { if (!$('app').hidden && !stream) loadFeed().catch(() => {}); }, 10000);
+setInterval(() => { if (!$('app').hidden) { loadStatus().catch(() => {}); loadConversations().catch(() => {}); } }, 30000);
+
+showApp().catch(() => {});
@@ -0,0 +1,135 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"sim-sms-forward/pkg/events"
+)
+
+// streamPing 事件流的保活间隔，避免反向代理因长时间没有数据断开连接
+const streamPing = 30 * time.Second
+
+// handleEvents 以 Server-Sent Events 推送事件
+// 断线重连时浏览器会自动带上 Last-Event-ID 请求头，脚本也可以用 last_event_id 查询参数指定，
+// 服务从该序号之后的已保存事件开始推送，不会遗漏断线期间的事件
+// 查询参数:
+//   - types: 只推送指定类型的事件，多个用逗号分隔
+//   - last_event_id: 最后收到的事件序号
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if s.backend.Events == nil {
+		writeError(w, http.StatusServiceUnavailable, "事件流未启用")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "不支持事件流")
+		return
+	}
+
+	params := r.URL.Query()
+	var filter map[string]bool
+	if value := params.Get("types"); value != "" {
+		filter = make(map[string]bool)
+		for _, typ := range strings.Split(value, ",") {
+			filter[strings.TrimSpace(typ)] = true
+		}
+	}
+	lastID := params.Get("last_event_id")
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		lastID = header
+	}
+
+	// 先订阅再读取已保存的事件，两者之间发布的事件按序号去重；
+	// 没有指定序号时从订阅前的最后一个事件之后开始，订阅前后发布的事件在序号不连续时补齐
+	sent := s.backend.Events.LastID()
+	live, cancel := s.backend.Events.Subscribe()
+	defer cancel()
+	var backlog []events.Event
+	if lastID != "" {
+		id, err := strconv.ParseInt(lastID, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "last_event_id 格式不正确")
+			return
+		}
+		if backlog, err = s.backend.Events.Since(id); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		sent = id
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 5000\n\n")
+
+	send := func(e events.Event) error {
+		if e.ID <= sent {
+			return nil
+		}
+		sent = e.ID
+		if filter != nil && !filter[e.Type] {
+			return nil
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+		return err
+	}
+	// backfill 推送 sent 之后的已保存事件，读取或写入失败时返回 false 断开连接，由客户端带上 Last-Event-ID 重连
+	backfill := func() bool {
+		missed, err := s.backend.Events.Since(sent)
+		if err != nil {
+			return false
+		}
+		for _, e := range missed {
+			if err := send(e); err != nil {
+				return false
+			}
+		}
+		return true
+	}
+	for _, e := range backlog {
+		if err := send(e); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	ping := time.NewTicker(streamPing)
+	defer ping.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-live:
+			if !ok {
+				return
+			}
+			// 订阅方的缓冲区满时事件总线会丢弃事件，序号不连续时从已保存的事件中补齐
+			if e.ID > sent+1 && !backfill() {
+				return
+			}
+			if err := send(e); err != nil {
+				return
+			}
+		case <-ping.C:
+			// 最后几个事件被丢弃时没有后续事件触发补齐，保活时检查一次
+			if s.backend.Events.LastID() > sent && !backfill() {
+				return
+			}
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,165 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sim-sms-forward/pkg/events"
+)
+
+// gatedWriter 事件流的响应，写入第一个事件时阻塞，直到 release 关闭，用于让订阅方的缓冲区写满
+type gatedWriter struct {
+	header  http.Header
+	ready   chan struct{} // 写入响应头后关闭，说明已经订阅
+	blocked chan struct{} // 写入第一个事件时关闭
+	release chan struct{}
+
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	started bool
+	gated   bool
+}
+
+func newGatedWriter() *gatedWriter {
+	return &gatedWriter{
+		header:  make(http.Header),
+		ready:   make(chan struct{}),
+		blocked: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+}
+
+func (w *gatedWriter) Header() http.Header { return w.header }
+
+func (w *gatedWriter) WriteHeader(int) {}
+
+func (w *gatedWriter) Flush() {}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	if !w.started {
+		w.started = true
+		close(w.ready)
+	}
+	gate := !w.gated && bytes.HasPrefix(p, []byte("id: "))
+	if gate {
+		w.gated = true
+	}
+	w.mu.Unlock()
+	if gate {
+		close(w.blocked)
+		<-w.release
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+var eventIDRegex = regexp.MustCompile(`(?m)^id: (\d+)$`)
+
+// ids 返回已写出的事件序号
+func (w *gatedWriter) ids() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var ids []string
+	for _, m := range eventIDRegex.FindAllStringSubmatch(w.buf.String(), -1) {
+		ids = append(ids, m[1])
+	}
+	return ids
+}
+
+// waitIDs 等待写出 n 个事件
+func waitIDs(t *testing.T, w *gatedWriter, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(w.ids()) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待 %d 个事件超时，已写出 %v", n, w.ids())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func wait(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("等待%s超时", what)
+	}
+}
+
+// TestEventsBackfill 订阅方的缓冲区写满丢弃事件后，从已保存的事件中补齐，序号连续
+func TestEventsBackfill(t *testing.T) {
+	bus, err := events.Open(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 连接前的事件，没有指定 last_event_id 时不推送
+	bus.Publish(events.TypeModemState, events.Modem{State: "registered"})
+
+	s := &Server{backend: Backend{Events: bus}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/api/events", nil).WithContext(ctx)
+	w := newGatedWriter()
+	done := make(chan struct{})
+	go func() {
+		s.handleEvents(w, req)
+		close(done)
+	}()
+
+	wait(t, w.ready, "订阅")
+	bus.Publish(events.TypeSMSReceived, events.SMS{SMSID: "2"})
+	wait(t, w.blocked, "写入第一个事件")
+	for i := 3; i <= 100; i++ {
+		bus.Publish(events.TypeSMSReceived, events.SMS{SMSID: fmt.Sprint(i)})
+	}
+	close(w.release)
+
+	// 缓冲区中的事件推送完后，下一个事件触发补齐
+	waitIDs(t, w, 65)
+	bus.Publish(events.TypeSMSReceived, events.SMS{SMSID: "101"})
+	waitIDs(t, w, 100)
+	cancel()
+	wait(t, done, "事件流结束")
+
+	var want []string
+	for i := 2; i <= 101; i++ {
+		want = append(want, fmt.Sprint(i))
+	}
+	if got := w.ids(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("推送的事件序号为 %v，期望 2 到 101", got)
+	}
+}
+
+// TestEventsResume 指定 Last-Event-ID 时从该序号之后的已保存事件开始推送
+func TestEventsResume(t *testing.T) {
+	bus, err := events.Open(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		bus.Publish(events.TypeSMSReceived, events.SMS{SMSID: fmt.Sprint(i)})
+	}
+
+	s := &Server{backend: Backend{Events: bus}}
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/api/events", nil).WithContext(ctx)
+	req.Header.Set("Last-Event-ID", "1")
+	w := httptest.NewRecorder()
+	cancel()
+	s.handleEvents(w, req)
+
+	if got := eventIDRegex.FindAllString(w.Body.String(), -1); strings.Join(got, ",") != "id: 2,id: 3" {
+		t.Errorf("推送的事件为 %q，期望 2 和 3", got)
+	}
+}
@@ -0,0 +1,207 @@
+// Package web 提供内嵌在程序中的网页控制台，用于查看调制解调器状态、历史短信和投递结果，以及发送短信
+package web
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"sim-sms-forward/pkg/archive"
+	"sim-sms-forward/pkg/events"
+	"sim-sms-forward/pkg/logger"
+	"sim-sms-forward/pkg/types"
+)
+
+// Config 网页控制台的配置
+type Config struct {
+	Enable    bool   `json:"enable"`             // 是否启用网页控制台
+	Listen    string `json:"listen,omitempty"`   // 监听地址，默认 :8080
+	Password  string `json:"password,omitempty"` // 登录密码
+	Token     string `json:"token,omitempty"`    // 访问令牌，可以在登录页输入，也可以通过 Authorization: Bearer 请求头访问接口
+	AllowSend bool   `json:"allow_send"`         // 是否允许在网页上发送短信
+}
+
+// Validate 验证网页控制台配置
+func (c *Config) Validate() error {
+	if !c.Enable {
+		return nil
+	}
+	if c.Password == "" && c.Token == "" {
+		return fmt.Errorf("启用网页控制台时，password 和 token 至少配置一个")
+	}
+	return nil
+}
+
+// StatusFunc 获取调制解调器状态的函数
+type StatusFunc func() (*types.ModemStatus, error)
+
+// SIMFunc 获取 SIM 卡信息的函数
+type SIMFunc func() (*types.SIMInfo, error)
+
+// SendFunc 发送短信的函数
+type SendFunc func(number, text string) error
+
+// RetryFunc 将归档短信重新发送到指定通知渠道的函数
+type RetryFunc func(id int64, channel string) error
+
+// Backend 控制台使用的数据和操作，由处理器在启动时提供
+type Backend struct {
+	Status   StatusFunc       // 获取调制解调器状态
+	SIM      SIMFunc          // 获取 SIM 卡信息
+	Send     SendFunc         // 发送短信，为 nil 时不支持发送
+	Retry    RetryFunc        // 重新发送到指定渠道，为 nil 时不支持重试
+	Archive  *archive.Archive // 短信归档，未启用时为 nil
+	Events   *events.Bus      // 事件流，为 nil 时不支持 /api/events
+	Channels []string         // 已启用的通知渠道名称
+}
+
+// sessionCookie 登录后保存会话的 Cookie 名称
+const sessionCookie = "sms_forward_session"
+
+// sessionTTL 会话有效期
+const sessionTTL = 30 * 24 * time.Hour
+
+//go:embed static
+var staticFiles embed.FS
+
+// Server 网页控制台
+type Server struct {
+	cfg      Config
+	deviceID string
+	backend  Backend
+
+	mu       sync.Mutex
+	sessions map[string]time.Time // 会话标识到过期时间
+}
+
+// NewServer 创建网页控制台
+// 参数:
+//   - cfg: 网页控制台配置
+//   - deviceID: 设备标识，显示在页面标题中
+//
+// 返回: 初始化好的 Server 指针，调用 Start 后才会监听
+func NewServer(cfg Config, deviceID string) *Server {
+	if cfg.Listen == "" {
+		cfg.Listen = ":8080"
+	}
+	if deviceID == "" {
+		deviceID = "sim-sms-forward"
+	}
+	return &Server{
+		cfg:      cfg,
+		deviceID: deviceID,
+		sessions: make(map[string]time.Time),
+	}
+}
+
+// Start 在后台开始监听
+// 参数: backend - 控制台使用的数据和操作
+func (s *Server) Start(backend Backend) {
+	if !s.cfg.AllowSend {
+		backend.Send = nil
+	}
+	s.backend = backend
+
+	server := &http.Server{
+		Addr:              s.cfg.Listen,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		logger.Infof("网页控制台已启动: %s", s.cfg.Listen)
+		if err := server.ListenAndServe(); err != nil {
+			logger.Errorf("网页控制台停止: %v", err)
+		}
+	}()
+}
+
+// Handler 返回控制台的 HTTP 处理器
+func (s *Server) Handler() http.Handler {
+	static, _ := fs.Sub(staticFiles, "static")
+	mux := http.NewServeMux()
+	mux.Handle("GET /", http.FileServer(http.FS(static)))
+	mux.HandleFunc("POST /api/login", s.handleLogin)
+	mux.HandleFunc("POST /api/logout", s.handleLogout)
+	mux.HandleFunc("GET /api/status", s.auth(s.handleStatus))
+	mux.HandleFunc("GET /api/conversations", s.auth(s.handleConversations))
+	mux.HandleFunc("GET /api/messages", s.auth(s.handleMessages))
+	mux.HandleFunc("POST /api/retry", s.auth(s.handleRetry))
+	mux.HandleFunc("POST /api/send", s.auth(s.handleSend))
+	mux.HandleFunc("GET /api/events", s.auth(s.handleEvents))
+	return mux
+}
+
+// auth 要求请求带有有效的会话 Cookie 或访问令牌
+func (s *Server) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			writeError(w, http.StatusUnauthorized, "未登录或登录已过期")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// authorized 判断请求是否已登录
+func (s *Server) authorized(r *http.Request) bool {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return s.cfg.Token != "" && equal(token, s.cfg.Token)
+	}
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expires, ok := s.sessions[cookie.Value]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expires) {
+		delete(s.sessions, cookie.Value)
+		return false
+	}
+	return true
+}
+
+// checkSecret 判断登录时输入的密码或访问令牌是否正确
+func (s *Server) checkSecret(secret string) bool {
+	if secret == "" {
+		return false
+	}
+	return (s.cfg.Password != "" && equal(secret, s.cfg.Password)) ||
+		(s.cfg.Token != "" && equal(secret, s.cfg.Token))
+}
+
+// newSession 创建会话，同时清理已过期的会话
+func (s *Server) newSession() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成会话失败: %v", err)
+	}
+	id := hex.EncodeToString(buf)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for sid, expires := range s.sessions {
+		if now.After(expires) {
+			delete(s.sessions, sid)
+		}
+	}
+	s.sessions[id] = now.Add(sessionTTL)
+	return id, nil
+}
+
+// equal 以固定时间比较两个字符串，避免通过响应时间猜测密码
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
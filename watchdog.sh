@@ -11,6 +11,8 @@ fi
 PROGRAM_PATH="$ROOT_DIR/sim-sms-forward"  # 程序路径（与前一个脚本保持一致）
 LOG_FILE="$ROOT_DIR/logs/watchdog.log"     # 日志文件路径
 RUN_SCRIPT="$ROOT_DIR/run.sh"
+HEALTH_URL="${HEALTH_URL:-}"                # 存活检查地址，如 http://127.0.0.1:9108/healthz，为空时只检查进程
+HEALTH_TOKEN="${HEALTH_TOKEN:-}"            # 监控端点的访问令牌（配置了 metrics.token 时需要）
 
 # 确保日志文件存在并可写
 if [ ! -f "$LOG_FILE" ]; then
@@ -30,6 +32,25 @@ is_running() {
     return $?  # 0=运行中，1=未运行
 }
 
+# 检查处理循环是否还在推进（进程存在但卡住时返回 1）
+# 只有端点明确返回 503 时才判定为失去响应，端点暂时无法访问（如程序刚启动）时不处理
+is_healthy() {
+    [ -z "$HEALTH_URL" ] && return 0
+    local code
+    if [ -n "$HEALTH_TOKEN" ]; then
+        code=$(curl -s -o /dev/null -w '%{http_code}' --max-time 10 -H "Authorization: Bearer $HEALTH_TOKEN" "$HEALTH_URL")
+    else
+        code=$(curl -s -o /dev/null -w '%{http_code}' --max-time 10 "$HEALTH_URL")
+    fi
+    if [ "$code" = "503" ]; then
+        return 1
+    fi
+    if [ "$code" != "200" ]; then
+        log "存活检查无法访问（HTTP $code）: $HEALTH_URL"
+    fi
+    return 0
+}
+
 # 启动程序
 start_program() {
     if [ ! -x "$PROGRAM_PATH" ]; then
@@ -52,8 +73,17 @@ start_program() {
 }
 
 if is_running; then
-    # 程序运行中（仅记录状态，可注释以减少日志量）
-    log "程序运行正常"
+    if is_healthy; then
+        # 程序运行中（仅记录状态，可注释以减少日志量）
+        log "程序运行正常"
+    else
+        # 进程存在但处理循环失去响应，结束后重新拉起
+        log "程序失去响应，准备重启..."
+        pkill -f "$PROGRAM_PATH"
+        sleep 2
+        is_running && pkill -9 -f "$PROGRAM_PATH"
+        start_program
+    fi
 else
     # 程序未运行，尝试拉起
     log "程序未运行，准备启动..."